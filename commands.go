@@ -0,0 +1,860 @@
+package main
+
+import (
+	"copy-images/config"
+	"copy-images/file"
+	"copy-images/index"
+	"copy-images/model"
+	"copy-images/runs"
+	"copy-images/trash"
+	"copy-images/utils"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// command describes a subcommand of copy-images. Commands having subcommands only group them and cannot be run themselves
+type command struct {
+	name        string
+	group       string
+	args        string
+	summary     string
+	setFlags    func(fs *flag.FlagSet, opts *options)
+	run         func(opts *options, args []string, out io.Writer) error
+	subcommands []*command
+}
+
+// commands contains all subcommands in the order they are listed in the usage
+var commands = []*command{
+	{
+		name:    "scan",
+		summary: "list all files which would be collected from the source",
+		setFlags: func(fs *flag.FlagSet, opts *options) {
+			opts.addProfileFlags(fs)
+			opts.addSourceFlags(fs)
+		},
+		run: runScan,
+	},
+	{
+		name:    "plan",
+		summary: "write a json plan describing all operations a move would perform",
+		setFlags: func(fs *flag.FlagSet, opts *options) {
+			opts.addProfileFlags(fs)
+			opts.addSourceFlags(fs)
+			opts.addTargetFlags(fs)
+			opts.addRetentionFlags(fs)
+			opts.addSpaceFlags(fs)
+			fs.StringVar(&opts.planFile, "out", "", "`name` of the plan file written to the target (default copy_desc_<time>.json)")
+		},
+		run: runPlan,
+	},
+	{
+		name:    "copy",
+		summary: "copy all files from the source to the target",
+		setFlags: func(fs *flag.FlagSet, opts *options) {
+			opts.addProfileFlags(fs)
+			opts.addSourceFlags(fs)
+			opts.addTargetFlags(fs)
+			opts.addExecutorFlags(fs)
+			opts.addSpaceFlags(fs)
+		},
+		run: runCopy,
+	},
+	{
+		name:    "move",
+		summary: "copy all files to the target and delete the ones the retention policy selects from the source",
+		setFlags: func(fs *flag.FlagSet, opts *options) {
+			opts.addProfileFlags(fs)
+			opts.addSourceFlags(fs)
+			opts.addTargetFlags(fs)
+			opts.addRetentionFlags(fs)
+			opts.addExecutorFlags(fs)
+			opts.addSpaceFlags(fs)
+			opts.addTrashFlags(fs)
+		},
+		run: runMove,
+	},
+	{
+		name:    "apply",
+		args:    "<plan.json>",
+		summary: "execute the operations of a plan written by the plan command",
+		setFlags: func(fs *flag.FlagSet, opts *options) {
+			opts.addProfileFlags(fs)
+			fs.StringVar(&opts.target, "target", "", "target `dir` whose index records the copied files and whose leftover temp files are removed")
+			opts.addExecutorFlags(fs)
+			opts.addSpaceFlags(fs)
+			opts.addTrashFlags(fs)
+		},
+		run: runApply,
+	},
+	{
+		name:    "resume",
+		args:    "<plan.json | run-id>",
+		summary: "continue an interrupted apply, copy or move where its checkpoint stopped",
+		setFlags: func(fs *flag.FlagSet, opts *options) {
+			opts.addProfileFlags(fs)
+			fs.StringVar(&opts.target, "target", "", "target `dir` whose index records the copied files and which holds the plans of copy and move runs")
+			opts.addExecutorFlags(fs)
+			opts.addSpaceFlags(fs)
+			opts.addTrashFlags(fs)
+		},
+		run: runResume,
+	},
+	{
+		name:    "verify",
+		args:    "<plan.json>",
+		summary: "check that all operations of a plan have been carried out",
+		run:     runVerify,
+	},
+	{
+		name:    "index",
+		summary: "work with the index of imported files kept in the target",
+		subcommands: []*command{
+			{
+				name:    "rebuild",
+				group:   "index",
+				summary: "regenerate the index by scanning all files of the target",
+				setFlags: func(fs *flag.FlagSet, opts *options) {
+					opts.addProfileFlags(fs)
+					fs.StringVar(&opts.target, "target", "", "target `dir` to index (required)")
+					opts.addCollectFlags(fs)
+				},
+				run: runIndexRebuild,
+			},
+		},
+	},
+	{
+		name:    "trash",
+		summary: "work with the sources moves have put into the trash",
+		subcommands: []*command{
+			{
+				name:    "restore",
+				group:   "trash",
+				summary: "put the sources a run deleted back where they were",
+				setFlags: func(fs *flag.FlagSet, opts *options) {
+					opts.addProfileFlags(fs)
+					fs.StringVar(&opts.target, "target", "", "target `dir` holding the manifest of the run (required)")
+					fs.StringVar(&opts.runID, "run", "", "`id` of the run whose deleted sources are restored (required)")
+				},
+				run: runTrashRestore,
+			},
+		},
+	},
+	{
+		name:    "undo",
+		args:    "<run-id>",
+		summary: "remove the copies a run created and put back the sources it deleted",
+		setFlags: func(fs *flag.FlagSet, opts *options) {
+			opts.addProfileFlags(fs)
+			fs.StringVar(&opts.target, "target", "", "target `dir` holding the journal of the run (required)")
+		},
+		run: runUndo,
+	},
+	{
+		name:    "retention",
+		summary: "work with the retention policy deciding which files are deleted from the source",
+		subcommands: []*command{
+			{
+				name:    "preview",
+				group:   "retention",
+				summary: "explain for every file whether a move would delete or keep it and which rule decided",
+				setFlags: func(fs *flag.FlagSet, opts *options) {
+					opts.addProfileFlags(fs)
+					opts.addSourceFlags(fs)
+					opts.addTargetFlags(fs)
+					opts.addRetentionFlags(fs)
+				},
+				run: runRetentionPreview,
+			},
+		},
+	},
+	{
+		name:    "config",
+		summary: "work with the config file",
+		subcommands: []*command{
+			{
+				name:    "validate",
+				group:   "config",
+				summary: "report unknown keys and bad values of the config file",
+				setFlags: func(fs *flag.FlagSet, opts *options) {
+					fs.StringVar(&opts.configFile, "config", config.DefaultPath(), "config `file` to validate")
+				},
+				run: runConfigValidate,
+			},
+		},
+	},
+}
+
+// findCommand returns the command with the given name out of the given commands or nil
+func findCommand(cmds []*command, name string) *command {
+	for _, cmd := range cmds {
+		if cmd.name == name {
+			return cmd
+		}
+	}
+	return nil
+}
+
+// fullName returns the name of the command including its group
+func (c *command) fullName() string {
+	if c.group == "" {
+		return c.name
+	}
+	return c.group + " " + c.name
+}
+
+// flagSet creates the flag.FlagSet of the command writing its usage to the given output
+func (c *command) flagSet(opts *options, output io.Writer) *flag.FlagSet {
+	fs := flag.NewFlagSet(c.fullName(), flag.ContinueOnError)
+	fs.SetOutput(output)
+	if c.setFlags != nil {
+		c.setFlags(fs, opts)
+	}
+	fs.Usage = func() {
+		if len(c.subcommands) > 0 {
+			fmt.Fprintf(fs.Output(), "Usage: copy-images %s <command> [flags] [args]\n\n", c.name)
+			fmt.Fprintf(fs.Output(), "%s\n\nCommands:\n", c.summary)
+			for _, sub := range c.subcommands {
+				fmt.Fprintf(fs.Output(), "  %-8s %s\n", sub.name, sub.summary)
+			}
+			return
+		}
+		fmt.Fprintf(fs.Output(), "Usage: copy-images %s [flags] %s\n\n", c.fullName(), c.args)
+		fmt.Fprintf(fs.Output(), "%s\n", c.summary)
+		hasFlags := false
+		fs.VisitAll(func(*flag.Flag) { hasFlags = true })
+		if hasFlags {
+			fmt.Fprintf(fs.Output(), "\nFlags:\n")
+			fs.PrintDefaults()
+		}
+	}
+	return fs
+}
+
+// noArgs returns a usage error if any positional arguments are given
+func noArgs(args []string) error {
+	if len(args) > 0 {
+		return newUsageError(fmt.Sprintf("unexpected argument %q", args[0]))
+	}
+	return nil
+}
+
+// planArg returns the single plan file argument
+func planArg(args []string) (string, error) {
+	if len(args) != 1 {
+		return "", newUsageError("expected exactly one plan file")
+	}
+	return args[0], nil
+}
+
+// collect collects all files from the source described by the options, the files the planner of the copyConfig
+// hashes are hashed while walking
+func collect(opts *options, copyConfig file.CopyConfig, out io.Writer) ([]model.FileInfo, error) {
+	if err := opts.requireSource(); err != nil {
+		return nil, err
+	}
+	collectFilesConfig, err := opts.collectFilesConfig()
+	if err != nil {
+		return nil, err
+	}
+	collectFilesConfig.Hash = copyConfig.HashNeeded
+	var images []model.FileInfo
+	err = file.CollectFiles(opts.source, &images, collectFilesConfig)
+	return images, checkScan(opts, err, out)
+}
+
+// checkScan reports the paths a scan skipped. They only fail the command with --strict, other errors always do.
+func checkScan(opts *options, err error, out io.Writer) error {
+	var report *file.ScanReport
+	if !errors.As(err, &report) {
+		return err
+	}
+	fmt.Fprintln(out, "Warning:", report.Error())
+	if opts.strict {
+		return fmt.Errorf("--strict: %d paths of %s could not be scanned", len(report.Problems), report.Root)
+	}
+	return nil
+}
+
+// cleanupTarget removes the temp files runs which died while copying left in the target, see file.CleanupTempFiles
+func cleanupTarget(target string, runID string, out io.Writer) error {
+	removed, err := file.CleanupTempFiles(target, runID)
+	for _, tempFile := range removed {
+		fmt.Fprintln(out, "Removed leftover temp file", tempFile)
+	}
+	return err
+}
+
+// finishRun closes the checkpoint and the journal and writes the manifest of the executor to the target, nothing is
+// written without a target. It returns the error of the run which is more important than an error writing the manifest.
+func finishRun(target string, executor file.Executor, runErr error, out io.Writer) error {
+	var err error
+	if executor.Checkpoint != nil {
+		err = executor.Checkpoint.Close()
+		if runErr != nil {
+			resume := "copy-images resume " + executor.Checkpoint.PlanFile()
+			if target != "" {
+				resume = "copy-images resume --target " + target + " " + executor.Checkpoint.PlanFile()
+			}
+			fmt.Fprintln(out, "Resume the run with:", resume)
+		}
+	}
+	if target == "" || executor.Manifest == nil {
+		if runErr != nil {
+			return runErr
+		}
+		return err
+	}
+	if executor.Journal != nil {
+		if closeErr := executor.Journal.Close(); err == nil {
+			err = closeErr
+		}
+	}
+	manifest := executor.Manifest
+	manifestFile, writeErr := manifest.Write(target)
+	if err == nil {
+		err = writeErr
+	}
+	if err != nil {
+		if runErr != nil {
+			return runErr
+		}
+		return err
+	}
+	fmt.Fprintf(out, "Run %s: %d verified, %d copied without verification, %d mismatches, manifest %s\n",
+		manifest.RunID, manifest.Count(runs.Verified), manifest.Count(runs.Copied), manifest.Count(runs.Mismatch), manifestFile)
+	return runErr
+}
+
+// deletesSources checks if the plan has operations removing their source, only those need a trash
+func deletesSources(fileOps model.FileOperations) bool {
+	for _, fileOp := range fileOps.FileOperations {
+		if fileOp.OpType == model.MoveOp || fileOp.OpType == model.DeleteOp {
+			return true
+		}
+	}
+	return false
+}
+
+// countOps counts the operations of the given type
+func countOps(fileOps model.FileOperations, opType model.OpType) int {
+	count := 0
+	for _, fileOp := range fileOps.FileOperations {
+		if fileOp.OpType == opType {
+			// the companions of a pair are counted as files of their own
+			count += 1 + len(fileOp.Companions)
+		}
+	}
+	return count
+}
+
+func runScan(opts *options, args []string, out io.Writer) error {
+	if err := noArgs(args); err != nil {
+		return err
+	}
+	if err := opts.requireSource(); err != nil {
+		return err
+	}
+	collectFilesConfig, err := opts.collectFilesConfig()
+	if err != nil {
+		return err
+	}
+	// the files are printed as soon as they are found, in the order the walk finds them
+	found := make(chan model.FileInfo)
+	walkErr := make(chan error, 1)
+	go func() {
+		walkErr <- file.WalkFiles(opts.source, found, collectFilesConfig)
+	}()
+	count := 0
+	for image := range found {
+		if image.MimeType != "" {
+			fmt.Fprintln(out, image.Path, image.CreationDate.Format(time.RFC3339), image.DateSource, image.MimeType)
+		} else {
+			fmt.Fprintln(out, image.Path, image.CreationDate.Format(time.RFC3339), image.DateSource)
+		}
+		count++
+	}
+	fmt.Fprintln(out, "Number of files found:", count)
+	return checkScan(opts, <-walkErr, out)
+}
+
+func runPlan(opts *options, args []string, out io.Writer) error {
+	if err := noArgs(args); err != nil {
+		return err
+	}
+	if err := opts.requireTarget(); err != nil {
+		return err
+	}
+	policy, err := opts.retentionPolicy()
+	if err != nil {
+		return err
+	}
+	copyConfig, err := opts.copyConfig()
+	if err != nil {
+		return err
+	}
+	images, err := collect(opts, copyConfig, out)
+	if err != nil {
+		return err
+	}
+	if !opts.allowDelete {
+		fmt.Fprintf(out, "Profile %s does not allow deletion, keeping all source files\n", opts.profile)
+		policy = nil
+	}
+	planFile := opts.planFile
+	if planFile == "" {
+		planFile = "copy_desc_" + time.Now().Format("2006-01-02-15:04:05") + ".json"
+	}
+	fmt.Fprintln(out, "Writing file op description", len(images))
+	planner := file.Planner{TargetDir: opts.target, Retention: policy, Facts: opts.retentionFacts(), CopyConfig: copyConfig}
+	fileOps, err := planner.Plan(images)
+	if err != nil {
+		return err
+	}
+	// a plan which does not fit is written anyway, apply refuses it unless the target has been cleaned up in between
+	fileOps, err = checkSpace(opts, fileOps, out)
+	var spaceErr *file.SpaceError
+	if errors.As(err, &spaceErr) {
+		fmt.Fprintln(out, "Warning:", err)
+	} else if err != nil {
+		return err
+	}
+	planFile = filepath.Join(opts.target, planFile)
+	if err = file.WriteFileOperations(planFile, fileOps); err != nil {
+		return err
+	}
+	fmt.Fprintln(out, planFile+" written!")
+	return nil
+}
+
+// writeRunPlan writes the plan of a copy or move to the dir of its run and starts its checkpoint, so that the run can
+// be resumed if it is interrupted
+func writeRunPlan(target string, runID string, fileOps model.FileOperations) (*runs.Checkpoint, error) {
+	planFile := runs.PlanPath(target, runID)
+	if err := os.MkdirAll(filepath.Dir(planFile), os.ModePerm); err != nil {
+		return nil, err
+	}
+	if err := file.WriteFileOperations(planFile, fileOps); err != nil {
+		return nil, err
+	}
+	return runs.NewCheckpoint(planFile)
+}
+
+// checkSpace prints the space the operations need on every file system of the target and returns a *file.SpaceError
+// if they do not fit. With --trim-to-space the newest operations which do not fit are skipped instead.
+func checkSpace(opts *options, fileOps model.FileOperations, out io.Writer) (model.FileOperations, error) {
+	reserve, err := opts.spaceReserve()
+	if err != nil {
+		return fileOps, err
+	}
+	estimate, err := file.EstimateSpace(fileOps, reserve)
+	if err != nil {
+		return fileOps, err
+	}
+	fileOps.Space = &estimate
+	if !estimate.Sufficient() && opts.trimToSpace {
+		var trimmed int
+		if fileOps, trimmed, err = file.TrimToSpace(fileOps, reserve); err != nil {
+			return fileOps, err
+		}
+		fmt.Fprintf(out, "Skipping %d operations which do not fit into the target\n", trimmed)
+		estimate = *fileOps.Space
+	}
+	for _, line := range file.DescribeSpace(estimate) {
+		fmt.Fprintln(out, "Space:", line)
+	}
+	if !estimate.Sufficient() {
+		return fileOps, &file.SpaceError{Estimate: estimate}
+	}
+	return fileOps, nil
+}
+
+func runCopy(opts *options, args []string, out io.Writer) error {
+	if err := noArgs(args); err != nil {
+		return err
+	}
+	if err := opts.requireTarget(); err != nil {
+		return err
+	}
+	copyConfig, err := opts.copyConfig()
+	if err != nil {
+		return err
+	}
+	executor, err := opts.executor(copyConfig.Index, runs.NewManifest(), false)
+	if err != nil {
+		return err
+	}
+	images, err := collect(opts, copyConfig, out)
+	if err != nil {
+		return err
+	}
+	if err = cleanupTarget(opts.target, executor.Manifest.RunID, out); err != nil {
+		return err
+	}
+	planner := file.Planner{TargetDir: opts.target, CopyConfig: copyConfig}
+	fileOps, err := planner.Plan(images)
+	if err != nil {
+		return err
+	}
+	if fileOps, err = checkSpace(opts, fileOps, out); err != nil {
+		return err
+	}
+	if executor.Checkpoint, err = writeRunPlan(opts.target, executor.Manifest.RunID, fileOps); err != nil {
+		return err
+	}
+	if err = finishRun(opts.target, executor, executor.Apply(fileOps), out); err != nil {
+		return err
+	}
+	fmt.Fprintln(out, "Copied all files:", countOps(fileOps, model.CopyOp))
+	fmt.Fprintln(out, "Skipped files:", countOps(fileOps, model.SkipOp))
+	return nil
+}
+
+func runMove(opts *options, args []string, out io.Writer) error {
+	if err := noArgs(args); err != nil {
+		return err
+	}
+	if err := opts.requireTarget(); err != nil {
+		return err
+	}
+	policy, err := opts.retentionPolicy()
+	if err != nil {
+		return err
+	}
+	copyConfig, err := opts.copyConfig()
+	if err != nil {
+		return err
+	}
+	executor, err := opts.executor(copyConfig.Index, runs.NewManifest(), true)
+	if err != nil {
+		return err
+	}
+	images, err := collect(opts, copyConfig, out)
+	if err != nil {
+		return err
+	}
+	if !opts.allowDelete {
+		fmt.Fprintf(out, "Profile %s does not allow deletion, keeping all source files\n", opts.profile)
+		policy = nil
+	}
+	if err = cleanupTarget(opts.target, executor.Manifest.RunID, out); err != nil {
+		return err
+	}
+	if err = opts.purgeQuarantine(executor.Trash, out); err != nil {
+		return err
+	}
+	planner := file.Planner{TargetDir: opts.target, Retention: policy, Facts: opts.retentionFacts(), CopyConfig: copyConfig}
+	fileOps, err := planner.Plan(images)
+	if err != nil {
+		return err
+	}
+	if fileOps, err = checkSpace(opts, fileOps, out); err != nil {
+		return err
+	}
+	if executor.Checkpoint, err = writeRunPlan(opts.target, executor.Manifest.RunID, fileOps); err != nil {
+		return err
+	}
+	if err = finishRun(opts.target, executor, executor.Apply(fileOps), out); err != nil {
+		return err
+	}
+	fmt.Fprintln(out, "Copied all files:", countOps(fileOps, model.CopyOp)+countOps(fileOps, model.MoveOp))
+	fmt.Fprintln(out, "Moved files:", countOps(fileOps, model.MoveOp))
+	fmt.Fprintln(out, "Deleted files already in the target:", countOps(fileOps, model.DeleteOp))
+	fmt.Fprintln(out, "Skipped files:", countOps(fileOps, model.SkipOp))
+	return nil
+}
+
+func runApply(opts *options, args []string, out io.Writer) error {
+	planFile, err := planArg(args)
+	if err != nil {
+		return err
+	}
+	checkpoint, err := runs.NewCheckpoint(planFile)
+	if err != nil {
+		return err
+	}
+	return applyPlan(opts, checkpoint, out)
+}
+
+func runResume(opts *options, args []string, out io.Writer) error {
+	if len(args) != 1 {
+		return newUsageError("expected exactly one plan file or run id")
+	}
+	// copy and move keep their plan in the dir of their run
+	planFile := args[0]
+	if !fileExists(planFile) && opts.target != "" && !strings.ContainsRune(planFile, filepath.Separator) {
+		planFile = runs.PlanPath(opts.target, args[0])
+	}
+	if _, err := os.Stat(planFile); err != nil {
+		return err
+	}
+	checkpoint, err := runs.OpenCheckpoint(planFile)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(out, "Resuming %s, completed operations: %d\n", planFile, checkpoint.Count())
+	return applyPlan(opts, checkpoint, out)
+}
+
+// applyPlan executes the plan of the checkpoint, the operations the checkpoint knows as completed are not repeated.
+// Resuming the plan of a copy or move appends to the journal and the manifest of its run.
+func applyPlan(opts *options, checkpoint *runs.Checkpoint, out io.Writer) error {
+	fileOps, err := file.ReadFileOperations(checkpoint.PlanFile())
+	if err != nil {
+		return err
+	}
+	// a resumed copy or move continues its run, any other plan records the run it resumes
+	manifest := runs.NewManifest()
+	if runID := runs.RunOf(opts.target, checkpoint.PlanFile()); opts.target != "" && runID != "" {
+		if manifest, err = runs.OpenManifest(opts.target, runID); err != nil {
+			return err
+		}
+	} else {
+		manifest.Parent = checkpoint.LastRun()
+	}
+	var idx *index.Index
+	if opts.target != "" {
+		if err = cleanupTarget(opts.target, manifest.RunID, out); err != nil {
+			return err
+		}
+		if idx, err = opts.loadIndex(); err != nil {
+			return err
+		}
+	}
+	executor, err := opts.executor(idx, manifest, deletesSources(fileOps))
+	if err != nil {
+		return err
+	}
+	if err = checkpoint.Start(manifest.RunID); err != nil {
+		return err
+	}
+	executor.Checkpoint = checkpoint
+	if err = opts.purgeQuarantine(executor.Trash, out); err != nil {
+		return err
+	}
+	if fileOps, err = checkSpace(opts, fileOps, out); err != nil {
+		return err
+	}
+	if err = finishRun(opts.target, executor, executor.Apply(fileOps), out); err != nil {
+		return err
+	}
+	fmt.Fprintln(out, "Applied all operations:", len(fileOps.FileOperations))
+	return nil
+}
+
+func runVerify(opts *options, args []string, out io.Writer) error {
+	planFile, err := planArg(args)
+	if err != nil {
+		return err
+	}
+	fileOps, err := file.ReadFileOperations(planFile)
+	if err != nil {
+		return err
+	}
+	problems := file.VerifyFileOperations(fileOps)
+	for _, problem := range problems {
+		fmt.Fprintln(out, problem)
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("%d of %d operations failed verification", len(problems), len(fileOps.FileOperations))
+	}
+	fmt.Fprintln(out, "Verified all operations:", len(fileOps.FileOperations))
+	return nil
+}
+
+func runIndexRebuild(opts *options, args []string, out io.Writer) error {
+	if err := noArgs(args); err != nil {
+		return err
+	}
+	if err := opts.requireTarget(); err != nil {
+		return err
+	}
+	collectFilesConfig, err := opts.collectFilesConfig()
+	if err != nil {
+		return err
+	}
+	// an index rebuilt from a partial scan would forget files, so every skipped path fails the rebuild
+	var images []model.FileInfo
+	if err = file.CollectFiles(opts.target, &images, collectFilesConfig); err != nil {
+		return err
+	}
+	idx, err := index.Rebuild(opts.target, images)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(out, "%s written, indexed files: %d\n", index.Path(opts.target), len(idx.Entries()))
+	return nil
+}
+
+func runRetentionPreview(opts *options, args []string, out io.Writer) error {
+	if err := noArgs(args); err != nil {
+		return err
+	}
+	if err := opts.requireTarget(); err != nil {
+		return err
+	}
+	policy, err := opts.retentionPolicy()
+	if err != nil {
+		return err
+	}
+	copyConfig, err := opts.copyConfig()
+	if err != nil {
+		return err
+	}
+	images, err := collect(opts, copyConfig, out)
+	if err != nil {
+		return err
+	}
+	if !opts.allowDelete {
+		fmt.Fprintf(out, "Profile %s does not allow deletion, keeping all source files\n", opts.profile)
+		return nil
+	}
+	facts := opts.retentionFacts()
+	if policy.MinFreeBytes > 0 && facts.FreeBytes >= 0 {
+		fmt.Fprintf(out, "Free space of the source: %s\n", utils.FormatSize(facts.FreeBytes))
+	}
+	planner := file.Planner{TargetDir: opts.target, Retention: policy, Facts: facts, CopyConfig: copyConfig}
+	fileOps, err := planner.Plan(images)
+	if err != nil {
+		return err
+	}
+	for _, fileOp := range fileOps.FileOperations {
+		fmt.Fprintf(out, "%s%s: %s\n", fileOp.From, pairedNames(fileOp), fileOp.Retention)
+	}
+	fmt.Fprintln(out, "Files to delete:", countOps(fileOps, model.MoveOp)+countOps(fileOps, model.DeleteOp))
+	fmt.Fprintln(out, "Files to keep:", countOps(fileOps, model.CopyOp)+countOps(fileOps, model.SkipOp))
+	return nil
+}
+
+// pairedNames lists the names of the files paired with the file of the operation, sidecars are left out
+func pairedNames(fileOp model.FileOperation) string {
+	var names strings.Builder
+	for _, companion := range fileOp.Companions {
+		if !companion.Sidecar {
+			names.WriteString(" + " + filepath.Base(companion.From))
+		}
+	}
+	return names.String()
+}
+
+func runTrashRestore(opts *options, args []string, out io.Writer) error {
+	if err := noArgs(args); err != nil {
+		return err
+	}
+	if err := opts.requireTarget(); err != nil {
+		return err
+	}
+	if opts.runID == "" {
+		return newUsageError("missing required flag --run")
+	}
+	// a run resuming the plan of another run restores the sources of that run as well
+	var entries []runs.Entry
+	for i, runID := range runs.Lineage(opts.target, opts.runID) {
+		manifest, err := runs.ReadManifest(opts.target, runID)
+		if i > 0 && os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("run %s: %w", runID, err)
+		}
+		entries = append(entries, manifest.Entries...)
+	}
+	restored, removed := 0, 0
+	var failures []string
+	for _, entry := range entries {
+		if entry.SourceDeleted && entry.Trashed == "" {
+			removed++
+			continue
+		}
+		if entry.Trashed == "" {
+			continue
+		}
+		// a second restore finds the files where they were
+		if _, err := os.Lstat(entry.Trashed); os.IsNotExist(err) && fileExists(entry.From) {
+			fmt.Fprintln(out, "Already restored", entry.From)
+			continue
+		}
+		if err := trash.Restore(entry.Trashed, entry.From); err != nil {
+			failures = append(failures, err.Error())
+			continue
+		}
+		fmt.Fprintln(out, "Restored", entry.From)
+		restored++
+	}
+	if removed > 0 {
+		fmt.Fprintf(out, "Warning: %d sources of run %s have been removed for good and cannot be restored\n", removed, opts.runID)
+	}
+	fmt.Fprintln(out, "Restored files:", restored)
+	if len(failures) > 0 {
+		return fmt.Errorf("%d files could not be restored:\n  %s", len(failures), strings.Join(failures, "\n  "))
+	}
+	return nil
+}
+
+func runUndo(opts *options, args []string, out io.Writer) error {
+	if len(args) != 1 {
+		return newUsageError("expected exactly one run id")
+	}
+	if err := opts.requireTarget(); err != nil {
+		return err
+	}
+	// a run resuming the plan of another run undoes that run as well, the journals are joined oldest first
+	var records []runs.Record
+	for i, runID := range runs.Lineage(opts.target, args[0]) {
+		journal, err := runs.ReadJournal(opts.target, runID)
+		if i > 0 && os.IsNotExist(err) {
+			// the parent ended before it changed anything
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("run %s: %w", runID, err)
+		}
+		if i > 0 {
+			fmt.Fprintf(out, "Undoing run %s resumed by run %s\n", runID, args[0])
+		}
+		records = append(journal, records...)
+	}
+	report := file.Undo(opts.target, records)
+	for _, path := range report.Removed {
+		fmt.Fprintln(out, "Removed", path)
+	}
+	for _, path := range report.Restored {
+		fmt.Fprintln(out, "Restored", path)
+	}
+	fmt.Fprintln(out, "Removed copies:", len(report.Removed))
+	fmt.Fprintln(out, "Restored sources:", len(report.Restored))
+	if len(report.Problems) > 0 {
+		return fmt.Errorf("%d paths have not been undone:\n  %s", len(report.Problems), strings.Join(report.Problems, "\n  "))
+	}
+	return nil
+}
+
+// fileExists checks if there is a file at the path
+func fileExists(path string) bool {
+	_, err := os.Lstat(path)
+	return err == nil
+}
+
+func runConfigValidate(opts *options, args []string, out io.Writer) error {
+	if err := noArgs(args); err != nil {
+		return err
+	}
+	cfg, err := config.Load(opts.configFile)
+	var validationErr *config.ValidationError
+	if errors.As(err, &validationErr) {
+		for _, problem := range validationErr.Problems {
+			fmt.Fprintln(out, problem)
+		}
+		return fmt.Errorf("%s has %d problems", opts.configFile, len(validationErr.Problems))
+	}
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(out, "%s is valid, profiles: %s\n", opts.configFile, strings.Join(cfg.ProfileNames(), ", "))
+	return nil
+}
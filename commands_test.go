@@ -0,0 +1,81 @@
+package main
+
+import (
+	"bytes"
+	"copy-images/file"
+	"copy-images/model"
+	"copy-images/retention"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// writeFile writes the content to the path, creating its dirs, and returns the path
+func writeFile(t *testing.T, path string, content string) string {
+	assert.Nil(t, os.MkdirAll(filepath.Dir(path), os.ModePerm))
+	assert.Nil(t, ioutil.WriteFile(path, []byte(content), 0644))
+	return path
+}
+
+func TestPlanKeepsTheSourcesOfAProfileWhichDoesNotAllowDeletion(t *testing.T) {
+
+	//GIVEN
+	sourceDir := t.TempDir()
+	targetDir := t.TempDir()
+	old := time.Now().AddDate(-2, 0, 0)
+	for _, name := range []string{"IMG_1.jpg", "IMG_2.jpg"} {
+		photo := writeFile(t, filepath.Join(sourceDir, name), name)
+		os.Chtimes(photo, old, old)
+	}
+	writeFile(t, filepath.Join(targetDir, "library", "IMG_2.jpg"), "IMG_2.jpg")
+	configFile := writeFile(t, filepath.Join(t.TempDir(), "config.yaml"), "profiles:\n  camera:\n    allow_delete: false\n")
+	var stdout, stderr bytes.Buffer
+
+	//WHEN
+	code := run([]string{"plan", "--config", configFile, "--profile", "camera", "--source", sourceDir, "--target", targetDir,
+		"--cutoff-months", "0", "--reserve-gb", "0", "--out", "plan.json"}, &stdout, &stderr)
+	fileOps, err := file.ReadFileOperations(filepath.Join(targetDir, "plan.json"))
+
+	//THEN
+	assert.Equal(t, 0, code, stderr.String())
+	assert.Nil(t, err, "No error must be thrown")
+	assert.Contains(t, stdout.String(), "Profile camera does not allow deletion")
+	var opTypes []model.OpType
+	for _, fileOp := range fileOps.FileOperations {
+		opTypes = append(opTypes, fileOp.OpType)
+	}
+	assert.ElementsMatch(t, []model.OpType{model.CopyOp, model.SkipOp}, opTypes, "Only copies and skips must be planned")
+
+}
+
+func TestApplyOfACopyOnlyPlanNeedsNoQuarantine(t *testing.T) {
+
+	//GIVEN
+	sourceDir := t.TempDir()
+	targetDir := t.TempDir()
+	photo := model.FileInfo{Path: writeFile(t, filepath.Join(sourceDir, "IMG_1.jpg"), "photo"), CreationDate: time.Now()}
+	old := model.FileInfo{Path: writeFile(t, filepath.Join(sourceDir, "IMG_2.jpg"), "old photo"), CreationDate: time.Now().AddDate(-2, 0, 0)}
+	copyOps, _ := file.Planner{TargetDir: targetDir}.Plan([]model.FileInfo{photo})
+	copyPlan := filepath.Join(t.TempDir(), "copy.json")
+	file.WriteFileOperations(copyPlan, copyOps)
+	moveOps, _ := file.Planner{TargetDir: targetDir, Retention: &retention.Policy{}}.Plan([]model.FileInfo{old})
+	movePlan := filepath.Join(t.TempDir(), "move.json")
+	file.WriteFileOperations(movePlan, moveOps)
+	var stdout, stderr bytes.Buffer
+
+	//WHEN
+	copyCode := run([]string{"apply", "--trash", "quarantine", "--reserve-gb", "0", copyPlan}, &stdout, &stderr)
+	moveCode := run([]string{"apply", "--trash", "quarantine", "--reserve-gb", "0", movePlan}, &stdout, &stderr)
+
+	//THEN
+	assert.Equal(t, 0, copyCode, stderr.String())
+	assert.FileExists(t, copyOps.FileOperations[0].To)
+	assert.Equal(t, 2, moveCode, "A plan deleting sources needs to know where to quarantine them")
+	assert.Contains(t, stderr.String(), "--quarantine-dir is required without --source")
+	assert.FileExists(t, old.Path)
+
+}
@@ -0,0 +1,185 @@
+// Package config reads the declarative configuration file holding the named device profiles
+package config
+
+import (
+	"bytes"
+	"copy-images/dates"
+	"copy-images/ignore"
+	"copy-images/layout"
+	"copy-images/trash"
+	"copy-images/utils"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// EnvConfigFile is the environment variable which can point to the config file
+const EnvConfigFile = "COPY_IMAGES_CONFIG"
+
+// Config is the content of a config file
+type Config struct {
+	Profiles map[string]Profile `yaml:"profiles"`
+}
+
+// Profile describes how the files of a single device are collected, copied and deleted.
+// Unset fields are nil or empty so that callers can fall back to their defaults.
+type Profile struct {
+	Source              string   `yaml:"source"`
+	Target              string   `yaml:"target"`
+	VideosDir           string   `yaml:"videos_dir"`
+	Layout              string   `yaml:"layout"`
+	ExcludedDirs        []string `yaml:"excluded_dirs"`
+	SupportedExtensions []string `yaml:"supported_extensions"`
+	DateSources         []string `yaml:"date_sources"`
+	CutoffMonths        *int     `yaml:"cutoff_months"`
+	KeepFavorites       *bool    `yaml:"keep_favorites"`
+	KeepAlbums          []string `yaml:"keep_albums"`
+	MinFreeGB           *float64 `yaml:"min_free_gb"`
+	AllowDelete         *bool    `yaml:"allow_delete"`
+	SkipDuplicates      *bool    `yaml:"skip_duplicates"`
+	Sniff               *bool    `yaml:"sniff"`
+	FixExtensions       *bool    `yaml:"fix_extensions"`
+	Takeout             *bool    `yaml:"takeout"`
+	SetModTime          *bool    `yaml:"set_mtime"`
+	ReserveGB           *float64 `yaml:"reserve_gb"`
+	TrimToSpace         *bool    `yaml:"trim_to_space"`
+	Trash               string   `yaml:"trash"`
+	QuarantineDir       string   `yaml:"quarantine_dir"`
+	TrashRetentionDays  *int     `yaml:"trash_retention_days"`
+}
+
+// ValidationError lists all problems found in a config file
+type ValidationError struct {
+	File     string
+	Problems []string
+}
+
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("invalid config %s:\n  %s", e.File, strings.Join(e.Problems, "\n  "))
+}
+
+// DefaultPath returns the path of the config file used if none is given explicitly.
+// It is taken from the COPY_IMAGES_CONFIG environment variable or the user config dir.
+func DefaultPath() string {
+	if configFile := os.Getenv(EnvConfigFile); configFile != "" {
+		return configFile
+	}
+	configDir, err := os.UserConfigDir()
+	if err != nil {
+		return "copy-images.yaml"
+	}
+	return filepath.Join(configDir, "copy-images", "config.yaml")
+}
+
+// Load reads and validates the config file. Unknown keys and bad values are reported as *ValidationError
+func Load(configFile string) (*Config, error) {
+	input, err := ioutil.ReadFile(configFile)
+	if err != nil {
+		return nil, err
+	}
+	config, problems := parse(input)
+	if len(problems) > 0 {
+		return nil, &ValidationError{File: configFile, Problems: problems}
+	}
+	return config, nil
+}
+
+// Profile returns the profile with the given name
+func (c *Config) Profile(name string) (Profile, error) {
+	profile, ok := c.Profiles[name]
+	if !ok {
+		return Profile{}, fmt.Errorf("unknown profile %q, known profiles: %s", name, strings.Join(c.ProfileNames(), ", "))
+	}
+	return profile, nil
+}
+
+// ProfileNames returns the sorted names of all profiles
+func (c *Config) ProfileNames() []string {
+	names := make([]string, 0, len(c.Profiles))
+	for name := range c.Profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// parse decodes the input rejecting unknown keys and validates all values
+func parse(input []byte) (*Config, []string) {
+	var config Config
+	var problems []string
+
+	decoder := yaml.NewDecoder(bytes.NewReader(input))
+	decoder.KnownFields(true)
+	err := decoder.Decode(&config)
+	var typeErr *yaml.TypeError
+	switch {
+	case errors.As(err, &typeErr):
+		problems = append(problems, typeErr.Errors...)
+	case err == io.EOF:
+		problems = append(problems, "config file is empty")
+	case err != nil:
+		return nil, []string{err.Error()}
+	}
+
+	for _, name := range config.ProfileNames() {
+		for _, problem := range config.Profiles[name].validate() {
+			problems = append(problems, fmt.Sprintf("profile %s: %s", name, problem))
+		}
+	}
+	return &config, problems
+}
+
+// validate returns all problems of the profile values
+func (p Profile) validate() []string {
+	var problems []string
+	if p.CutoffMonths != nil && *p.CutoffMonths < 0 {
+		problems = append(problems, fmt.Sprintf("cutoff_months must not be negative, got %d", *p.CutoffMonths))
+	}
+	if p.MinFreeGB != nil && *p.MinFreeGB < 0 {
+		problems = append(problems, fmt.Sprintf("min_free_gb must not be negative, got %g", *p.MinFreeGB))
+	}
+	if p.ReserveGB != nil && *p.ReserveGB < 0 {
+		problems = append(problems, fmt.Sprintf("reserve_gb must not be negative, got %g", *p.ReserveGB))
+	}
+	for _, extension := range p.SupportedExtensions {
+		if !strings.HasPrefix(extension, ".") || len(extension) < 2 {
+			problems = append(problems, fmt.Sprintf("supported_extensions: %q must start with a dot", extension))
+		} else if extension != strings.ToLower(extension) {
+			problems = append(problems, fmt.Sprintf("supported_extensions: %q must be lower case", extension))
+		}
+	}
+	if _, err := dates.NewChain(p.DateSources); err != nil {
+		problems = append(problems, "date_sources: "+err.Error())
+	}
+	if !utils.IsBelow(p.VideosDir) {
+		problems = append(problems, fmt.Sprintf("videos_dir: %q must be relative to the target", p.VideosDir))
+	}
+	if p.Layout != "" {
+		if _, err := layout.Parse(p.Layout); err != nil {
+			problems = append(problems, "layout: "+err.Error())
+		}
+	}
+	if p.Trash != "" {
+		if _, err := trash.ParseMode(p.Trash); err != nil {
+			problems = append(problems, "trash: "+err.Error())
+		}
+	}
+	if p.TrashRetentionDays != nil && *p.TrashRetentionDays < 0 {
+		problems = append(problems, fmt.Sprintf("trash_retention_days must not be negative, got %d", *p.TrashRetentionDays))
+	}
+	for _, excludedDir := range p.ExcludedDirs {
+		if strings.TrimSpace(excludedDir) == "" {
+			problems = append(problems, "excluded_dirs: entries must not be empty")
+		} else if _, err := ignore.Compile([]string{excludedDir}); err != nil {
+			problems = append(problems, "excluded_dirs: "+err.Error())
+		}
+	}
+	return problems
+}
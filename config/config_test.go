@@ -0,0 +1,109 @@
+package config_test
+
+import (
+	"copy-images/config"
+	"errors"
+	"io/ioutil"
+	"path"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// writeConfig writes the given content to a config file in a temp dir and returns its path
+func writeConfig(t *testing.T, content string) string {
+	configFile := path.Join(t.TempDir(), "config.yaml")
+	err := ioutil.WriteFile(configFile, []byte(content), 0644)
+	assert.Nil(t, err)
+	return configFile
+}
+
+func TestThatProfilesAreLoaded(t *testing.T) {
+
+	//GIVEN
+	configFile := writeConfig(t, `
+profiles:
+  pixel6:
+    source: /media/pixel6/DCIM
+    target: /mnt/nas/photos
+    excluded_dirs: [".thumbnails"]
+    supported_extensions: [".jpg", ".png"]
+    cutoff_months: 3
+    date_sources: [filename, mtime]
+  camera:
+    source: /media/sdcard
+    allow_delete: false
+`)
+
+	//WHEN
+	cfg, err := config.Load(configFile)
+
+	//THEN
+	assert.Nil(t, err, "No error must be thrown")
+	assert.Equal(t, []string{"camera", "pixel6"}, cfg.ProfileNames())
+	pixel6, err := cfg.Profile("pixel6")
+	assert.Nil(t, err)
+	assert.Equal(t, "/media/pixel6/DCIM", pixel6.Source)
+	assert.Equal(t, "/mnt/nas/photos", pixel6.Target)
+	assert.Equal(t, 3, *pixel6.CutoffMonths)
+	assert.Nil(t, pixel6.AllowDelete, "Unset values must stay nil")
+	assert.Equal(t, []string{".thumbnails"}, pixel6.ExcludedDirs)
+	assert.Equal(t, []string{".jpg", ".png"}, pixel6.SupportedExtensions)
+	assert.Equal(t, []string{"filename", "mtime"}, pixel6.DateSources)
+	camera, _ := cfg.Profile("camera")
+	assert.False(t, *camera.AllowDelete)
+
+}
+
+func TestThatUnknownProfileIsReported(t *testing.T) {
+
+	//GIVEN
+	configFile := writeConfig(t, "profiles:\n  pixel6:\n    source: /media\n")
+	cfg, _ := config.Load(configFile)
+
+	//WHEN
+	_, err := cfg.Profile("iphone")
+
+	//THEN
+	assert.NotNil(t, err, "Unknown profile must be reported")
+	assert.Contains(t, err.Error(), "pixel6", "Known profiles must be listed")
+
+}
+
+func TestThatUnknownKeysAndBadValuesAreReported(t *testing.T) {
+
+	//GIVEN
+	configFile := writeConfig(t, `
+profiles:
+  pixel6:
+    sources: /media/pixel6/DCIM
+    supported_extensions: ["jpg", ".PNG"]
+    excluded_dirs: [""]
+    cutoff_months: -2
+    date_sources: [exif, gps]
+`)
+
+	//WHEN
+	_, err := config.Load(configFile)
+
+	//THEN
+	var validationErr *config.ValidationError
+	assert.True(t, errors.As(err, &validationErr), "A validation error must be returned")
+	assert.Equal(t, 6, len(validationErr.Problems), "All problems must be reported")
+	assert.Contains(t, validationErr.Problems[0], "field sources not found")
+
+}
+
+func TestThatEmptyConfigIsReported(t *testing.T) {
+
+	//GIVEN
+	configFile := writeConfig(t, "")
+
+	//WHEN
+	_, err := config.Load(configFile)
+
+	//THEN
+	var validationErr *config.ValidationError
+	assert.True(t, errors.As(err, &validationErr), "A validation error must be returned")
+
+}
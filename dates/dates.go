@@ -0,0 +1,153 @@
+// Package dates determines the creation date of a file by consulting several date sources in a configured order
+package dates
+
+import (
+	"copy-images/exif"
+	"copy-images/model"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// ConflictTolerance is the maximum difference between two dates of a file which is not reported as a conflict
+const ConflictTolerance = time.Minute
+
+// Resolver determines the creation date of a file from a single source
+type Resolver interface {
+	// Source names the source the dates of this resolver are taken from
+	Source() model.DateSource
+	// Resolve returns the creation date of the file and false if the source does not know it
+	Resolve(path string, info os.FileInfo) (time.Time, bool)
+}
+
+// DefaultOrder is the order of the date sources used if none is configured
+var DefaultOrder = []model.DateSource{model.ExifSource, model.ContainerSource, model.FilenameSource, model.SidecarSource, model.ModTimeSource}
+
+// TakeoutOrder is the order used for Google Takeout exports if none is configured. Their json sidecars know the capture
+// time best, the modification time is the time of the export and is only used as last resort without being consulted,
+// so that it is not reported as conflict of every file.
+var TakeoutOrder = []model.DateSource{model.SidecarSource, model.ExifSource, model.ContainerSource, model.FilenameSource}
+
+// Chain consults its resolvers in order, the first one knowing a date wins
+type Chain []Resolver
+
+// Resolution is the result of resolving the date of a file
+type Resolution struct {
+	Date      time.Time
+	Source    model.DateSource
+	Conflicts []model.DateConflict
+	// CameraModel is the camera recorded in the EXIF data of the file, it is empty if the file has none
+	CameraModel string
+}
+
+// NewResolver creates the resolver of the named source
+func NewResolver(source model.DateSource) (Resolver, error) {
+	switch source {
+	case model.ExifSource:
+		return ExifResolver{}, nil
+	case model.ContainerSource:
+		return ContainerResolver{}, nil
+	case model.FilenameSource:
+		return FilenameResolver{}, nil
+	case model.SidecarSource:
+		return SidecarResolver{}, nil
+	case model.ModTimeSource:
+		return ModTimeResolver{}, nil
+	}
+	return nil, fmt.Errorf("unknown date source %q, known sources: %s", source, strings.Join(SourceNames(), ", "))
+}
+
+// SourceNames returns the names of all known date sources
+func SourceNames() []string {
+	return []string{string(model.ExifSource), string(model.ContainerSource), string(model.FilenameSource), string(model.SidecarSource), string(model.ModTimeSource)}
+}
+
+// NewChain creates a chain consulting the named sources in the given order
+func NewChain(sources []string) (Chain, error) {
+	chain := make(Chain, 0, len(sources))
+	seen := make(map[model.DateSource]bool)
+	for _, name := range sources {
+		source := model.DateSource(strings.ToLower(strings.TrimSpace(name)))
+		if seen[source] {
+			return nil, fmt.Errorf("date source %q is listed twice", source)
+		}
+		seen[source] = true
+		resolver, err := NewResolver(source)
+		if err != nil {
+			return nil, err
+		}
+		chain = append(chain, resolver)
+	}
+	return chain, nil
+}
+
+// DefaultChain returns the chain consulting the DefaultOrder
+func DefaultChain() Chain {
+	return chainOf(DefaultOrder)
+}
+
+// TakeoutChain returns the chain consulting the TakeoutOrder
+func TakeoutChain() Chain {
+	return chainOf(TakeoutOrder)
+}
+
+// chainOf creates the chain consulting the known sources in the given order
+func chainOf(order []model.DateSource) Chain {
+	chain := make(Chain, 0, len(order))
+	for _, source := range order {
+		resolver, _ := NewResolver(source)
+		chain = append(chain, resolver)
+	}
+	return chain
+}
+
+// WithSidecar returns a copy of the chain whose SidecarResolver reads the given json sidecar
+func (c Chain) WithSidecar(path string) Chain {
+	chain := make(Chain, len(c))
+	for i, resolver := range c {
+		if _, ok := resolver.(SidecarResolver); ok {
+			resolver = SidecarResolver{Path: path}
+		}
+		chain[i] = resolver
+	}
+	return chain
+}
+
+// Resolve consults all resolvers of the chain. The first date found wins, all later dates differing by more than
+// the ConflictTolerance are reported as conflicts. If no resolver knows a date the modification time is used.
+// The EXIF data of the file is decoded once, it provides the date of the ExifResolver and the camera model.
+func (c Chain) Resolve(path string, info os.FileInfo) Resolution {
+	var resolution Resolution
+	x, exifErr := exif.ReadFile(path)
+	if exifErr == nil {
+		resolution.CameraModel = x.Camera()
+	}
+	found := false
+	for _, resolver := range c {
+		var date time.Time
+		var ok bool
+		if exifResolver, isExif := resolver.(ExifResolver); isExif {
+			date, ok = exifResolver.resolveExif(x, exifErr)
+		} else {
+			date, ok = resolver.Resolve(path, info)
+		}
+		if !ok {
+			continue
+		}
+		if !found {
+			resolution.Date = date
+			resolution.Source = resolver.Source()
+			found = true
+			continue
+		}
+		if difference := date.Sub(resolution.Date); difference > ConflictTolerance || difference < -ConflictTolerance {
+			resolution.Conflicts = append(resolution.Conflicts, model.DateConflict{Source: resolver.Source(), Date: date})
+		}
+	}
+	if !found {
+		resolution.Date = info.ModTime()
+		resolution.Source = model.ModTimeSource
+	}
+	return resolution
+}
@@ -0,0 +1,183 @@
+package dates_test
+
+import (
+	"copy-images/dates"
+	"copy-images/model"
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// createFile creates an empty file with the given modification time in the dir
+func createFile(t *testing.T, dir string, name string, modTime time.Time) (string, os.FileInfo) {
+	filePath := path.Join(dir, name)
+	assert.Nil(t, ioutil.WriteFile(filePath, []byte{}, 0644))
+	assert.Nil(t, os.Chtimes(filePath, modTime, modTime))
+	info, err := os.Stat(filePath)
+	assert.Nil(t, err)
+	return filePath, info
+}
+
+func TestThatDatesAreParsedFromFilenames(t *testing.T) {
+
+	//GIVEN
+	var names = map[string]time.Time{
+		"IMG_20210303_141516.jpg":        time.Date(2021, time.March, 3, 14, 15, 16, 0, time.Local),
+		"VID_20201224_080000.mp4":        time.Date(2020, time.December, 24, 8, 0, 0, 0, time.Local),
+		"PXL_20210303_141516123.jpg":     time.Date(2021, time.March, 3, 14, 15, 16, 123000000, time.Local),
+		"IMG-20210303-WA0001.jpg":        time.Date(2021, time.March, 3, 0, 0, 0, 0, time.Local),
+		"Screenshot_20210303-141516.png": time.Date(2021, time.March, 3, 14, 15, 16, 0, time.Local),
+		"2021-03-03 14.15.16.jpg":        time.Date(2021, time.March, 3, 14, 15, 16, 0, time.Local),
+	}
+
+	for name, expected := range names {
+		//WHEN
+		date, ok := dates.ParseFilename(name)
+
+		//THEN
+		assert.True(t, ok, "Date must be found in %s", name)
+		assert.Equal(t, expected, date, "Wrong date parsed from %s", name)
+	}
+
+}
+
+func TestThatFilenamesWithoutDatesAreIgnored(t *testing.T) {
+
+	//GIVEN
+	var names = []string{"test.jpg", "IMG_1234.jpg", "IMG_20211350_141516.jpg", "DSC_123456789012345.jpg"}
+
+	for _, name := range names {
+		//WHEN
+		_, ok := dates.ParseFilename(name)
+
+		//THEN
+		assert.False(t, ok, "No date must be found in %s", name)
+	}
+
+}
+
+func TestThatSidecarDateIsRead(t *testing.T) {
+
+	//GIVEN
+	tempDir := t.TempDir()
+	imagePath, info := createFile(t, tempDir, "IMG_1.jpg", time.Now())
+	sidecar := `{"title": "IMG_1.jpg", "photoTakenTime": {"timestamp": "1614780916", "formatted": "03.03.2021, 14:15:16 UTC"}}`
+	ioutil.WriteFile(imagePath+".json", []byte(sidecar), 0644)
+
+	//WHEN
+	date, ok := dates.SidecarResolver{}.Resolve(imagePath, info)
+
+	//THEN
+	assert.True(t, ok, "Sidecar date must be found")
+	assert.Equal(t, time.Unix(1614780916, 0), date)
+
+}
+
+func TestThatTheSidecarOfATakeoutDuplicateIsRead(t *testing.T) {
+
+	//GIVEN
+	tempDir := t.TempDir()
+	imagePath, info := createFile(t, tempDir, "IMG_1(1).jpg", time.Now())
+	ioutil.WriteFile(path.Join(tempDir, "IMG_1.jpg.json"), []byte(`{"photoTakenTime": {"timestamp": "1614780916"}}`), 0644)
+	ioutil.WriteFile(path.Join(tempDir, "IMG_1.jpg(1).json"), []byte(`{"photoTakenTime": {"timestamp": "1614867316"}}`), 0644)
+	ioutil.WriteFile(path.Join(tempDir, "cut.json"), []byte(`{"photoTakenTime": {"timestamp": "1614953716"}}`), 0644)
+
+	//WHEN
+	date, ok := dates.SidecarResolver{}.Resolve(imagePath, info)
+	chain := dates.Chain{dates.SidecarResolver{}, dates.ModTimeResolver{}}.WithSidecar(path.Join(tempDir, "cut.json"))
+	resolution := chain.Resolve(imagePath, info)
+
+	//THEN
+	assert.True(t, ok, "Sidecar date must be found")
+	assert.Equal(t, time.Unix(1614867316, 0), date)
+	assert.Equal(t, time.Unix(1614953716, 0), resolution.Date, "The given sidecar must be read")
+	assert.Equal(t, model.SidecarSource, resolution.Source)
+
+}
+
+func TestThatFirstSourceWinsAndConflictsAreRecorded(t *testing.T) {
+
+	//GIVEN
+	tempDir := t.TempDir()
+	modTime := time.Date(2021, time.August, 29, 14, 57, 54, 0, time.Local)
+	filePath, info := createFile(t, tempDir, "IMG_20210303_141516.jpg", modTime)
+	chain, err := dates.NewChain([]string{"exif", "filename", "mtime"})
+	assert.Nil(t, err)
+
+	//WHEN
+	resolution := chain.Resolve(filePath, info)
+
+	//THEN
+	assert.Equal(t, model.FilenameSource, resolution.Source, "The empty file has no exif so the filename must win")
+	assert.Equal(t, time.March, resolution.Date.Month())
+	assert.Equal(t, 1, len(resolution.Conflicts), "The modification time must be reported as conflict")
+	assert.Equal(t, model.ModTimeSource, resolution.Conflicts[0].Source)
+	assert.Equal(t, modTime, resolution.Conflicts[0].Date)
+
+}
+
+func TestThatAgreeingSourcesAreNoConflict(t *testing.T) {
+
+	//GIVEN
+	tempDir := t.TempDir()
+	modTime := time.Date(2021, time.March, 3, 14, 15, 40, 0, time.Local)
+	filePath, info := createFile(t, tempDir, "IMG_20210303_141516.jpg", modTime)
+	chain, _ := dates.NewChain([]string{"filename", "mtime"})
+
+	//WHEN
+	resolution := chain.Resolve(filePath, info)
+
+	//THEN
+	assert.Empty(t, resolution.Conflicts, "Dates within the tolerance must not conflict")
+
+}
+
+func TestThatModTimeIsUsedIfNoSourceKnowsADate(t *testing.T) {
+
+	//GIVEN
+	tempDir := t.TempDir()
+	modTime := time.Date(2021, time.August, 29, 14, 57, 54, 0, time.Local)
+	filePath, info := createFile(t, tempDir, "test.jpg", modTime)
+	chain, _ := dates.NewChain([]string{"filename"})
+
+	//WHEN
+	resolution := chain.Resolve(filePath, info)
+
+	//THEN
+	assert.Equal(t, model.ModTimeSource, resolution.Source)
+	assert.Equal(t, modTime, resolution.Date)
+
+}
+
+func TestThatUnknownOrDuplicateSourcesAreRejected(t *testing.T) {
+
+	//WHEN
+	_, unknownErr := dates.NewChain([]string{"exif", "gps"})
+	_, duplicateErr := dates.NewChain([]string{"exif", "EXIF"})
+
+	//THEN
+	assert.NotNil(t, unknownErr)
+	assert.NotNil(t, duplicateErr)
+
+}
+
+func TestThatTheExifDateAndCameraAreResolvedTogether(t *testing.T) {
+
+	//GIVEN
+	filePath := "../exif/testdata/datetime_original.orf"
+	info, err := os.Stat(filePath)
+	assert.Nil(t, err)
+
+	//WHEN
+	resolution := dates.DefaultChain().Resolve(filePath, info)
+
+	//THEN
+	assert.Equal(t, model.ExifSource, resolution.Source)
+	assert.Equal(t, time.Date(2021, time.June, 12, 10, 20, 30, 0, time.Local), resolution.Date)
+	assert.Equal(t, "OLYMPUS IMAGING CORP. E-M5", resolution.CameraModel)
+
+}
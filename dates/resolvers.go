@@ -0,0 +1,135 @@
+package dates
+
+import (
+	"copy-images/exif"
+	"copy-images/model"
+	"copy-images/mp4"
+	"copy-images/takeout"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"time"
+)
+
+// ExifResolver reads the DateTimeOriginal of JPEG, TIFF and camera RAW files
+type ExifResolver struct{}
+
+// Source implements Resolver
+func (ExifResolver) Source() model.DateSource {
+	return model.ExifSource
+}
+
+// Resolve implements Resolver
+func (r ExifResolver) Resolve(path string, info os.FileInfo) (time.Time, bool) {
+	return r.resolveExif(exif.ReadFile(path))
+}
+
+// resolveExif returns the date of EXIF data which has already been decoded, the Chain decodes it only once per file
+func (ExifResolver) resolveExif(x *exif.Exif, err error) (time.Time, bool) {
+	if err != nil {
+		return time.Time{}, false
+	}
+	date, err := x.CaptureTime()
+	return date, err == nil
+}
+
+// ContainerResolver reads the creation time of the movie header of MP4, MOV, 3GP and M4V files
+type ContainerResolver struct{}
+
+// Source implements Resolver
+func (ContainerResolver) Source() model.DateSource {
+	return model.ContainerSource
+}
+
+// Resolve implements Resolver
+func (ContainerResolver) Resolve(path string, info os.FileInfo) (time.Time, bool) {
+	date, err := mp4.CreationTime(path)
+	return date, err == nil
+}
+
+// ModTimeResolver uses the modification time of the file
+type ModTimeResolver struct{}
+
+// Source implements Resolver
+func (ModTimeResolver) Source() model.DateSource {
+	return model.ModTimeSource
+}
+
+// Resolve implements Resolver
+func (ModTimeResolver) Resolve(path string, info os.FileInfo) (time.Time, bool) {
+	return info.ModTime(), true
+}
+
+// filenamePatterns match the dates phones and cameras put into file names, the groups are year, month, day and
+// optionally hour, minute, second and milliseconds
+var filenamePatterns = []*regexp.Regexp{
+	// IMG_20210303_141516.jpg, VID_20210303_141516.mp4, PXL_20210303_141516123.jpg, 20210303_141516.jpg, Screenshot_20210303-141516.png
+	regexp.MustCompile(`(?:^|[^0-9])((?:19|20)\d{2})(\d{2})(\d{2})[_-](\d{2})(\d{2})(\d{2})(\d{3})?(?:[^0-9]|$)`),
+	// 2021-03-03 14.15.16.jpg as written by dropbox camera uploads
+	regexp.MustCompile(`(?:^|[^0-9])((?:19|20)\d{2})-(\d{2})-(\d{2})[ _](\d{2})\.(\d{2})\.(\d{2})()(?:[^0-9]|$)`),
+	// IMG-20210303-WA0001.jpg as written by WhatsApp, only the day is known
+	regexp.MustCompile(`(?:^|[^0-9])((?:19|20)\d{2})(\d{2})(\d{2})-WA\d+`),
+}
+
+// FilenameResolver parses dates out of well known file name patterns. The dates are interpreted in the local time zone.
+type FilenameResolver struct{}
+
+// Source implements Resolver
+func (FilenameResolver) Source() model.DateSource {
+	return model.FilenameSource
+}
+
+// Resolve implements Resolver
+func (FilenameResolver) Resolve(path string, info os.FileInfo) (time.Time, bool) {
+	return ParseFilename(filepath.Base(path))
+}
+
+// ParseFilename returns the date encoded in the given file name
+func ParseFilename(name string) (time.Time, bool) {
+	for _, pattern := range filenamePatterns {
+		match := pattern.FindStringSubmatch(name)
+		if match == nil {
+			continue
+		}
+		numbers := make([]int, 7)
+		for i, group := range match[1:] {
+			numbers[i], _ = strconv.Atoi(group)
+		}
+		date := time.Date(numbers[0], time.Month(numbers[1]), numbers[2], numbers[3], numbers[4], numbers[5], numbers[6]*int(time.Millisecond), time.Local)
+		// time.Date normalizes invalid values like month 13, such names are no dates
+		if date.Year() != numbers[0] || int(date.Month()) != numbers[1] || date.Day() != numbers[2] || date.Hour() != numbers[3] || date.Minute() != numbers[4] || date.Second() != numbers[5] {
+			continue
+		}
+		return date, true
+	}
+	return time.Time{}, false
+}
+
+// SidecarResolver reads the photoTakenTime of the google photos json sidecar of a file, like IMG_1.jpg.json or
+// IMG_1.json, see takeout.Candidates
+type SidecarResolver struct {
+	// Path is the json sidecar to read, by default the candidates next to the file are tried
+	Path string
+}
+
+// Source implements Resolver
+func (SidecarResolver) Source() model.DateSource {
+	return model.SidecarSource
+}
+
+// Resolve implements Resolver
+func (r SidecarResolver) Resolve(path string, info os.FileInfo) (time.Time, bool) {
+	candidates := takeout.Candidates(path)
+	if r.Path != "" {
+		candidates = []string{r.Path}
+	}
+	for _, candidate := range candidates {
+		metadata, err := takeout.Read(candidate)
+		if err != nil || metadata.TakenTime.IsZero() {
+			continue
+		}
+		return metadata.TakenTime, true
+	}
+	return time.Time{}, false
+}
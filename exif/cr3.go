@@ -0,0 +1,93 @@
+package exif
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+)
+
+// canonUUID identifies the box of a Canon CR3 file holding the tiff structures of its metadata
+var canonUUID = []byte{0x85, 0xc0, 0xb6, 0x87, 0x82, 0x0f, 0x11, 0xe0, 0x81, 0x11, 0xf4, 0xce, 0x46, 0x2b, 0x6a, 0x48}
+
+// box is a box of the ISO base media file format CR3 files are stored in
+type box struct {
+	boxType string
+	// offset is the start of the payload
+	offset int64
+	// size is the size of the payload
+	size int64
+}
+
+// isCR3Header checks for the file type box of a Canon CR3 file
+func isCR3Header(header []byte) bool {
+	return len(header) >= 12 && string(header[4:8]) == "ftyp" && string(header[8:12]) == "crx "
+}
+
+// decodeCR3 decodes the CMT1 box holding IFD0 and the CMT2 box holding the exif ifd of a CR3 file. Both are complete
+// tiff structures inside the Canon uuid box of the movie box.
+func decodeCR3(r io.ReaderAt) (*Exif, error) {
+	moov, ok := findBox(r, 0, -1, func(b box) bool { return b.boxType == "moov" })
+	if !ok {
+		return nil, ErrNoExif
+	}
+	canon, ok := findBox(r, moov.offset, moov.offset+moov.size, func(b box) bool {
+		id := make([]byte, len(canonUUID))
+		_, err := r.ReadAt(id, b.offset)
+		return b.boxType == "uuid" && err == nil && bytes.Equal(id, canonUUID)
+	})
+	if !ok {
+		return nil, ErrNoExif
+	}
+	start, end := canon.offset+int64(len(canonUUID)), canon.offset+canon.size
+	cmt1, ok := findBox(r, start, end, func(b box) bool { return b.boxType == "CMT1" })
+	if !ok {
+		return nil, ErrNoExif
+	}
+	x, err := decodeTIFF(io.NewSectionReader(r, cmt1.offset, cmt1.size))
+	if err != nil {
+		return nil, err
+	}
+	if cmt2, ok := findBox(r, start, end, func(b box) bool { return b.boxType == "CMT2" }); ok {
+		t, exifIFD, err := readTIFF(io.NewSectionReader(r, cmt2.offset, cmt2.size))
+		if err != nil {
+			return nil, err
+		}
+		t.decodeDates(exifIFD, x)
+	}
+	return x, nil
+}
+
+// findBox returns the first box between start and end matching the predicate, an end of -1 reads up to the end of r
+func findBox(r io.ReaderAt, start int64, end int64, matches func(b box) bool) (box, bool) {
+	header := make([]byte, 16)
+	for offset := start; end < 0 || offset+8 <= end; {
+		if _, err := r.ReadAt(header[:8], offset); err != nil {
+			return box{}, false
+		}
+		size := int64(binary.BigEndian.Uint32(header))
+		b := box{boxType: string(header[4:8]), offset: offset + 8}
+		switch size {
+		case 0:
+			// the box extends to the end
+			if end < 0 {
+				return box{}, false
+			}
+			size = end - offset
+		case 1:
+			if _, err := r.ReadAt(header[8:], offset+8); err != nil {
+				return box{}, false
+			}
+			size = int64(binary.BigEndian.Uint64(header[8:]))
+			b.offset += 8
+		}
+		b.size = offset + size - b.offset
+		if b.size < 0 || (end >= 0 && offset+size > end) {
+			return box{}, false
+		}
+		if matches(b) {
+			return b, true
+		}
+		offset += size
+	}
+	return box{}, false
+}
@@ -0,0 +1,311 @@
+// Package exif is a minimal reader for the EXIF metadata of JPEG and TIFF files and of the camera RAW formats based
+// on them, like CR2, NEF, ARW, DNG, ORF, RW2 and CR3. It only decodes the tags copy-images needs to sort the files.
+package exif
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+)
+
+// ErrNoExif is returned if the file does not contain any exif data
+var ErrNoExif = errors.New("exif: no exif data found")
+
+// ErrNoDate is returned if the exif data does not contain a DateTimeOriginal tag
+var ErrNoDate = errors.New("exif: no DateTimeOriginal found")
+
+// tag ids of all tags read by this package
+const (
+	tagMake               = 0x010F
+	tagModel              = 0x0110
+	tagExifIFDPointer     = 0x8769
+	tagDateTimeOriginal   = 0x9003
+	tagOffsetTime         = 0x9010
+	tagOffsetTimeOriginal = 0x9011
+	tagSubSecTime         = 0x9290
+	tagSubSecTimeOriginal = 0x9291
+)
+
+// tiff field types
+const (
+	typeASCII = 2
+	typeShort = 3
+	typeLong  = 4
+)
+
+// maxEntries guards against corrupt ifds pretending to have a huge number of entries
+const maxEntries = 1024
+
+// Exif holds the decoded tags
+type Exif struct {
+	// Make is the manufacturer of the camera
+	Make string
+	// Model is the model name of the camera
+	Model string
+	// DateTimeOriginal is the raw "YYYY:MM:DD HH:MM:SS" value of the DateTimeOriginal tag
+	DateTimeOriginal string
+	// OffsetTime is the raw "+HH:MM" utc offset of DateTimeOriginal, falling back to OffsetTime
+	OffsetTime string
+	// SubSecTime is the raw fraction of a second of DateTimeOriginal, falling back to SubSecTime
+	SubSecTime string
+}
+
+// ReadFile decodes the exif data of the JPEG, TIFF or RAW file at the given path
+func ReadFile(path string) (*Exif, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return Decode(f)
+}
+
+// DateTimeOriginal returns the capture date of the file at the given path.
+// ErrNoExif or ErrNoDate are returned if the file does not carry one.
+func DateTimeOriginal(path string) (time.Time, error) {
+	x, err := ReadFile(path)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return x.CaptureTime()
+}
+
+// Decode decodes the exif data of a JPEG, TIFF or RAW file
+func Decode(r io.ReaderAt) (*Exif, error) {
+	header := make([]byte, 12)
+	n, err := r.ReadAt(header, 0)
+	if n < 4 {
+		if err == io.EOF {
+			return nil, ErrNoExif
+		}
+		return nil, err
+	}
+	header = header[:n]
+	switch {
+	case header[0] == 0xFF && header[1] == 0xD8:
+		payload, err := jpegExifPayload(r)
+		if err != nil {
+			return nil, err
+		}
+		return decodeTIFF(bytes.NewReader(payload))
+	case isTIFFHeader(header):
+		return decodeTIFF(r)
+	case isCR3Header(header):
+		return decodeCR3(r)
+	}
+	return nil, ErrNoExif
+}
+
+// CaptureTime combines DateTimeOriginal, OffsetTime and SubSecTime into a time.
+// Without an offset the time is interpreted in the local time zone as cameras record local time.
+func (x *Exif) CaptureTime() (time.Time, error) {
+	if x.DateTimeOriginal == "" {
+		return time.Time{}, ErrNoDate
+	}
+	location := time.Local
+	if x.OffsetTime != "" {
+		offset, err := time.Parse("-07:00", x.OffsetTime)
+		if err == nil {
+			_, seconds := offset.Zone()
+			location = time.FixedZone(x.OffsetTime, seconds)
+		}
+	}
+	date, err := time.ParseInLocation("2006:01:02 15:04:05", x.DateTimeOriginal, location)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("exif: invalid DateTimeOriginal %q: %w", x.DateTimeOriginal, err)
+	}
+	if x.SubSecTime != "" {
+		fraction, err := time.ParseDuration("0." + x.SubSecTime + "s")
+		if err == nil {
+			date = date.Add(fraction)
+		}
+	}
+	return date, nil
+}
+
+// Camera returns the camera name made of Make and Model. The make is omitted if the model already starts with it.
+func (x *Exif) Camera() string {
+	if x.Make == "" || strings.HasPrefix(strings.ToLower(x.Model), strings.ToLower(x.Make)) {
+		return x.Model
+	}
+	if x.Model == "" {
+		return x.Make
+	}
+	return x.Make + " " + x.Model
+}
+
+// tiffMagics are the starts of tiff structures, Olympus ORF and Panasonic RW2 files replace the magic number with
+// their own but are tiff structures otherwise
+var tiffMagics = []string{"II*\x00", "MM\x00*", "IIRO", "IIRS", "MMOR", "IIU\x00"}
+
+// isTIFFHeader checks for the little or big endian tiff magic
+func isTIFFHeader(header []byte) bool {
+	for _, magic := range tiffMagics {
+		if string(header[:4]) == magic {
+			return true
+		}
+	}
+	return false
+}
+
+// jpegExifPayload walks the jpeg segments up to the start of scan and returns the tiff payload of the exif APP1 segment
+func jpegExifPayload(r io.ReaderAt) ([]byte, error) {
+	var offset int64 = 2
+	marker := make([]byte, 4)
+	for {
+		if _, err := r.ReadAt(marker, offset); err != nil {
+			return nil, ErrNoExif
+		}
+		if marker[0] != 0xFF {
+			return nil, fmt.Errorf("exif: invalid jpeg marker at offset %d", offset)
+		}
+		// padding bytes before a marker
+		if marker[1] == 0xFF {
+			offset++
+			continue
+		}
+		// start of scan or end of image, no metadata follows
+		if marker[1] == 0xDA || marker[1] == 0xD9 {
+			return nil, ErrNoExif
+		}
+		length := int64(binary.BigEndian.Uint16(marker[2:]))
+		if length < 2 {
+			return nil, fmt.Errorf("exif: invalid jpeg segment length at offset %d", offset)
+		}
+		if marker[1] == 0xE1 && length > 8 {
+			segment := make([]byte, length-2)
+			if _, err := r.ReadAt(segment, offset+4); err != nil {
+				return nil, err
+			}
+			if bytes.HasPrefix(segment, []byte("Exif\x00\x00")) {
+				return segment[6:], nil
+			}
+		}
+		offset += 2 + length
+	}
+}
+
+// tiffReader reads ifds of a tiff structure
+type tiffReader struct {
+	r     io.ReaderAt
+	order binary.ByteOrder
+}
+
+// decodeTIFF decodes the tags of IFD0 and the exif sub ifd of a tiff structure starting at offset 0 of r
+func decodeTIFF(r io.ReaderAt) (*Exif, error) {
+	t, ifd0, err := readTIFF(r)
+	if err != nil {
+		return nil, err
+	}
+	x := &Exif{Make: t.ascii(ifd0, tagMake), Model: t.ascii(ifd0, tagModel)}
+	exifPointer, ok := ifd0[tagExifIFDPointer]
+	if !ok {
+		return x, nil
+	}
+	exifIFD, err := t.readIFD(int64(exifPointer.uint32(t.order)))
+	if err != nil {
+		return nil, err
+	}
+	t.decodeDates(exifIFD, x)
+	return x, nil
+}
+
+// readTIFF reads the header and IFD0 of a tiff structure starting at offset 0 of r
+func readTIFF(r io.ReaderAt) (tiffReader, map[uint16]entry, error) {
+	header := make([]byte, 8)
+	if _, err := r.ReadAt(header, 0); err != nil {
+		return tiffReader{}, nil, ErrNoExif
+	}
+	t := tiffReader{r: r}
+	switch string(header[:2]) {
+	case "II":
+		t.order = binary.LittleEndian
+	case "MM":
+		t.order = binary.BigEndian
+	default:
+		return t, nil, errors.New("exif: invalid tiff byte order")
+	}
+	ifd0, err := t.readIFD(int64(t.order.Uint32(header[4:])))
+	return t, ifd0, err
+}
+
+// decodeDates sets the capture date of x from the tags of an exif ifd
+func (t tiffReader) decodeDates(exifIFD map[uint16]entry, x *Exif) {
+	x.DateTimeOriginal = t.ascii(exifIFD, tagDateTimeOriginal)
+	x.OffsetTime = t.ascii(exifIFD, tagOffsetTimeOriginal)
+	if x.OffsetTime == "" {
+		x.OffsetTime = t.ascii(exifIFD, tagOffsetTime)
+	}
+	x.SubSecTime = t.ascii(exifIFD, tagSubSecTimeOriginal)
+	if x.SubSecTime == "" {
+		x.SubSecTime = t.ascii(exifIFD, tagSubSecTime)
+	}
+}
+
+// entry is a single raw ifd entry
+type entry struct {
+	fieldType uint16
+	count     uint32
+	value     []byte
+}
+
+// uint32 returns the numeric value of a SHORT or LONG entry
+func (e entry) uint32(order binary.ByteOrder) uint32 {
+	if e.fieldType == typeShort {
+		return uint32(order.Uint16(e.value))
+	}
+	return order.Uint32(e.value)
+}
+
+// readIFD reads all entries of the ifd at the given offset
+func (t tiffReader) readIFD(offset int64) (map[uint16]entry, error) {
+	countBytes := make([]byte, 2)
+	if _, err := t.r.ReadAt(countBytes, offset); err != nil {
+		return nil, fmt.Errorf("exif: cannot read ifd at offset %d: %w", offset, err)
+	}
+	count := int(t.order.Uint16(countBytes))
+	if count > maxEntries {
+		return nil, fmt.Errorf("exif: ifd at offset %d has too many entries", offset)
+	}
+	raw := make([]byte, 12*count)
+	if _, err := t.r.ReadAt(raw, offset+2); err != nil {
+		return nil, fmt.Errorf("exif: cannot read ifd at offset %d: %w", offset, err)
+	}
+	entries := make(map[uint16]entry, count)
+	for i := 0; i < count; i++ {
+		field := raw[i*12 : (i+1)*12]
+		tag := t.order.Uint16(field)
+		e := entry{fieldType: t.order.Uint16(field[2:]), count: t.order.Uint32(field[4:])}
+		switch e.fieldType {
+		case typeASCII:
+			if e.count <= 4 {
+				e.value = field[8 : 8+e.count]
+			} else if e.count <= 1<<16 {
+				e.value = make([]byte, e.count)
+				if _, err := t.r.ReadAt(e.value, int64(t.order.Uint32(field[8:]))); err != nil {
+					continue
+				}
+			}
+		case typeShort, typeLong:
+			e.value = field[8:12]
+		default:
+			continue
+		}
+		entries[tag] = e
+	}
+	return entries, nil
+}
+
+// ascii returns the trimmed string value of the given tag or an empty string
+func (t tiffReader) ascii(entries map[uint16]entry, tag uint16) string {
+	e, ok := entries[tag]
+	if !ok || e.fieldType != typeASCII {
+		return ""
+	}
+	return strings.TrimSpace(strings.TrimRight(string(e.value), "\x00"))
+}
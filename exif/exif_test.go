@@ -0,0 +1,167 @@
+package exif_test
+
+import (
+	"bytes"
+	"copy-images/exif"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestThatDateTimeOriginalIsReadFromJpeg(t *testing.T) {
+
+	//GIVEN
+	var testFile string = "testdata/datetime_original.jpg"
+
+	//WHEN
+	date, err := exif.DateTimeOriginal(testFile)
+
+	//THEN
+	assert.Nil(t, err, "No error must be thrown")
+	expected := time.Date(2019, time.July, 14, 18, 30, 5, 250000000, time.FixedZone("+02:00", 2*60*60))
+	assert.True(t, expected.Equal(date), "Offset and sub seconds must be applied, got %s", date)
+	_, offset := date.Zone()
+	assert.Equal(t, 2*60*60, offset)
+
+}
+
+func TestThatBigEndianExifIsRead(t *testing.T) {
+
+	//GIVEN
+	var testFile string = "testdata/big_endian.jpg"
+
+	//WHEN
+	date, err := exif.DateTimeOriginal(testFile)
+
+	//THEN
+	assert.Nil(t, err, "No error must be thrown")
+	assert.Equal(t, time.Date(2018, time.December, 24, 8, 15, 0, 0, time.Local), date, "Without offset the local time zone must be used")
+
+}
+
+func TestThatDateTimeOriginalIsReadFromTiff(t *testing.T) {
+
+	//GIVEN
+	var testFile string = "testdata/datetime_original.tif"
+
+	//WHEN
+	x, err := exif.ReadFile(testFile)
+
+	//THEN
+	assert.Nil(t, err, "No error must be thrown")
+	assert.Equal(t, "2020:02:29 23:59:59", x.DateTimeOriginal)
+	assert.Equal(t, "-05:00", x.OffsetTime, "OffsetTime must be used if OffsetTimeOriginal is missing")
+	date, _ := x.CaptureTime()
+	assert.Equal(t, time.March, date.UTC().Month())
+
+}
+
+func TestThatMissingExifIsReported(t *testing.T) {
+
+	//GIVEN
+	var testFile string = "testdata/no_exif.jpg"
+
+	//WHEN
+	_, err := exif.DateTimeOriginal(testFile)
+
+	//THEN
+	assert.Equal(t, exif.ErrNoExif, err)
+
+}
+
+func TestThatEmptyAndForeignFilesHaveNoExif(t *testing.T) {
+
+	//GIVEN
+	var emptyFile = bytes.NewReader([]byte{})
+	var pngFile = bytes.NewReader([]byte("\x89PNG\r\n\x1a\n"))
+
+	//WHEN
+	_, emptyErr := exif.Decode(emptyFile)
+	_, pngErr := exif.Decode(pngFile)
+
+	//THEN
+	assert.Equal(t, exif.ErrNoExif, emptyErr)
+	assert.Equal(t, exif.ErrNoExif, pngErr)
+
+}
+
+func TestThatMissingDateIsReported(t *testing.T) {
+
+	//GIVEN
+	var x = exif.Exif{}
+
+	//WHEN
+	_, err := x.CaptureTime()
+
+	//THEN
+	assert.Equal(t, exif.ErrNoDate, err)
+
+}
+
+func TestThatTruncatedJpegIsNoPanic(t *testing.T) {
+
+	//GIVEN
+	var truncated = bytes.NewReader([]byte{0xFF, 0xD8, 0xFF, 0xE1, 0x00, 0x40, 'E', 'x', 'i', 'f', 0, 0, 'I', 'I'})
+
+	//WHEN
+	_, err := exif.Decode(truncated)
+
+	//THEN
+	assert.NotNil(t, err, "A truncated file must be reported")
+
+}
+
+func TestThatCameraCombinesMakeAndModel(t *testing.T) {
+
+	//GIVEN
+	var pixel = exif.Exif{Make: "Google", Model: "Pixel 6"}
+	var canon = exif.Exif{Make: "Canon", Model: "Canon EOS 5D Mark IV"}
+
+	//WHEN
+	pixelCamera := pixel.Camera()
+	canonCamera := canon.Camera()
+
+	//THEN
+	assert.Equal(t, "Google Pixel 6", pixelCamera)
+	assert.Equal(t, "Canon EOS 5D Mark IV", canonCamera, "The make must not be repeated")
+
+}
+
+func TestThatDatesAreReadFromRawFiles(t *testing.T) {
+	tests := []struct {
+		file     string
+		camera   string
+		expected string
+	}{
+		{"testdata/datetime_original.orf", "OLYMPUS IMAGING CORP. E-M5", "2021:06:12 10:20:30"},
+		{"testdata/datetime_original.rw2", "Panasonic DC-G9", "2022:01:02 03:04:05"},
+		{"testdata/datetime_original.cr3", "Canon EOS R5", "2023:07:08 09:10:11"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.file, func(t *testing.T) {
+
+			//WHEN
+			x, err := exif.ReadFile(tt.file)
+
+			//THEN
+			assert.Nil(t, err, "No error must be thrown")
+			assert.Equal(t, tt.camera, x.Camera())
+			assert.Equal(t, tt.expected, x.DateTimeOriginal)
+
+		})
+	}
+}
+
+func TestThatCR3WithoutCanonBoxHasNoExif(t *testing.T) {
+
+	//GIVEN
+	var cr3 = bytes.NewReader([]byte("\x00\x00\x00\x18ftypcrx \x00\x00\x00\x01crx isom\x00\x00\x00\x08moov"))
+
+	//WHEN
+	_, err := exif.Decode(cr3)
+
+	//THEN
+	assert.Equal(t, exif.ErrNoExif, err)
+
+}
@@ -0,0 +1,14 @@
+//go:build !linux
+// +build !linux
+
+package file
+
+import (
+	"errors"
+	"os"
+)
+
+//cloneFile is only supported on linux
+func cloneFile(destination *os.File, source *os.File) error {
+	return errors.New("cloning files is not supported on this platform")
+}
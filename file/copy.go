@@ -0,0 +1,204 @@
+package file
+
+import (
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+)
+
+//DefaultBufferSize is the size of the buffer files are streamed with if no other size is configured
+const DefaultBufferSize = 1024 * 1024
+
+//CopyMethod selects how the content of a file is copied
+type CopyMethod string
+
+const (
+	// CopyAuto clones the file if the file system supports it, lets the kernel copy it if it can and streams it otherwise
+	CopyAuto CopyMethod = "auto"
+	// CopyStream always streams the content through a buffer in user space
+	CopyStream CopyMethod = "stream"
+)
+
+//ParseCopyMethod returns the CopyMethod of the given name, an empty name is CopyAuto
+func ParseCopyMethod(name string) (CopyMethod, error) {
+	switch CopyMethod(name) {
+	case "", CopyAuto:
+		return CopyAuto, nil
+	case CopyStream:
+		return CopyStream, nil
+	}
+	return "", fmt.Errorf("unknown copy method %q, known methods: %s, %s", name, CopyAuto, CopyStream)
+}
+
+//TempFilePrefix starts the names of the hidden files copies are written to before they are put into place. It is
+//followed by the id of the run and a "-" if the run has one.
+const TempFilePrefix = ".copy-images-tmp-"
+
+//staleTempFileAge is the time after which a temp file which is not written to any more is taken as left by a run
+//which died, a run still copying it writes to it continuously
+const staleTempFileAge = time.Hour
+
+//ErrDestinationExists is returned by copies whose destination already exists, an existing file is never overwritten
+var ErrDestinationExists = errors.New("the destination already exists")
+
+//copyFile copies the content of from to the new file to without ever holding more than bufferSize bytes in memory.
+//If a digest is given the content is streamed through it, in that case the file is never cloned or copied by the kernel.
+//The content is written to a hidden temp file in the destination dir which is synced and published, so the
+//destination either does not exist or is complete, even if the process dies. An existing file at the destination is
+//not replaced, see publish. The name of the temp file holds the id of the run, so that resuming the run removes it
+//if the process dies.
+func copyFile(from string, to string, method CopyMethod, bufferSize int, digest hash.Hash, runID string) (int64, error) {
+	source, err := os.Open(from)
+	if err != nil {
+		return 0, err
+	}
+	defer source.Close()
+	temp, err := ioutil.TempFile(filepath.Dir(to), tempFilePrefix(runID))
+	if err != nil {
+		return 0, err
+	}
+	written, err := copyContent(temp, source, method, bufferSize, digest)
+	if err == nil {
+		err = temp.Chmod(0644)
+	}
+	if err == nil {
+		err = temp.Sync()
+	}
+	if closeErr := temp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = publish(temp.Name(), to)
+	}
+	if err != nil {
+		os.Remove(temp.Name())
+		return written, err
+	}
+	return written, syncDir(filepath.Dir(to))
+}
+
+//tempFilePrefix returns the start of the names of the temp files of the run
+func tempFilePrefix(runID string) string {
+	if runID == "" {
+		return TempFilePrefix
+	}
+	return TempFilePrefix + runID + "-"
+}
+
+//publish puts the complete temp file into place without replacing an existing destination. The temp file is hard
+//linked to the destination, which fails if it exists, and removed afterwards. File systems without hard links, like
+//FAT, get a copy of the temp file instead, see copyExclusive.
+func publish(temp string, to string) error {
+	err := os.Link(temp, to)
+	if err == nil {
+		//the copy is in place, a temp file which cannot be removed is left for CleanupTempFiles
+		os.Remove(temp)
+		return nil
+	}
+	if os.IsExist(err) {
+		return fmt.Errorf("%s: %w", to, ErrDestinationExists)
+	}
+	return copyExclusive(temp, to)
+}
+
+//copyExclusive copies the temp file to the destination, which is created exclusively so that an existing file is never
+//replaced. Unlike a link the destination is not complete until the copy returns, if the process dies it is left
+//incomplete for a resumed run to copy again, see Executor.resumePart.
+func copyExclusive(temp string, to string) error {
+	destination, err := os.OpenFile(to, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if os.IsExist(err) {
+		return fmt.Errorf("%s: %w", to, ErrDestinationExists)
+	}
+	if err != nil {
+		return err
+	}
+	source, err := os.Open(temp)
+	if err == nil {
+		_, err = destination.ReadFrom(source)
+		source.Close()
+	}
+	if err == nil {
+		err = destination.Sync()
+	}
+	if closeErr := destination.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(to)
+		return err
+	}
+	os.Remove(temp)
+	return nil
+}
+
+//syncDir makes a rename within the dir durable
+func syncDir(dir string) error {
+	//windows cannot sync directories, renames are durable there once they returned
+	if runtime.GOOS == "windows" {
+		return nil
+	}
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	return d.Sync()
+}
+
+//isTempFile checks if the file name is one of a temp file written by copyFile
+func isTempFile(name string) bool {
+	return strings.HasPrefix(name, TempFilePrefix)
+}
+
+//CleanupTempFiles removes the temp files left in the dir and its sub dirs by runs which died while copying: the ones
+//of the run with the given id, which is resumed, and the ones of other runs which have not been written to for
+//staleTempFileAge. The temp files of runs copying to the same dir at the same time are kept.
+//It returns the removed files, a dir which does not exist has nothing to clean up.
+func CleanupTempFiles(dir string, runID string) ([]string, error) {
+	var removed []string
+	stale := time.Now().Add(-staleTempFileAge)
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) && path == dir {
+				return filepath.SkipDir
+			}
+			return err
+		}
+		ownFile := runID != "" && strings.HasPrefix(info.Name(), tempFilePrefix(runID))
+		if info.Mode().IsRegular() && isTempFile(info.Name()) && (ownFile || info.ModTime().Before(stale)) {
+			if err := os.Remove(path); err != nil {
+				return err
+			}
+			removed = append(removed, path)
+		}
+		return nil
+	})
+	return removed, err
+}
+
+//copyContent copies the content of the open source to the open, empty destination
+func copyContent(destination *os.File, source *os.File, method CopyMethod, bufferSize int, digest hash.Hash) (int64, error) {
+	if method != CopyStream && digest == nil {
+		if info, err := source.Stat(); err == nil && cloneFile(destination, source) == nil {
+			return info.Size(), nil
+		}
+		//os.File.ReadFrom uses copy_file_range or sendfile where the platform supports it and streams otherwise
+		return destination.ReadFrom(source)
+	}
+	if bufferSize <= 0 {
+		bufferSize = DefaultBufferSize
+	}
+	var writer io.Writer = destination
+	if digest != nil {
+		writer = io.MultiWriter(destination, digest)
+	}
+	//hiding ReadFrom and WriteTo makes io.CopyBuffer use the given buffer
+	return io.CopyBuffer(struct{ io.Writer }{writer}, struct{ io.Reader }{source}, make([]byte, bufferSize))
+}
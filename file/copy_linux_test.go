@@ -0,0 +1,95 @@
+package file_test
+
+import (
+	"copy-images/file"
+	"copy-images/model"
+	"flag"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"os"
+	"path"
+	"syscall"
+	"testing"
+)
+
+// copySizeMiB is the size of the synthetic file copied by the benchmarks, e.g. -copy-size-mib 4096 for a phone video
+var copySizeMiB = flag.Int("copy-size-mib", 64, "size of the synthetic file copied by the copy benchmarks in MiB")
+
+// BenchmarkCopy compares the copy methods with reading the whole file into memory as CopyFilesTo used to do.
+// maxrss only grows, run a single method per process for absolute numbers, e.g.
+// go test ./file -run NONE -bench 'Copy/readfile' -copy-size-mib 4096
+func BenchmarkCopy(b *testing.B) {
+	source := path.Join(b.TempDir(), "VID_1.mp4")
+	writeRandomFile(b, source, int64(*copySizeMiB)*1024*1024)
+	benchmarks := []struct {
+		name string
+		copy func(from string, to string) error
+	}{
+		{"auto", executorCopy(file.Executor{Method: file.CopyAuto})},
+		{"stream-32KiB", executorCopy(file.Executor{Method: file.CopyStream, BufferSize: 32 * 1024})},
+		{"stream-1MiB", executorCopy(file.Executor{Method: file.CopyStream, BufferSize: 1024 * 1024})},
+		{"stream-8MiB", executorCopy(file.Executor{Method: file.CopyStream, BufferSize: 8 * 1024 * 1024})},
+		// the readfile baseline has to run last as it drives up maxrss of the whole process
+		{"readfile", readFileCopy},
+	}
+	for _, benchmark := range benchmarks {
+		b.Run(benchmark.name, func(b *testing.B) {
+			targetDir := b.TempDir()
+			rssBefore := maxRSS(b)
+			b.SetBytes(int64(*copySizeMiB) * 1024 * 1024)
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				destination := path.Join(targetDir, "VID_1.mp4")
+				if err := benchmark.copy(source, destination); err != nil {
+					b.Fatal(err)
+				}
+				b.StopTimer()
+				os.Remove(destination)
+				b.StartTimer()
+			}
+			b.StopTimer()
+			b.ReportMetric(float64(maxRSS(b)-rssBefore)/1024, "maxrss-growth-MiB")
+			b.ReportMetric(float64(maxRSS(b))/1024, "maxrss-MiB")
+		})
+	}
+}
+
+// executorCopy copies a file with a single operation of the executor
+func executorCopy(executor file.Executor) func(from string, to string) error {
+	executor.Progress = ioutil.Discard
+	return func(from string, to string) error {
+		return executor.Apply(model.FileOperations{FileOperations: []model.FileOperation{{From: from, To: to, OpType: model.CopyOp}}})
+	}
+}
+
+// readFileCopy is the copy implementation before streaming was introduced
+func readFileCopy(from string, to string) error {
+	input, err := ioutil.ReadFile(from)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(to, input, 0644)
+}
+
+// writeRandomFile writes a file of the given size with incompressible content
+func writeRandomFile(b *testing.B, filePath string, size int64) {
+	f, err := os.Create(filePath)
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer f.Close()
+	if _, err := io.CopyN(f, rand.New(rand.NewSource(1)), size); err != nil {
+		b.Fatal(err)
+	}
+}
+
+// maxRSS returns the peak resident set size of the process in KiB
+func maxRSS(b *testing.B) int64 {
+	var usage syscall.Rusage
+	if err := syscall.Getrusage(syscall.RUSAGE_SELF, &usage); err != nil {
+		b.Fatal(err)
+	}
+	return int64(usage.Maxrss)
+}
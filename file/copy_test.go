@@ -0,0 +1,168 @@
+package file_test
+
+import (
+	"bytes"
+	"copy-images/file"
+	"copy-images/index"
+	"copy-images/model"
+	"copy-images/utils"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStreamingCopyWithSmallBufferCopiesAndHashesContent(t *testing.T) {
+
+	//GIVEN
+	sourceDir := t.TempDir()
+	targetDir := t.TempDir()
+	content := bytes.Repeat([]byte("0123456789abcdef"), 1000)
+	source := writeFile(t, path.Join(sourceDir, "VID_1.mp4"), string(content))
+	idx, _ := index.Load(targetDir)
+	destination := path.Join(targetDir, "2021", "March", "VID_1.mp4")
+	fileOps := model.FileOperations{FileOperations: []model.FileOperation{{From: source, To: destination, OpType: model.CopyOp}}}
+	executor := file.Executor{Index: idx, Method: file.CopyStream, BufferSize: 7, Progress: ioutil.Discard}
+
+	//WHEN
+	err := executor.Apply(fileOps)
+
+	//THEN
+	assert.Nil(t, err, "No error must be thrown")
+	copied, _ := ioutil.ReadFile(destination)
+	assert.Equal(t, content, copied)
+	expectedHash, _ := utils.HashFile(source)
+	assert.Equal(t, expectedHash, idx.Entries()[0].Hash)
+	assert.Equal(t, int64(len(content)), idx.Entries()[0].Size)
+
+}
+
+func TestAutoCopyCopiesContent(t *testing.T) {
+
+	//GIVEN
+	sourceDir := t.TempDir()
+	targetDir := t.TempDir()
+	content := bytes.Repeat([]byte("photo"), 100000)
+	source := writeFile(t, path.Join(sourceDir, "IMG_1.jpg"), string(content))
+	destination := path.Join(targetDir, "IMG_1.jpg")
+	fileOps := model.FileOperations{FileOperations: []model.FileOperation{{From: source, To: destination, OpType: model.MoveOp}}}
+
+	//WHEN
+	err := file.Executor{Progress: ioutil.Discard}.Apply(fileOps)
+
+	//THEN
+	assert.Nil(t, err, "No error must be thrown")
+	copied, _ := ioutil.ReadFile(destination)
+	assert.Equal(t, content, copied)
+	assert.False(t, fileExists(source), "The moved source must be removed")
+
+}
+
+func TestFailedCopyKeepsSourceAndLeavesNoDestination(t *testing.T) {
+
+	//GIVEN
+	targetDir := t.TempDir()
+	destination := path.Join(targetDir, "IMG_1.jpg")
+	fileOps := model.FileOperations{FileOperations: []model.FileOperation{{From: path.Join(targetDir, "missing.jpg"), To: destination, OpType: model.MoveOp}}}
+
+	//WHEN
+	err := file.Executor{Progress: ioutil.Discard}.Apply(fileOps)
+
+	//THEN
+	assert.NotNil(t, err, "The missing source must be reported")
+	assert.False(t, fileExists(destination))
+
+}
+
+func TestParseCopyMethod(t *testing.T) {
+
+	//GIVEN
+	names := []string{"", "auto", "stream", "mmap"}
+
+	//WHEN
+	methods := make([]file.CopyMethod, len(names))
+	errs := make([]error, len(names))
+	for i, name := range names {
+		methods[i], errs[i] = file.ParseCopyMethod(name)
+	}
+
+	//THEN
+	assert.Equal(t, []file.CopyMethod{file.CopyAuto, file.CopyAuto, file.CopyStream, ""}, methods)
+	assert.Nil(t, errs[2])
+	assert.NotNil(t, errs[3], "Unknown methods must be rejected")
+
+}
+
+func TestCopyLeavesNoTempFiles(t *testing.T) {
+
+	//GIVEN
+	sourceDir := t.TempDir()
+	targetDir := t.TempDir()
+	source := writeFile(t, path.Join(sourceDir, "IMG_1.jpg"), "holiday")
+	destination := path.Join(targetDir, "IMG_1.jpg")
+	fileOps := model.FileOperations{FileOperations: []model.FileOperation{{From: source, To: destination, OpType: model.CopyOp}}}
+
+	//WHEN
+	err := file.Executor{Progress: ioutil.Discard}.Apply(fileOps)
+
+	//THEN
+	assert.Nil(t, err, "No error must be thrown")
+	entries, _ := ioutil.ReadDir(targetDir)
+	assert.Equal(t, 1, len(entries), "Only the destination must be left in the target")
+	assert.Equal(t, "IMG_1.jpg", entries[0].Name())
+	assert.Equal(t, "-rw-r--r--", entries[0].Mode().String())
+
+}
+
+func TestCleanupTempFilesRemovesLeftoversOfCrashedRuns(t *testing.T) {
+
+	//GIVEN
+	targetDir := t.TempDir()
+	resumed := writeFile(t, path.Join(targetDir, "2021", "March", file.TempFilePrefix+"20210303-141516-a1b2c3-123456"), "truncated")
+	stale := writeFile(t, path.Join(targetDir, "2021", "March", file.TempFilePrefix+"20210202-141516-d4e5f6-123456"), "truncated")
+	past := time.Now().Add(-2 * time.Hour)
+	os.Chtimes(stale, past, past)
+	concurrent := writeFile(t, path.Join(targetDir, "2021", "April", file.TempFilePrefix+"20210404-141516-a7b8c9-123456"), "in flight")
+	photo := writeFile(t, path.Join(targetDir, "2021", "March", "IMG_1.jpg"), "holiday")
+
+	//WHEN
+	removed, err := file.CleanupTempFiles(targetDir, "20210303-141516-a1b2c3")
+	_, missingErr := file.CleanupTempFiles(path.Join(targetDir, "missing"), "")
+
+	//THEN
+	assert.Nil(t, err, "No error must be thrown")
+	assert.Nil(t, missingErr, "A missing target has nothing to clean up")
+	assert.ElementsMatch(t, []string{resumed, stale}, removed)
+	assert.False(t, fileExists(resumed), "The temp files of the resumed run must be removed")
+	assert.False(t, fileExists(stale), "Temp files nobody writes to any more must be removed")
+	assert.True(t, fileExists(concurrent), "The temp files of a run copying at the same time must be kept")
+	assert.True(t, fileExists(photo), "Other files must be kept")
+
+}
+
+func TestCopyExclusiveNeverReplacesTheDestination(t *testing.T) {
+
+	//GIVEN
+	dir := t.TempDir()
+	temp := writeFile(t, path.Join(dir, file.TempFilePrefix+"1"), "holiday")
+	otherTemp := writeFile(t, path.Join(dir, file.TempFilePrefix+"2"), "beach")
+	existing := writeFile(t, path.Join(dir, "IMG_2.jpg"), "sunset")
+
+	//WHEN
+	err := file.CopyExclusive(temp, path.Join(dir, "IMG_1.jpg"))
+	existsErr := file.CopyExclusive(otherTemp, existing)
+
+	//THEN
+	assert.Nil(t, err, "No error must be thrown")
+	content, _ := ioutil.ReadFile(path.Join(dir, "IMG_1.jpg"))
+	assert.Equal(t, "holiday", string(content))
+	assert.False(t, fileExists(temp), "The published temp file must be removed")
+	assert.True(t, errors.Is(existsErr, file.ErrDestinationExists))
+	content, _ = ioutil.ReadFile(existing)
+	assert.Equal(t, "sunset", string(content), "An existing destination must not be replaced")
+
+}
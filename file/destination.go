@@ -0,0 +1,136 @@
+package file
+
+import (
+	"copy-images/layout"
+	"copy-images/model"
+	"copy-images/sniff"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+//destinationNamer assigns every file its destination in the target according to the layout of the CopyConfig.
+//A file rendering to an already assigned destination or to a file already existing in the target gets a _<n> suffix
+//so that nothing is overwritten.
+type destinationNamer struct {
+	targetDir  string
+	copyConfig CopyConfig
+	layout     *layout.Template
+	usedNames  map[string]int
+}
+
+//newDestinationNamer creates a destinationNamer for the targetDir
+func newDestinationNamer(targetDir string, copyConfig CopyConfig) *destinationNamer {
+	var fileLayout *layout.Template = copyConfig.Layout
+	if fileLayout == nil {
+		fileLayout = layout.MustParse(layout.Default)
+	}
+	return &destinationNamer{targetDir: targetDir, copyConfig: copyConfig, layout: fileLayout, usedNames: make(map[string]int)}
+}
+
+//destination returns the path the file is copied to
+func (n *destinationNamer) destination(fileToCopy model.FileInfo) (string, error) {
+	destinations, _, err := n.destinations(fileGroup{primary: fileToCopy})
+	if err != nil {
+		return "", err
+	}
+	return destinations[0], nil
+}
+
+//destinations returns the paths the members of the group are copied to and the paths of their sidecars. The
+//companions get the basename of the primary and their own extension, sidecars the name of their file followed by
+//their own suffix. A _<n> suffix is added to all of them so that they stay together.
+func (n *destinationNamer) destinations(group fileGroup) ([]string, [][]string, error) {
+	fileToCopy := group.primary
+	rendered, err := n.layout.Execute(fileToCopy)
+	if err != nil {
+		return nil, nil, err
+	}
+	if fileToCopy.MediaType == model.VideoMedia && n.copyConfig.VideosDir != "" {
+		rendered = path.Join(n.copyConfig.VideosDir, rendered)
+	}
+	if n.copyConfig.FixExtensions {
+		rendered = fixExtension(rendered, fileToCopy)
+	}
+	base := strings.TrimSuffix(rendered, path.Ext(rendered))
+	var suffixes []string
+	for i, member := range group.members() {
+		suffix := path.Ext(rendered)
+		if i > 0 {
+			companionName := base + filepath.Ext(member.Path)
+			if n.copyConfig.FixExtensions {
+				companionName = fixExtension(companionName, member)
+			}
+			suffix = path.Ext(companionName)
+		}
+		suffixes = append(suffixes, suffix)
+		for _, sidecar := range member.Sidecars {
+			suffixes = append(suffixes, sidecarSuffix(member.Path, suffix, sidecar))
+		}
+	}
+	// names are compared case insensitive as many targets are case insensitive file systems
+	key := strings.ToLower(rendered)
+	count := n.usedNames[key]
+	candidates := names(base, "", suffixes)
+	for n.taken(candidates) {
+		count++
+		candidates = names(base, "_"+strconv.Itoa(count), suffixes)
+	}
+	n.usedNames[key] = count
+	var members []string
+	var sidecars [][]string
+	for _, member := range group.members() {
+		members = append(members, n.assign(candidates[0], rendered))
+		var memberSidecars []string
+		for range member.Sidecars {
+			candidates = candidates[1:]
+			memberSidecars = append(memberSidecars, n.assign(candidates[0], rendered))
+		}
+		sidecars = append(sidecars, memberSidecars)
+		candidates = candidates[1:]
+	}
+	return members, sidecars, nil
+}
+
+//assign marks the candidate as used and returns its path in the target
+func (n *destinationNamer) assign(candidate string, rendered string) string {
+	if candidate != rendered {
+		n.usedNames[strings.ToLower(candidate)] = 0
+	}
+	return path.Join(n.targetDir, candidate)
+}
+
+//names returns the base with the count suffix followed by each of the suffixes
+func names(base string, count string, suffixes []string) []string {
+	candidates := make([]string, len(suffixes))
+	for i, suffix := range suffixes {
+		candidates[i] = base + count + suffix
+	}
+	return candidates
+}
+
+//taken checks if any of the destinations has already been assigned or exists in the target
+func (n *destinationNamer) taken(candidates []string) bool {
+	for _, candidate := range candidates {
+		if _, assigned := n.usedNames[strings.ToLower(candidate)]; assigned {
+			return true
+		}
+		if _, err := os.Lstat(path.Join(n.targetDir, candidate)); err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+//fixExtension replaces the extension of the rendered destination if it has been taken from the source and does not match
+//the sniffed type of the file. Files without extension get the usual one of their type.
+func fixExtension(rendered string, f model.FileInfo) string {
+	detected, ok := sniff.ByMimeType(f.MimeType)
+	extension := path.Ext(rendered)
+	if !ok || detected.HasExtension(extension) || !strings.EqualFold(extension, filepath.Ext(f.Path)) {
+		return rendered
+	}
+	return strings.TrimSuffix(rendered, extension) + detected.Extension()
+}
@@ -0,0 +1,101 @@
+package file
+
+import (
+	"copy-images/index"
+	"copy-images/model"
+	"copy-images/utils"
+	"os"
+	"path/filepath"
+)
+
+//contentIndex maps the sha-256 of contents to the slash separated path relative to the target of the first file having it.
+//Only files of a size one of the candidates has are hashed, everything else cannot be a duplicate.
+type contentIndex struct {
+	targetDir string
+	sizes     map[int64]bool
+	hashes    map[string]string
+}
+
+//newContentIndex indexes all files of the targetDir having the size of one of the candidates. Files recorded in idx
+//with their size which have not been modified since they were recorded are not hashed again, idx may be nil.
+//The metadata dir of the target is left out, a targetDir which does not exist yet results in an empty index.
+func newContentIndex(targetDir string, candidates []model.FileInfo, idx *index.Index) (*contentIndex, error) {
+	contents := &contentIndex{targetDir: targetDir, sizes: make(map[int64]bool), hashes: make(map[string]string)}
+	for _, candidate := range candidates {
+		size, err := fileSize(candidate)
+		if err != nil {
+			return nil, err
+		}
+		contents.sizes[size] = true
+	}
+	err := filepath.Walk(targetDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) && path == targetDir {
+				return filepath.SkipDir
+			}
+			return err
+		}
+		if info.IsDir() && info.Name() == index.Dir {
+			return filepath.SkipDir
+		}
+		if !info.Mode().IsRegular() || isTempFile(info.Name()) || !contents.sizes[info.Size()] {
+			return nil
+		}
+		hash, ok := recordedHash(idx, contents.relative(path), info)
+		if !ok {
+			if hash, err = utils.HashFile(path); err != nil {
+				return err
+			}
+		}
+		if _, ok := contents.hashes[hash]; !ok {
+			contents.hashes[hash] = contents.relative(path)
+		}
+		return nil
+	})
+	return contents, err
+}
+
+//recordedHash returns the hash idx has recorded for the file at the relative path, if the file still has the recorded
+//size and has not been modified after it was recorded
+func recordedHash(idx *index.Index, relative string, info os.FileInfo) (string, bool) {
+	if idx == nil {
+		return "", false
+	}
+	entry, ok := idx.Destination(relative)
+	if !ok || entry.Hash == "" || entry.Size != info.Size() || info.ModTime().After(entry.ImportedAt) {
+		return "", false
+	}
+	return entry.Hash, true
+}
+
+//duplicateOf returns the path relative to the target of a file having the given content hash
+func (contents *contentIndex) duplicateOf(hash string) (string, bool) {
+	existing, ok := contents.hashes[hash]
+	return existing, ok
+}
+
+//add records that a file with the given hash will be written to the destination
+func (contents *contentIndex) add(hash string, destination string) {
+	contents.hashes[hash] = contents.relative(destination)
+}
+
+//relative returns the slash separated path of a file within the target
+func (contents *contentIndex) relative(path string) string {
+	relative, err := filepath.Rel(contents.targetDir, path)
+	if err != nil {
+		return filepath.ToSlash(path)
+	}
+	return filepath.ToSlash(relative)
+}
+
+//fileSize returns the size of the file, it is only read from the file system if the FileInfo does not carry it
+func fileSize(f model.FileInfo) (int64, error) {
+	if f.Size > 0 {
+		return f.Size, nil
+	}
+	info, err := os.Stat(f.Path)
+	if err != nil {
+		return 0, err
+	}
+	return info.Size(), nil
+}
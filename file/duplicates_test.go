@@ -0,0 +1,214 @@
+package file_test
+
+import (
+	"copy-images/file"
+	"copy-images/index"
+	"copy-images/model"
+	"copy-images/utils"
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPlannerSkipsContentAlreadyInTarget(t *testing.T) {
+
+	//GIVEN
+	sourceDir := t.TempDir()
+	targetDir := t.TempDir()
+	march, _ := time.Parse("2006-01-02", "2021-03-03")
+	writeFile(t, path.Join(targetDir, "2021", "March", "IMG_1.jpg"), "holiday")
+	writeFile(t, path.Join(targetDir, "2021", "March", "IMG_2.jpg"), "beach")
+	filesToCopy := []model.FileInfo{
+		{Path: writeFile(t, path.Join(sourceDir, "copy_of_holiday.jpg"), "holiday"), CreationDate: march},
+		{Path: writeFile(t, path.Join(sourceDir, "IMG_2.jpg"), "mountains"), CreationDate: march},
+	}
+	planner := file.Planner{TargetDir: targetDir, CopyConfig: file.CopyConfig{SkipDuplicates: true}}
+
+	//WHEN
+	fileOps, err := planner.Plan(filesToCopy)
+
+	//THEN
+	assert.Nil(t, err, "No error must be thrown")
+	assert.Equal(t, model.SkipOp, fileOps.FileOperations[0].OpType)
+	assert.Equal(t, "duplicate of 2021/March/IMG_1.jpg", fileOps.FileOperations[0].Reason)
+	assert.Equal(t, "", fileOps.FileOperations[0].To)
+	assert.Equal(t, model.CopyOp, fileOps.FileOperations[1].OpType)
+	assert.Equal(t, path.Join(targetDir, "2021", "March", "IMG_2_1.jpg"), fileOps.FileOperations[1].To, "A different content must not overwrite the existing file")
+	assert.NotEmpty(t, fileOps.FileOperations[1].Hash)
+
+}
+
+func TestPlannerTakesTheHashesOfUnchangedFilesInTheTargetFromTheIndex(t *testing.T) {
+
+	//GIVEN
+	sourceDir := t.TempDir()
+	targetDir := t.TempDir()
+	march, _ := time.Parse("2006-01-02", "2021-03-03")
+	unchanged := writeFile(t, path.Join(targetDir, "2021", "March", "IMG_1.jpg"), "sunny")
+	modified := writeFile(t, path.Join(targetDir, "2021", "March", "IMG_2.jpg"), "rainy")
+	beach := model.FileInfo{Path: writeFile(t, path.Join(sourceDir, "beach.jpg"), "beach"), CreationDate: march}
+	waves := model.FileInfo{Path: writeFile(t, path.Join(sourceDir, "waves.jpg"), "waves"), CreationDate: march}
+	beachHash, _ := utils.HashFile(beach.Path)
+	wavesHash, _ := utils.HashFile(waves.Path)
+	idx, _ := index.Load(targetDir)
+	//the index pretends that the files hold the content of the sources, which shows if they are hashed again
+	idx.Record(model.FileOperation{From: "/media/old/IMG_1.jpg", To: unchanged}, beachHash, 5)
+	idx.Record(model.FileOperation{From: "/media/old/IMG_2.jpg", To: modified}, wavesHash, 5)
+	later := time.Now().Add(time.Hour)
+	os.Chtimes(modified, later, later)
+	planner := file.Planner{TargetDir: targetDir, CopyConfig: file.CopyConfig{Index: idx, SkipDuplicates: true}}
+
+	//WHEN
+	fileOps, err := planner.Plan([]model.FileInfo{beach, waves})
+
+	//THEN
+	assert.Nil(t, err, "No error must be thrown")
+	assert.Equal(t, model.SkipOp, fileOps.FileOperations[0].OpType)
+	assert.Equal(t, "duplicate of 2021/March/IMG_1.jpg", fileOps.FileOperations[0].Reason, "An unchanged file must not be hashed again")
+	assert.Equal(t, model.CopyOp, fileOps.FileOperations[1].OpType, "A file modified after it was recorded must be hashed again")
+
+}
+
+func TestPlannerSkipsDuplicatesWithinTheSource(t *testing.T) {
+
+	//GIVEN
+	sourceDir := t.TempDir()
+	targetDir := t.TempDir()
+	march, _ := time.Parse("2006-01-02", "2021-03-03")
+	filesToCopy := []model.FileInfo{
+		{Path: writeFile(t, path.Join(sourceDir, "IMG_1.jpg"), "holiday"), CreationDate: march},
+		{Path: writeFile(t, path.Join(sourceDir, "backup", "IMG_1.jpg"), "holiday"), CreationDate: march},
+	}
+	planner := file.Planner{TargetDir: targetDir, CopyConfig: file.CopyConfig{SkipDuplicates: true}}
+
+	//WHEN
+	fileOps, err := planner.Plan(filesToCopy)
+
+	//THEN
+	assert.Nil(t, err, "No error must be thrown")
+	assert.Equal(t, model.CopyOp, fileOps.FileOperations[0].OpType)
+	assert.Equal(t, model.SkipOp, fileOps.FileOperations[1].OpType)
+	assert.Equal(t, "duplicate of 2021/March/IMG_1.jpg", fileOps.FileOperations[1].Reason)
+
+}
+
+func TestRerunWithSkipDuplicatesCopiesNothing(t *testing.T) {
+
+	//GIVEN
+	sourceDir := t.TempDir()
+	targetDir := t.TempDir()
+	march, _ := time.Parse("2006-01-02", "2021-03-03")
+	filesToCopy := []model.FileInfo{
+		{Path: writeFile(t, path.Join(sourceDir, "IMG_1.jpg"), "holiday"), CreationDate: march},
+		{Path: writeFile(t, path.Join(sourceDir, "IMG_2.jpg"), "beach"), CreationDate: march},
+	}
+	copyConfig := file.CopyConfig{SkipDuplicates: true}
+	assert.Nil(t, file.CopyFilesTo(targetDir, filesToCopy, copyConfig))
+	planner := file.Planner{TargetDir: targetDir, CopyConfig: copyConfig}
+
+	//WHEN
+	fileOps, err := planner.Plan(filesToCopy)
+	applyErr := file.Executor{}.Apply(fileOps)
+
+	//THEN
+	assert.Nil(t, err, "No error must be thrown")
+	assert.Nil(t, applyErr, "Skipped operations must be valid")
+	for _, fileOp := range fileOps.FileOperations {
+		assert.Equal(t, model.SkipOp, fileOp.OpType)
+	}
+	assert.Empty(t, file.VerifyFileOperations(fileOps))
+	assert.False(t, fileExists(path.Join(targetDir, "2021", "March", "IMG_1_1.jpg")))
+
+}
+
+func writeFile(t *testing.T, filePath string, content string) string {
+	if err := os.MkdirAll(path.Dir(filePath), os.ModePerm); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filePath, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return filePath
+}
+
+func TestIndexSkipsFilesImportedByEarlierRuns(t *testing.T) {
+
+	//GIVEN
+	sourceDir := t.TempDir()
+	targetDir := t.TempDir()
+	march, _ := time.Parse("2006-01-02", "2021-03-03")
+	filesToCopy := []model.FileInfo{
+		{Path: writeFile(t, path.Join(sourceDir, "IMG_1.jpg"), "holiday"), CreationDate: march},
+	}
+	idx, _ := index.Load(targetDir)
+	assert.Nil(t, file.CopyFilesTo(targetDir, filesToCopy, file.CopyConfig{Index: idx}))
+	filesToCopy = append(filesToCopy, model.FileInfo{Path: writeFile(t, path.Join(sourceDir, "IMG_2.jpg"), "beach"), CreationDate: march})
+	reloaded, _ := index.Load(targetDir)
+	planner := file.Planner{TargetDir: targetDir, CopyConfig: file.CopyConfig{Index: reloaded}}
+
+	//WHEN
+	fileOps, err := planner.Plan(filesToCopy)
+
+	//THEN
+	assert.Nil(t, err, "No error must be thrown")
+	assert.Equal(t, model.SkipOp, fileOps.FileOperations[0].OpType)
+	assert.Equal(t, "already imported to 2021/March/IMG_1.jpg", fileOps.FileOperations[0].Reason)
+	assert.Equal(t, model.CopyOp, fileOps.FileOperations[1].OpType, "Only new files must be copied")
+
+}
+
+func TestImportedFilesOlderThanTheCutoffAreDeletedWithoutCopyingThemAgain(t *testing.T) {
+
+	//GIVEN
+	sourceDir := t.TempDir()
+	targetDir := t.TempDir()
+	march, _ := time.Parse("2006-01-02", "2021-03-03")
+	april, _ := time.Parse("2006-01-02", "2021-04-04")
+	photo := model.FileInfo{Path: writeFile(t, path.Join(sourceDir, "IMG_1.jpg"), "holiday"), CreationDate: march}
+	idx, _ := index.Load(targetDir)
+	assert.Nil(t, file.CopyFilesTo(targetDir, []model.FileInfo{photo}, file.CopyConfig{Index: idx}))
+	reloaded, _ := index.Load(targetDir)
+	planner := file.Planner{TargetDir: targetDir, CutoffDate: april, CopyConfig: file.CopyConfig{Index: reloaded}}
+
+	//WHEN
+	fileOps, err := planner.Plan([]model.FileInfo{photo})
+	applyErr := file.Executor{Progress: ioutil.Discard}.Apply(fileOps)
+
+	//THEN
+	assert.Nil(t, err, "No error must be thrown")
+	assert.Nil(t, applyErr, "No error must be thrown")
+	assert.Equal(t, model.DeleteOp, fileOps.FileOperations[0].OpType)
+	assert.Equal(t, path.Join(targetDir, "2021", "March", "IMG_1.jpg"), fileOps.FileOperations[0].To)
+	assert.False(t, fileExists(photo.Path), "The imported source must be deleted")
+	assert.True(t, fileExists(fileOps.FileOperations[0].To), "The existing copy must be kept")
+
+}
+
+func TestDuplicatesAreOnlyDeletedIfTheirCopyStillHasTheirContent(t *testing.T) {
+
+	//GIVEN
+	sourceDir := t.TempDir()
+	targetDir := t.TempDir()
+	march, _ := time.Parse("2006-01-02", "2021-03-03")
+	april, _ := time.Parse("2006-01-02", "2021-04-04")
+	photo := model.FileInfo{Path: writeFile(t, path.Join(sourceDir, "IMG_1.jpg"), "holiday"), CreationDate: march}
+	copyPath := writeFile(t, path.Join(targetDir, "2021", "March", "IMG_1.jpg"), "holiday")
+	planner := file.Planner{TargetDir: targetDir, CutoffDate: april, CopyConfig: file.CopyConfig{SkipDuplicates: true}}
+	fileOps, _ := planner.Plan([]model.FileInfo{photo})
+	writeFile(t, copyPath, "edited!")
+
+	//WHEN
+	err := file.Executor{Progress: ioutil.Discard}.Apply(fileOps)
+
+	//THEN
+	assert.Equal(t, model.DeleteOp, fileOps.FileOperations[0].OpType)
+	assert.NotNil(t, err, "The modified copy must be reported")
+	assert.True(t, fileExists(photo.Path), "The source must be kept")
+	content, _ := ioutil.ReadFile(copyPath)
+	assert.Equal(t, "edited!", string(content), "The existing copy must not be touched")
+
+}
@@ -0,0 +1,521 @@
+package file
+
+import (
+	"copy-images/index"
+	"copy-images/model"
+	"copy-images/runs"
+	"copy-images/trash"
+	"copy-images/utils"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+//Executor applies model.FileOperations created by a Planner or read from an edited plan file
+type Executor struct {
+	// Index records every copied file, it is not used if it is nil
+	Index *index.Index
+	// Method selects how the content is copied, CopyAuto if it is empty
+	Method CopyMethod
+	// BufferSize is the size of the buffer files are streamed with, DefaultBufferSize if it is 0
+	BufferSize int
+	// Progress receives a line for every operation, os.Stdout if it is nil
+	Progress io.Writer
+	// VerifyCopies reads back copies like moves, by default only moves are verified before their source is removed
+	VerifyCopies bool
+	// Manifest records the result of every operation, it is not used if it is nil
+	Manifest *runs.Manifest
+	// Workers is the number of operations executed concurrently, operations are executed one by one if it is below 2
+	Workers int
+	// SetModTime sets the modification time of every copy to the date of its operation, like the capture time read
+	// from the json sidecar of a Google Takeout export
+	SetModTime bool
+	// Trash takes the sources of moves, they are removed for good if it is nil
+	Trash trash.Trash
+	// Journal records every file created and every source removed before and after the change, so that the run can
+	// be undone even if it crashed, it is not used if it is nil
+	Journal *runs.Journal
+	// Checkpoint records every completed operation, operations it already knows as completed are not executed again.
+	// It is not used if it is nil.
+	Checkpoint *runs.Checkpoint
+}
+
+//hashDestination reads back a copy, it is replaced by tests to simulate corrupt copies
+var hashDestination = utils.HashFile
+
+//Apply validates all operations and executes them with Workers goroutines. A model.MoveOp removes the source after
+//its copy has been verified, a model.DeleteOp after its existing copy has been, a model.SkipOp is only reported.
+//Nothing is executed if any operation is invalid.
+//The destinations have been assigned by the Planner, validation makes sure that no two workers write the same one.
+//After an operation failed no further operations are started, the operations in progress are finished and all
+//their errors are returned. A copy not matching its source is removed and reported after all other operations are done.
+func (e Executor) Apply(fileOps model.FileOperations) error {
+	if err := ValidateFileOperations(fileOps); err != nil {
+		return err
+	}
+	progress := e.Progress
+	if progress == nil {
+		progress = os.Stdout
+	}
+	progress = &syncWriter{writer: progress}
+	workers := e.Workers
+	if workers < 1 {
+		workers = 1
+	}
+
+	numberOfOps := len(fileOps.FileOperations)
+	results := make([]result, numberOfOps)
+	jobs := make(chan int)
+	var failed int32
+	var wg sync.WaitGroup
+	for worker := 0; worker < workers; worker++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for index := range jobs {
+				//drain the remaining jobs without executing them once an operation failed
+				if atomic.LoadInt32(&failed) != 0 {
+					continue
+				}
+				fileOp := fileOps.FileOperations[index]
+				if fileOp.OpType == model.SkipOp {
+					fmt.Fprintf(progress, "Skipping %d/%d %s%s, %s\n", (index + 1), numberOfOps, fileOp.From, companionNames(fileOp), fileOp.Reason)
+					var entries []runs.Entry
+					for _, part := range fileOp.Parts() {
+						entries = append(entries, runs.Entry{From: part.From, OpType: string(part.OpType), Status: runs.Skipped, Error: part.Reason})
+					}
+					results[index] = result{done: true, entries: entries}
+					continue
+				}
+				if e.Checkpoint != nil && e.Checkpoint.Done(fileOp.From, fileOp.To) {
+					fmt.Fprintf(progress, "Already done %d/%d %s%s\n", (index + 1), numberOfOps, fileOp.From, companionNames(fileOp))
+					var entries []runs.Entry
+					for _, part := range fileOp.Parts() {
+						//a run continuing its own manifest has recorded the result already
+						if e.Manifest != nil && e.Manifest.Holds(part.From, part.To) {
+							continue
+						}
+						entries = append(entries, runs.Entry{From: part.From, To: part.To, OpType: string(part.OpType), Status: runs.Skipped, Error: "completed before the run was resumed"})
+					}
+					results[index] = result{done: true, entries: entries}
+					continue
+				}
+				fmt.Fprintf(progress, "%s %d/%d %s%s ... \n", progressVerb(fileOp.OpType), (index + 1), numberOfOps, fileOp.From, companionNames(fileOp))
+				entries, err := e.apply(fileOp)
+				if err == nil {
+					err = e.checkpoint(fileOp, entries)
+				}
+				if err != nil {
+					entries[len(entries)-1].Status = runs.Failed
+					entries[len(entries)-1].Error = err.Error()
+					atomic.StoreInt32(&failed, 1)
+				}
+				results[index] = result{done: true, entries: entries, err: err}
+			}
+		}()
+	}
+	for index := range fileOps.FileOperations {
+		if atomic.LoadInt32(&failed) != 0 {
+			break
+		}
+		jobs <- index
+	}
+	close(jobs)
+	wg.Wait()
+
+	//the manifest and the errors list the operations in the order of the plan, no matter which worker finished first
+	var errs []error
+	var mismatches []string
+	for _, r := range results {
+		if !r.done {
+			continue
+		}
+		for _, entry := range r.entries {
+			e.record(entry)
+			if entry.Status == runs.Mismatch {
+				mismatches = append(mismatches, entry.Error)
+			}
+		}
+		if r.err != nil {
+			errs = append(errs, r.err)
+		}
+	}
+	if len(errs) == 1 {
+		return errs[0]
+	}
+	if len(errs) > 1 {
+		messages := make([]string, len(errs))
+		for i, err := range errs {
+			messages[i] = err.Error()
+		}
+		return fmt.Errorf("%d operations failed:\n  %s", len(errs), strings.Join(messages, "\n  "))
+	}
+	if len(mismatches) > 0 {
+		return fmt.Errorf("%d copies do not match their source, their sources have been kept:\n  %s", len(mismatches), strings.Join(mismatches, "\n  "))
+	}
+	return nil
+}
+
+//result is the outcome of an operation executed by a worker, it has an entry for every part of the operation
+type result struct {
+	done    bool
+	entries []runs.Entry
+	err     error
+}
+
+//syncWriter serializes the writes of the workers so that their progress lines do not interleave
+type syncWriter struct {
+	mu     sync.Mutex
+	writer io.Writer
+}
+
+func (w *syncWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.writer.Write(p)
+}
+
+//runID returns the id of the run of the manifest, it is empty without manifest
+func (e Executor) runID() string {
+	if e.Manifest == nil {
+		return ""
+	}
+	return e.Manifest.RunID
+}
+
+//record adds the entry to the manifest if there is one
+func (e Executor) record(entry runs.Entry) {
+	if e.Manifest != nil {
+		e.Manifest.Add(entry)
+	}
+}
+
+//checkpoint records the operation as completed if the copies of all its parts match their source
+func (e Executor) checkpoint(fileOp model.FileOperation, entries []runs.Entry) error {
+	if e.Checkpoint == nil {
+		return nil
+	}
+	for _, entry := range entries {
+		if entry.Status == runs.Mismatch {
+			return nil
+		}
+	}
+	return e.Checkpoint.Complete(fileOp.From, fileOp.To)
+}
+
+//journal appends the record to the journal if there is one
+func (e Executor) journal(record runs.Record) error {
+	if e.Journal == nil {
+		return nil
+	}
+	return e.Journal.Append(record)
+}
+
+//apply executes a single operation and its companions. The content is streamed so that the size of a file does not
+//matter. The sources of a move are only removed once the copies of all parts have been read back and have the hash
+//their source had while copying, so a pair is never separated. It returns an entry for every part it started.
+func (e Executor) apply(fileOp model.FileOperation) ([]runs.Entry, error) {
+	parts := fileOp.Parts()
+	entries := make([]runs.Entry, 0, len(parts))
+	complete := true
+	for _, part := range parts {
+		var entry runs.Entry
+		var err error
+		if fileOp.OpType == model.DeleteOp {
+			entry, err = e.checkPart(part)
+		} else {
+			entry, err = e.copyPart(part)
+		}
+		entries = append(entries, entry)
+		if err != nil {
+			return entries, err
+		}
+		complete = complete && entry.Status != runs.Mismatch
+	}
+	if (fileOp.OpType != model.MoveOp && fileOp.OpType != model.DeleteOp) || !complete {
+		return entries, nil
+	}
+	action := runs.Remove
+	if e.Trash != nil {
+		action = runs.Trash
+	}
+	for i, part := range parts {
+		//an interrupted run may have removed the source already
+		if entries[i].SourceDeleted {
+			continue
+		}
+		record := runs.Record{Action: action, Path: part.From, Hash: entries[i].SourceHash}
+		if fileOp.OpType == model.DeleteOp {
+			//the copy has not been created by the run, undo restores a removed source from it
+			record.From = part.To
+		}
+		if err := e.journal(record); err != nil {
+			return entries, err
+		}
+		trashed, err := deleteFile(part.From, e.Trash)
+		if err != nil {
+			return entries, err
+		}
+		entries[i].SourceDeleted, entries[i].Trashed = true, trashed
+		record.Done, record.To = true, trashed
+		if err := e.journal(record); err != nil {
+			return entries, err
+		}
+	}
+	return entries, nil
+}
+
+//copyPart copies a single file of an operation, verifies the copy and records it in the index. A copy not matching
+//its source is removed again.
+func (e Executor) copyPart(fileOp model.FileOperation) (runs.Entry, error) {
+	entry := runs.Entry{From: fileOp.From, To: fileOp.To, OpType: string(fileOp.OpType)}
+	if done, err := e.resumePart(fileOp, &entry); err != nil || done {
+		if err != nil || entry.SourceDeleted {
+			return entry, err
+		}
+		return entry, e.finishPart(fileOp, entry.SourceHash, entry.Size)
+	}
+	//an existing file is not the run's, it must neither be overwritten nor be removed by an undo of the run
+	if exists(fileOp.To) {
+		return entry, fmt.Errorf("%s: %w", fileOp.To, ErrDestinationExists)
+	}
+	//create the destination path
+	err := os.MkdirAll(filepath.Dir(fileOp.To), os.ModePerm)
+	if err != nil {
+		return entry, err
+	}
+	verify := fileOp.OpType == model.MoveOp || e.VerifyCopies
+	//the index and the journal need the hash, it only has to be computed while copying if the plan does not carry it
+	var digest hash.Hash
+	if verify || ((e.Index != nil || e.Journal != nil) && fileOp.Hash == "") {
+		digest = sha256.New()
+	}
+	record := runs.Record{Action: runs.Create, Path: fileOp.To, From: fileOp.From, Hash: fileOp.Hash}
+	if err := e.journal(record); err != nil {
+		return entry, err
+	}
+	entry.Size, err = copyFile(fileOp.From, fileOp.To, e.Method, e.BufferSize, digest, e.runID())
+	if err != nil {
+		return entry, err
+	}
+	contentHash := fileOp.Hash
+	if digest != nil {
+		contentHash = hex.EncodeToString(digest.Sum(nil))
+		entry.SourceHash = contentHash
+	}
+	entry.Status = runs.Copied
+	if verify {
+		if entry.DestinationHash, err = hashDestination(fileOp.To); err != nil {
+			return entry, err
+		}
+		if entry.DestinationHash != entry.SourceHash {
+			entry.Status = runs.Mismatch
+			entry.Error = fmt.Sprintf("%s: sha-256 %s does not match %s of %s", fileOp.To, entry.DestinationHash, entry.SourceHash, fileOp.From)
+			return entry, os.Remove(fileOp.To)
+		}
+		entry.Status = runs.Verified
+	}
+	record.Done, record.Hash = true, contentHash
+	if err := e.journal(record); err != nil {
+		return entry, err
+	}
+	return entry, e.finishPart(fileOp, contentHash, entry.Size)
+}
+
+//finishPart sets the modification time of a copy and records it in the index
+func (e Executor) finishPart(fileOp model.FileOperation, contentHash string, size int64) error {
+	if e.SetModTime && !fileOp.Date.IsZero() {
+		if err := os.Chtimes(fileOp.To, fileOp.Date, fileOp.Date); err != nil {
+			return err
+		}
+	}
+	if e.Index != nil {
+		return e.Index.Record(fileOp, contentHash, size)
+	}
+	return nil
+}
+
+//checkPart checks that the existing copy of a part of a model.DeleteOp has the content of its source, nothing is
+//written. A copy which is missing or differs is reported as mismatch and keeps the sources of the operation.
+func (e Executor) checkPart(fileOp model.FileOperation) (runs.Entry, error) {
+	entry := runs.Entry{From: fileOp.From, To: fileOp.To, OpType: string(fileOp.OpType)}
+	source, err := os.Stat(fileOp.From)
+	if os.IsNotExist(err) && e.Checkpoint != nil && exists(fileOp.To) {
+		//an interrupted run has removed the source already
+		entry.Status, entry.SourceDeleted = runs.Verified, true
+		return entry, nil
+	}
+	if err != nil {
+		return entry, err
+	}
+	entry.Size = source.Size()
+	if entry.SourceHash, err = utils.HashFile(fileOp.From); err != nil {
+		return entry, err
+	}
+	if entry.DestinationHash, err = hashDestination(fileOp.To); err != nil && !os.IsNotExist(err) {
+		return entry, err
+	}
+	if entry.DestinationHash != entry.SourceHash {
+		entry.Status = runs.Mismatch
+		entry.Error = fmt.Sprintf("%s: the copy of %s is missing or does not have its content", fileOp.To, fileOp.From)
+		return entry, nil
+	}
+	entry.Status = runs.Verified
+	return entry, nil
+}
+
+//resumePart checks if an interrupted run has carried out the part already, which is only done with a Checkpoint. A
+//copy at the destination is kept if it has the size and hash of its source, a partially written copy holding the
+//beginning of its source is removed and copied again. Other files are left for copyPart to refuse. The source of a
+//move may have been removed already, which only happens after its copy was verified.
+func (e Executor) resumePart(fileOp model.FileOperation, entry *runs.Entry) (bool, error) {
+	if e.Checkpoint == nil {
+		return false, nil
+	}
+	destination, err := os.Stat(fileOp.To)
+	if err != nil {
+		return false, nil
+	}
+	source, err := os.Stat(fileOp.From)
+	if os.IsNotExist(err) && fileOp.OpType == model.MoveOp {
+		entry.Status, entry.Size, entry.SourceDeleted = runs.Verified, destination.Size(), true
+		return true, nil
+	}
+	if err == nil && destination.Size() < source.Size() {
+		return false, removePartialCopy(fileOp.From, fileOp.To, destination.Size())
+	}
+	if err != nil || source.Size() != destination.Size() {
+		return false, nil
+	}
+	if entry.SourceHash, err = utils.HashFile(fileOp.From); err != nil {
+		return false, err
+	}
+	if entry.DestinationHash, err = hashDestination(fileOp.To); err != nil {
+		return false, err
+	}
+	if entry.SourceHash != entry.DestinationHash {
+		entry.SourceHash, entry.DestinationHash = "", ""
+		return false, nil
+	}
+	entry.Status, entry.Size = runs.Verified, source.Size()
+	return true, nil
+}
+
+//removePartialCopy removes the destination if it holds the first size bytes of the source
+func removePartialCopy(from string, to string, size int64) error {
+	source, err := os.Open(from)
+	if err != nil {
+		return err
+	}
+	defer source.Close()
+	digest := sha256.New()
+	if _, err := io.CopyN(digest, source, size); err != nil {
+		return err
+	}
+	partial, err := hashDestination(to)
+	if err != nil || partial != hex.EncodeToString(digest.Sum(nil)) {
+		return err
+	}
+	return os.Remove(to)
+}
+
+//companionNames lists the names of the companions of an operation for its progress line
+func companionNames(fileOp model.FileOperation) string {
+	var names strings.Builder
+	for _, companion := range fileOp.Companions {
+		names.WriteString(" + " + filepath.Base(companion.From))
+	}
+	return names.String()
+}
+
+//progressVerb returns the verb printed for the progress of an operation
+func progressVerb(opType model.OpType) string {
+	if opType == model.MoveOp {
+		return "Moving"
+	}
+	if opType == model.DeleteOp {
+		return "Deleting"
+	}
+	return "Copying"
+}
+
+// ValidateFileOperations checks that every operation has a source, a destination unless it is skipped and a known type
+// and that no two operations write the same destination, the destination of a model.DeleteOp is only read
+func ValidateFileOperations(fileOps model.FileOperations) error {
+	var problems []string
+	destinations := make(map[string]int)
+	for index, fileOp := range fileOps.FileOperations {
+		if fileOp.OpType != model.SkipOp && fileOp.OpType != model.CopyOp && fileOp.OpType != model.MoveOp && fileOp.OpType != model.DeleteOp {
+			problems = append(problems, fmt.Sprintf("operation %d: unknown type %q", index+1, fileOp.OpType))
+		}
+		//companions are checked like the file of the operation
+		for _, part := range fileOp.Parts() {
+			if part.OpType == model.SkipOp {
+				if part.From == "" {
+					problems = append(problems, fmt.Sprintf("operation %d: from is required", index+1))
+				}
+				continue
+			}
+			if part.From == "" || part.To == "" {
+				problems = append(problems, fmt.Sprintf("operation %d: from and to are required", index+1))
+			}
+			//deletes only read their destination, duplicates within the source share it
+			if part.OpType == model.DeleteOp {
+				continue
+			}
+			if other, ok := destinations[part.To]; ok && part.To != "" {
+				problems = append(problems, fmt.Sprintf("operation %d: destination %s is already written by operation %d", index+1, part.To, other+1))
+			}
+			destinations[part.To] = index
+		}
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("invalid file operations:\n  %s", strings.Join(problems, "\n  "))
+	}
+	return nil
+}
+
+// VerifyFileOperations checks that the destination of every operation and its companions exists and has the size of
+// its source. Skipped operations have nothing to verify. It returns one error for every operation which does not match
+func VerifyFileOperations(fileOps model.FileOperations) []error {
+	var problems []error
+	for _, fileOp := range allParts(fileOps) {
+		if fileOp.OpType == model.SkipOp {
+			continue
+		}
+		destInfo, err := os.Stat(fileOp.To)
+		if err != nil {
+			problems = append(problems, err)
+			continue
+		}
+		sourceInfo, err := os.Stat(fileOp.From)
+		if err != nil {
+			// a moved source is gone, in that case only the destination can be checked
+			if (fileOp.OpType == model.MoveOp || fileOp.OpType == model.DeleteOp) && os.IsNotExist(err) {
+				continue
+			}
+			problems = append(problems, err)
+			continue
+		}
+		if sourceInfo.Size() != destInfo.Size() {
+			problems = append(problems, fmt.Errorf("%s: size %d does not match size %d of %s", fileOp.To, destInfo.Size(), sourceInfo.Size(), fileOp.From))
+		}
+	}
+	return problems
+}
+
+//allParts returns the parts of all operations
+func allParts(fileOps model.FileOperations) []model.FileOperation {
+	var parts []model.FileOperation
+	for _, fileOp := range fileOps.FileOperations {
+		parts = append(parts, fileOp.Parts()...)
+	}
+	return parts
+}
@@ -0,0 +1,118 @@
+package file_test
+
+import (
+	"copy-images/file"
+	"copy-images/model"
+	"copy-images/runs"
+	"errors"
+	"io/ioutil"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPlannerCreatesMoveAndCopyOperations(t *testing.T) {
+
+	//GIVEN
+	var filesToCopy []model.FileInfo
+	file.CollectFiles(path.Join(basicTestDir, "subdir", "subsubdir"), &filesToCopy, basicCollectConfig)
+	tempDir := t.TempDir()
+	cutoffDate, _ := time.Parse("2006-01-02", "2021-03-03")
+	filesToCopy[0].CreationDate, _ = time.Parse("2006-01-02", "2021-03-02")
+	planner := file.Planner{TargetDir: tempDir, CutoffDate: cutoffDate}
+
+	//WHEN
+	fileOps, err := planner.Plan(filesToCopy)
+
+	//THEN
+	assert.Nil(t, err, "No error must be thrown")
+	assert.Equal(t, 4, len(fileOps.FileOperations))
+	assert.Equal(t, model.MoveOp, fileOps.FileOperations[0].OpType)
+	assert.Equal(t, path.Join(tempDir, "2021", "March", "test.gif"), fileOps.FileOperations[0].To)
+	assert.Equal(t, model.CopyOp, fileOps.FileOperations[1].OpType)
+
+}
+
+func TestExecutorAppliesEditedPlan(t *testing.T) {
+
+	//GIVEN
+	sourceDir := t.TempDir()
+	targetDir := t.TempDir()
+	var sourceFiles []model.FileInfo = copyFilesToTemp(basicTestDir, sourceDir)
+	planner := file.Planner{TargetDir: targetDir}
+	fileOps, _ := planner.Plan(sourceFiles[:2])
+	descFile := path.Join(targetDir, "plan.json")
+	file.WriteFileOperations(descFile, fileOps)
+	//a reviewer renames the destination and turns the first copy into a move
+	editedOps, _ := file.ReadFileOperations(descFile)
+	editedOps.FileOperations[0].To = path.Join(targetDir, "reviewed", "first.gif")
+	editedOps.FileOperations[0].OpType = model.MoveOp
+
+	//WHEN
+	var result = file.Executor{}.Apply(editedOps)
+
+	//THEN
+	assert.Nil(t, result, "No error must be thrown")
+	assert.True(t, fileExists(path.Join(targetDir, "reviewed", "first.gif")), "The edited destination must be used")
+	assert.False(t, fileExists(sourceFiles[0].Path), "The moved source must be removed")
+	assert.True(t, fileExists(sourceFiles[1].Path), "The copied source must be kept")
+	assert.True(t, fileExists(fileOps.FileOperations[1].To))
+
+}
+
+func TestExecutorRejectsInvalidPlanWithoutTouchingFiles(t *testing.T) {
+
+	//GIVEN
+	sourceDir := t.TempDir()
+	targetDir := t.TempDir()
+	var sourceFiles []model.FileInfo = copyFilesToTemp(basicTestDir, sourceDir)
+	fileOps := model.FileOperations{FileOperations: []model.FileOperation{
+		{From: sourceFiles[0].Path, To: path.Join(targetDir, "a.gif"), OpType: model.MoveOp},
+		{From: sourceFiles[1].Path, To: path.Join(targetDir, "a.gif"), OpType: model.CopyOp},
+		{From: sourceFiles[2].Path, To: path.Join(targetDir, "b.gif"), OpType: "RENAME"},
+		{From: sourceFiles[3].Path, OpType: model.CopyOp},
+	}}
+
+	//WHEN
+	var result = file.Executor{}.Apply(fileOps)
+
+	//THEN
+	assert.NotNil(t, result, "The invalid plan must be rejected")
+	assert.Contains(t, result.Error(), "operation 2: destination")
+	assert.Contains(t, result.Error(), "operation 3: unknown type \"RENAME\"")
+	assert.Contains(t, result.Error(), "operation 4: from and to are required")
+	assert.True(t, fileExists(sourceFiles[0].Path), "Nothing must be executed")
+	assert.False(t, fileExists(path.Join(targetDir, "a.gif")), "Nothing must be executed")
+
+}
+
+func TestExecutorNeverOverwritesAnExistingDestination(t *testing.T) {
+
+	//GIVEN
+	sourceDir := t.TempDir()
+	targetDir := t.TempDir()
+	march, _ := time.Parse("2006-01-02", "2021-03-03")
+	april, _ := time.Parse("2006-01-02", "2021-04-04")
+	photo := model.FileInfo{Path: writeFile(t, path.Join(sourceDir, "IMG_1.jpg"), "new photo"), CreationDate: march}
+	fileOps, _ := file.Planner{TargetDir: targetDir, CutoffDate: april}.Plan([]model.FileInfo{photo})
+	libraryFile := writeFile(t, fileOps.FileOperations[0].To, "library photo")
+	runID := runs.NewID()
+	journal := runs.NewJournal(targetDir, runID)
+
+	//WHEN
+	err := file.Executor{Progress: ioutil.Discard, Journal: journal}.Apply(fileOps)
+	journal.Close()
+	records, _ := runs.ReadJournal(targetDir, runID)
+	report := file.Undo(targetDir, records)
+
+	//THEN
+	assert.True(t, errors.Is(err, file.ErrDestinationExists), "The stale plan must fail")
+	content, _ := ioutil.ReadFile(libraryFile)
+	assert.Equal(t, "library photo", string(content), "The existing file must not be overwritten")
+	assert.True(t, fileExists(photo.Path), "The source of the move must be kept")
+	assert.Empty(t, report.Removed, "An undo must not remove the existing file")
+	assert.True(t, fileExists(libraryFile))
+
+}
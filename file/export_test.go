@@ -0,0 +1,27 @@
+package file
+
+import "os"
+
+// SetHashDestination replaces the function reading back copies until the returned restore function is called
+func SetHashDestination(hasher func(path string) (string, error)) (restore func()) {
+	previous := hashDestination
+	hashDestination = hasher
+	return func() { hashDestination = previous }
+}
+
+// SetReadDir replaces the function reading dirs while walking until the returned restore function is called
+func SetReadDir(reader func(dir string) ([]os.DirEntry, error)) (restore func()) {
+	previous := readDir
+	readDir = reader
+	return func() { readDir = previous }
+}
+
+// SetFreeSpace replaces the function reading the free space of file systems until the returned restore function is called
+func SetFreeSpace(reader func(path string) (int64, error)) (restore func()) {
+	previous := freeSpace
+	freeSpace = reader
+	return func() { freeSpace = previous }
+}
+
+// CopyExclusive exposes the publishing of copies on file systems without hard links
+var CopyExclusive = copyExclusive
@@ -1,174 +1,182 @@
 package file
 
 import (
+	"copy-images/dates"
+	"copy-images/index"
+	"copy-images/layout"
 	"copy-images/model"
+	"copy-images/trash"
 	"copy-images/utils"
-	"encoding/json"
 	"fmt"
-	"io/ioutil"
 	"log"
 	"os"
 	"path"
 	"path/filepath"
-	"strconv"
 	"strings"
 	"time"
 )
 
 //CollectFilesConfig describes the configuration for the CollectFiles function
 type CollectFilesConfig struct {
+	// ExcludedDirs are gitignore-style rules like "Android/data" or "!DCIM/Camera/keep/**" matched against the paths
+	// relative to the root, see package ignore. The rules of a .copyimagesignore file in the root are added after them.
 	ExcludedDirs        []string
 	SupportedExtensions []string
+	// Sniff detects the type of every file from its content. Files of a supported type are collected whatever their
+	// extension, files with a supported extension are collected whatever their content.
+	Sniff bool
+	// Takeout matches the json sidecars with the naming quirks of Google Takeout exports, see takeout.Match, and reads
+	// the locations of the files from them
+	Takeout bool
+	// DateResolvers determine the CreationDate of the files, dates.DefaultChain or with Takeout dates.TakeoutChain is
+	// used if it is empty
+	DateResolvers dates.Chain
+	// Workers is the number of dirs read concurrently, DefaultWalkWorkers if it is 0
+	Workers int
+	// Hash selects the files whose content hash is computed while walking, so that hashing overlaps with reading the
+	// dirs instead of waiting for the Planner, see CopyConfig.HashNeeded. No file is hashed if it is nil.
+	Hash func(model.FileInfo) bool
 }
 
-//visit returns a function which collects all fileInfos having the correct file extension
-func visit(files *[]model.FileInfo, collectFilesConfig CollectFilesConfig) filepath.WalkFunc {
-	return func(path string, info os.FileInfo, err error) error {
-		if err != nil {
-			log.Fatal(err)
-		}
-		// we skip the dir if it is included in the Excluded dirs
-		if info.IsDir() {
-			for _, excludedDir := range collectFilesConfig.ExcludedDirs {
-				if strings.Contains(strings.ToLower(path), strings.ToLower(excludedDir)) {
-					return filepath.SkipDir
-				}
-			}
-		}
-		// if the file does not match the  supported extensions or it is a dir we just return
-		if !utils.ItemExists(collectFilesConfig.SupportedExtensions, strings.ToLower(filepath.Ext(path))) || info.IsDir() {
-			return nil
+//VideoExtensions are the file extensions of the supported video containers
+var VideoExtensions = []string{".mp4", ".mov", ".3gp", ".3g2", ".m4v"}
+
+//CopyConfig describes the configuration for the PrepareCopy and CopyFilesTo functions
+type CopyConfig struct {
+	// VideosDir is a dir relative to the target all videos are sorted into, if empty videos are sorted like photos
+	VideosDir string
+	// Layout renders the destination of a file relative to the target, layout.Default is used if it is nil
+	Layout *layout.Template
+	// SkipDuplicates hashes all files and skips the ones whose content already exists in the target or is copied
+	// by an earlier operation
+	SkipDuplicates bool
+	// FixExtensions replaces the extension of destinations which does not match the sniffed content of the file
+	FixExtensions bool
+	// Index records the imported files, files it knows as already imported are skipped. It is not used if it is nil
+	Index *index.Index
+}
+
+//HashNeeded checks if the Planner hashes the content of the file: with SkipDuplicates every file which the Index does
+//not know as imported is hashed
+func (c CopyConfig) HashNeeded(f model.FileInfo) bool {
+	if !c.SkipDuplicates {
+		return false
+	}
+	if c.Index != nil {
+		if _, imported := c.Index.Imported(f); imported {
+			return false
 		}
-		var currentImage = model.FileInfo{Path: path, CreationDate: info.ModTime()}
-		*files = append(*files, currentImage)
-		return nil
 	}
+	return true
+}
+
+//mediaType determines the model.MediaType according to the file extension
+func mediaType(path string) model.MediaType {
+	if utils.ItemExists(VideoExtensions, strings.ToLower(filepath.Ext(path))) {
+		return model.VideoMedia
+	}
+	return model.PhotoMedia
 }
 
-// CollectFiles collects all files according to the given collectFilesConfig in the provided files array
+// CollectFiles collects all files according to the given collectFilesConfig in the provided files array.
+// The files are walked concurrently by WalkFiles and appended in the order filepath.Walk would visit them.
+// The Planner needs all files before it starts: RAW+JPEG pairs are only complete once their dir has been read, the
+// destinations are numbered in the order of the files and duplicates are only searched among the target files having
+// the size of a collected one. Only the hashing, the expensive part of planning, overlaps with the walk, see
+// CollectFilesConfig.Hash.
 func CollectFiles(rootDir string, files *[]model.FileInfo, collectFilesConfig CollectFilesConfig) error {
-	return filepath.Walk(rootDir, visit(files, collectFilesConfig))
+	found := make(chan model.FileInfo)
+	walkErr := make(chan error, 1)
+	go func() {
+		walkErr <- WalkFiles(rootDir, found, collectFilesConfig)
+	}()
+	var collected []model.FileInfo
+	for f := range found {
+		collected = append(collected, f)
+	}
+	SortFiles(collected)
+	*files = append(*files, collected...)
+	return <-walkErr
 }
 
 // PrepareCopy creates a a json file according to model.FileOperations
-// describing all file file operations which would be performend by a real copy
-func PrepareCopy(targetDir string, filesToCopy []model.FileInfo, descFileName string, cutoffDate time.Time) error {
-	copiedFileNames := make(map[string]int)
-
-	copyDescription := model.FileOperations{FileOperations: make([]model.FileOperation, 0)}
-	for _, fileToCopy := range filesToCopy {
-		var createionYear int = fileToCopy.CreationDate.Year()
-		var creationMonth time.Month = fileToCopy.CreationDate.Month()
-		pathWithCreationDate := path.Join(strconv.Itoa(createionYear), creationMonth.String())
-		destinationPath := path.Join(targetDir, pathWithCreationDate)
-
-		fileName := path.Base(fileToCopy.Path)
-		//check if the file name has already been copied if yes create a new filename in order to not override elements in the destination
-		if val, ok := copiedFileNames[path.Base(fileToCopy.Path)]; ok {
-			copiedFileNames[path.Base(fileToCopy.Path)] = val + 1
-			fileName = strings.Replace(fileName, path.Ext(fileName), "_"+strconv.Itoa(val+1)+path.Ext(fileName), 1)
-		} else {
-			copiedFileNames[path.Base(fileToCopy.Path)] = 0
-		}
-		absolutePath, _ := filepath.Abs(fileToCopy.Path)
-
-		// determine the action type of the operation
-		opType := operationType(fileToCopy, cutoffDate)
-
-		copyDescription.FileOperations = append(
-			copyDescription.FileOperations,
-			model.FileOperation{
-				From:   absolutePath,
-				To:     path.Join(destinationPath, fileName),
-				OpType: opType,
-			})
-
+// describing all file file operations which would be performend by a real copy, including the estimate of the space
+// they need in the target, see EstimateSpace
+func PrepareCopy(targetDir string, filesToCopy []model.FileInfo, descFileName string, cutoffDate time.Time, copyConfig CopyConfig) error {
+	planner := Planner{TargetDir: targetDir, CutoffDate: cutoffDate, CopyConfig: copyConfig}
+	copyDescription, err := planner.Plan(filesToCopy)
+	if err != nil {
+		return err
 	}
+	estimate, err := EstimateSpace(copyDescription, DefaultSpaceReserve)
+	if err != nil {
+		return err
+	}
+	copyDescription.Space = &estimate
 	//lets write the json
-	// current_time := time.Now()
-	// copy_desc_"+current_time.Format("2006-01-02-15:04:05")+".json")
-	desc, _ := json.MarshalIndent(copyDescription, "", "     ")
-	err := ioutil.WriteFile(path.Join(targetDir, descFileName), desc, 0644)
+	err = WriteFileOperations(path.Join(targetDir, descFileName), copyDescription)
 	fmt.Println(path.Join(targetDir, descFileName) + " written!")
 
 	return err
 }
 
-//operationType returns the a valid model.ActionType according to the cutoffDate. All files created on and after the cutoffDate will be copied
-func operationType(fileInfo model.FileInfo, cutoffDate time.Time) model.OpType {
-
-	if fileInfo.CreationDate.Before(cutoffDate) {
-		return model.MoveOp
+//CopyFilesTo copies all filesToCopy to the targetDir. Nothing is copied and a *SpaceError is returned if the files
+//do not fit into the target. No space is reserved, the commands keep the DefaultSpaceReserve free.
+func CopyFilesTo(targetDir string, filesToCopy []model.FileInfo, copyConfig CopyConfig) error {
+	//without a cutoff date all operations are copies
+	planner := Planner{TargetDir: targetDir, CopyConfig: copyConfig}
+	fileOps, err := planner.Plan(filesToCopy)
+	if err != nil {
+		return err
 	}
-	return model.CopyOp
-}
-
-//CopyFilesTo copies all filesToCopy to the targetDir
-func CopyFilesTo(targetDir string, filesToCopy []model.FileInfo) error {
-	copiedFileNames := make(map[string]int)
-
-	numberOfImagesToCopy := len(filesToCopy)
-	for index, fileToCopy := range filesToCopy {
-
-		input, err := ioutil.ReadFile(fileToCopy.Path)
-		if err != nil {
-			return err
-		}
-		fmt.Printf("Copying %d/%d %s ... \n", (index + 1), numberOfImagesToCopy, fileToCopy.Path)
-		var createionYear int = fileToCopy.CreationDate.Year()
-		var creationMonth time.Month = fileToCopy.CreationDate.Month()
-
-		pathWithCreationDate := path.Join(strconv.Itoa(createionYear), creationMonth.String())
-		destinationPath := path.Join(targetDir, pathWithCreationDate)
-
-		//create the destination path
-		err = os.MkdirAll(destinationPath, os.ModePerm)
-		if err != nil {
-			return err
-		}
-		fileName := path.Base(fileToCopy.Path)
-		//check if the file name has already been copied if yes create a new filename in order to not override elements in the destination
-		if val, ok := copiedFileNames[path.Base(fileToCopy.Path)]; ok {
-			copiedFileNames[path.Base(fileToCopy.Path)] = val + 1
-			fileName = strings.Replace(fileName, path.Ext(fileName), "_"+strconv.Itoa(val+1)+path.Ext(fileName), 1)
-		} else {
-			copiedFileNames[path.Base(fileToCopy.Path)] = 0
-		}
-
-		err = ioutil.WriteFile(path.Join(destinationPath, fileName), input, 0644)
-		if err != nil {
-			return err
-		}
+	estimate, err := EstimateSpace(fileOps, 0)
+	if err != nil {
+		return err
 	}
-	return nil
+	if !estimate.Sufficient() {
+		return &SpaceError{Estimate: estimate}
+	}
+	return Executor{Index: copyConfig.Index}.Apply(fileOps)
 }
 
-//DeleteFiles removes all given files from the file-system
-func DeleteFiles(files []model.FileInfo) error {
+//DeleteFiles puts all given files and their sidecars into the trash, they are removed from the file-system for good
+//if bin is nil
+func DeleteFiles(files []model.FileInfo, bin trash.Trash) error {
 	numberOfFilesToDelete := len(files)
 	for index, fileToRemove := range files {
 		fmt.Printf("Removing %d/%d %s ... \n", (index + 1), numberOfFilesToDelete, fileToRemove.Path)
-		e := os.Remove(fileToRemove.Path)
-		if e != nil {
-			//if we cannot delete just print a log
-			log.Print(e)
+		for _, filePath := range append([]string{fileToRemove.Path}, fileToRemove.Sidecars...) {
+			_, e := deleteFile(filePath, bin)
+			if e != nil {
+				//if we cannot delete just print a log
+				log.Print(e)
+			}
 		}
 	}
 	return nil
 }
 
-// DeleteFilesCreatedBefore removes all files form the filesystem which have a creation date smaller than provided cutoffDate
-func DeleteFilesCreatedBefore(cutoffDate time.Time, files []model.FileInfo) []model.FileInfo {
+// DeleteFilesCreatedBefore puts all files which have a creation date smaller than provided cutoffDate into the trash,
+// see DeleteFiles. RAW+JPEG pairs are removed together if their RAW file is older, see groupFiles
+func DeleteFilesCreatedBefore(cutoffDate time.Time, files []model.FileInfo, bin trash.Trash) []model.FileInfo {
 	//filter the files matching the cutoffDate
 	var filteredFiles []model.FileInfo
 
-	for _, file := range files {
-		if file.CreationDate.Before(cutoffDate) {
-			filteredFiles = append(filteredFiles, file)
+	for _, group := range groupFiles(files) {
+		if group.primary.CreationDate.Before(cutoffDate) {
+			filteredFiles = append(filteredFiles, group.members()...)
 		}
 	}
 	//ok now delete the files
-	DeleteFiles(filteredFiles)
+	DeleteFiles(filteredFiles, bin)
 
 	return filteredFiles
 }
+
+//deleteFile puts the file into the trash and returns where it has been put, without trash it is removed for good
+func deleteFile(filePath string, bin trash.Trash) (string, error) {
+	if bin == nil {
+		return "", os.Remove(filePath)
+	}
+	return bin.Put(filePath)
+}
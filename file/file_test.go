@@ -2,6 +2,7 @@ package file_test
 
 import (
 	"copy-images/file"
+	"copy-images/layout"
 	"copy-images/model"
 	"encoding/json"
 	"fmt"
@@ -132,6 +133,69 @@ func TestThatCorrectDateIsAttached(t *testing.T) {
 	assert.Nil(t, result, "No error must be thrown")
 	assert.Equal(t, 2021, filesToCopy[0].CreationDate.Year())
 	assert.Equal(t, time.August, filesToCopy[0].CreationDate.Month())
+	assert.Equal(t, model.ModTimeSource, filesToCopy[0].DateSource)
+
+}
+
+func TestThatExifDateIsPreferredOverModTime(t *testing.T) {
+
+	//GIVEN
+	var testDir string = path.Join("..", "exif", "testdata")
+	var filesToCopy []model.FileInfo
+
+	//WHEN
+	var result = file.CollectFiles(testDir, &filesToCopy, basicCollectConfig)
+
+	//THEN
+	assert.Nil(t, result, "No error must be thrown")
+	assert.Equal(t, 3, len(filesToCopy), "3 jpg files must be found")
+	assert.Equal(t, path.Join(testDir, "big_endian.jpg"), filesToCopy[0].Path)
+	assert.Equal(t, 2018, filesToCopy[0].CreationDate.Year())
+	assert.Equal(t, model.ExifSource, filesToCopy[0].DateSource)
+	assert.Equal(t, 2019, filesToCopy[1].CreationDate.Year())
+	assert.Equal(t, model.ExifSource, filesToCopy[1].DateSource)
+	assert.Equal(t, path.Join(testDir, "no_exif.jpg"), filesToCopy[2].Path)
+	assert.Equal(t, model.ModTimeSource, filesToCopy[2].DateSource, "Without exif the modification time must be used")
+
+}
+
+func TestThatVideosAreCollectedWithContainerDate(t *testing.T) {
+
+	//GIVEN
+	var testDir string = path.Join("..", "mp4", "testdata")
+	var filesToCopy []model.FileInfo
+	var collectFilesConfig file.CollectFilesConfig = file.CollectFilesConfig{ExcludedDirs: []string{}, SupportedExtensions: file.VideoExtensions}
+
+	//WHEN
+	var result = file.CollectFiles(testDir, &filesToCopy, collectFilesConfig)
+
+	//THEN
+	assert.Nil(t, result, "No error must be thrown")
+	assert.Equal(t, 4, len(filesToCopy), "4 videos must be found")
+	assert.Equal(t, path.Join(testDir, "creation_time.mp4"), filesToCopy[0].Path)
+	assert.Equal(t, model.VideoMedia, filesToCopy[0].MediaType)
+	assert.Equal(t, model.ContainerSource, filesToCopy[0].DateSource)
+	assert.Equal(t, 2021, filesToCopy[0].CreationDate.Year())
+	assert.Equal(t, model.ModTimeSource, filesToCopy[1].DateSource, "The video without creation time must use the modification time")
+
+}
+
+func TestThatVideosAreCopiedToVideosDir(t *testing.T) {
+
+	//GIVEN
+	var filesToCopy []model.FileInfo
+	var collectFilesConfig file.CollectFilesConfig = file.CollectFilesConfig{ExcludedDirs: []string{}, SupportedExtensions: append(basicExtensions, file.VideoExtensions...)}
+	file.CollectFiles(path.Join("..", "mp4", "testdata"), &filesToCopy, collectFilesConfig)
+	file.CollectFiles(path.Join(basicTestDir, "subdir", "subsubdir"), &filesToCopy, collectFilesConfig)
+	tempDir := t.TempDir()
+
+	//WHEN
+	var result = file.CopyFilesTo(tempDir, filesToCopy, file.CopyConfig{VideosDir: "Videos"})
+
+	//THEN
+	assert.Nil(t, result, "No error must be thrown")
+	assert.True(t, fileExists(path.Join(tempDir, "Videos", "2021", filesToCopy[0].CreationDate.Month().String(), "creation_time.mp4")), "Videos must be copied to the videos dir")
+	assert.True(t, fileExists(path.Join(tempDir, "2021", "August", "test.jpg")), "Photos must be copied to the target")
 
 }
 
@@ -144,7 +208,7 @@ func TestThatFilesAreCopiedToTargetDir(t *testing.T) {
 	tempDir := t.TempDir()
 
 	//WHEN
-	var result = file.CopyFilesTo(tempDir, filesToCopy)
+	var result = file.CopyFilesTo(tempDir, filesToCopy, file.CopyConfig{})
 
 	//THEN
 	var copiedFiles []model.FileInfo
@@ -163,7 +227,7 @@ func TestThatFilesAreCopiedAndSortedAccordingToCreationDate(t *testing.T) {
 	tempDir := t.TempDir()
 
 	//WHEN
-	var result = file.CopyFilesTo(tempDir, filesToCopy)
+	var result = file.CopyFilesTo(tempDir, filesToCopy, file.CopyConfig{})
 
 	//THEN
 	var copiedFiles []model.FileInfo
@@ -185,7 +249,7 @@ func TestCopyFilesWithEqualFileNames(t *testing.T) {
 	tempDir := t.TempDir()
 
 	//WHEN
-	var result = file.CopyFilesTo(tempDir, filesToCopy)
+	var result = file.CopyFilesTo(tempDir, filesToCopy, file.CopyConfig{})
 
 	//THEN
 	var copiedFiles []model.FileInfo
@@ -200,6 +264,32 @@ func TestCopyFilesWithEqualFileNames(t *testing.T) {
 
 }
 
+func TestThatLayoutDrivesPlanAndCopy(t *testing.T) {
+
+	//GIVEN
+	var filesToCopy []model.FileInfo
+	file.CollectFiles(basicTestDir, &filesToCopy, basicCollectConfig)
+	planDir := t.TempDir()
+	copyDir := t.TempDir()
+	copyConfig := file.CopyConfig{Layout: layout.MustParse("{year}/{month:02}/{parent}_{basename}{ext}")}
+
+	//WHEN
+	var planResult = file.PrepareCopy(planDir, filesToCopy, "test_desc.json", time.Time{}, copyConfig)
+	var copyResult = file.CopyFilesTo(copyDir, filesToCopy, copyConfig)
+
+	//THEN
+	assert.Nil(t, planResult, "No error must be thrown")
+	assert.Nil(t, copyResult, "No error must be thrown")
+	fileOps, _ := file.ReadFileOperations(path.Join(planDir, "test_desc.json"))
+	assert.Equal(t, path.Join(planDir, "2021", "08", "subsubdir_test.gif"), fileOps.FileOperations[0].To)
+	assert.Equal(t, path.Join(planDir, "2021", "08", "subdir_test.gif"), fileOps.FileOperations[4].To)
+	for _, fileOp := range fileOps.FileOperations {
+		relativePath, _ := filepath.Rel(planDir, fileOp.To)
+		assert.True(t, fileExists(path.Join(copyDir, relativePath)), "Plan and copy must use the same destination for %s", fileOp.From)
+	}
+
+}
+
 func TestDeleteFilesRemovesFilesFromFileSystem(t *testing.T) {
 
 	//GIVEN
@@ -209,7 +299,7 @@ func TestDeleteFilesRemovesFilesFromFileSystem(t *testing.T) {
 
 	//WHEN
 	//remove them
-	file.DeleteFiles(copiedFiles)
+	file.DeleteFiles(copiedFiles, nil)
 
 	//THEN
 	var emptyFiles []model.FileInfo
@@ -233,7 +323,7 @@ func TestDeleteFilesRemovesFilesFromFileSystemCreatedBefore(t *testing.T) {
 
 	//WHEN
 	//remove them
-	var deletedFiles []model.FileInfo = file.DeleteFilesCreatedBefore(cutoffDate, copiedFiles)
+	var deletedFiles []model.FileInfo = file.DeleteFilesCreatedBefore(cutoffDate, copiedFiles, nil)
 
 	//THEN
 	var keptFiles []model.FileInfo
@@ -264,7 +354,7 @@ func TestDeleteFilesRemovesFilesFromFileSystemCreatedBefore_DoesNotRemoveExactCu
 
 	//WHEN
 	//remove them
-	var deletedFiles []model.FileInfo = file.DeleteFilesCreatedBefore(cutoffDate, copiedFiles)
+	var deletedFiles []model.FileInfo = file.DeleteFilesCreatedBefore(cutoffDate, copiedFiles, nil)
 
 	//THEN
 	var keptFiles []model.FileInfo
@@ -289,7 +379,7 @@ func TestDeleteFilesRemovesFilesFromFileSystemCreatedBefore_differentMonth(t *te
 
 	//WHEN
 	//remove them
-	var deletedFiles []model.FileInfo = file.DeleteFilesCreatedBefore(cutoffDate, copiedFiles)
+	var deletedFiles []model.FileInfo = file.DeleteFilesCreatedBefore(cutoffDate, copiedFiles, nil)
 
 	//THEN
 	var keptFiles []model.FileInfo
@@ -319,7 +409,7 @@ func TestDeleteFilesRemovesFilesFromFileSystemCreatedBefore_differentYear(t *tes
 
 	//WHEN
 	//remove them
-	var deletedFiles []model.FileInfo = file.DeleteFilesCreatedBefore(cutoffDate, copiedFiles)
+	var deletedFiles []model.FileInfo = file.DeleteFilesCreatedBefore(cutoffDate, copiedFiles, nil)
 
 	//THEN
 	var keptFiles []model.FileInfo
@@ -344,7 +434,7 @@ func TestPerpareCopyCreatesJson(t *testing.T) {
 	tempDir := t.TempDir()
 
 	//WHEN
-	var result = file.PrepareCopy(tempDir, filesToCopy, "test_desc.json", time.Now())
+	var result = file.PrepareCopy(tempDir, filesToCopy, "test_desc.json", time.Now(), file.CopyConfig{})
 
 	//THEN
 	assert.Nil(t, result, "No error must be thrown")
@@ -363,7 +453,7 @@ func TestPerpareCopyCreatesJsonWithCorrectFileOperations(t *testing.T) {
 	tempDir := t.TempDir()
 
 	//WHEN
-	var result = file.PrepareCopy(tempDir, filesToCopy, "test_desc.json", time.Now())
+	var result = file.PrepareCopy(tempDir, filesToCopy, "test_desc.json", time.Now(), file.CopyConfig{})
 
 	//THEN
 	assert.Nil(t, result, "No error must be thrown")
@@ -382,6 +472,7 @@ func TestPerpareCopyCreatesJsonWithCorrectFileOperations(t *testing.T) {
 	assert.Equal(t, path.Join(tempDir, "2021", "August", "test.jpg"), copyDesc.FileOperations[2].To)
 	assert.Equal(t, path.Join(anbsoluteTestDir, "test.png"), copyDesc.FileOperations[3].From)
 	assert.Equal(t, path.Join(tempDir, "2021", "August", "test.png"), copyDesc.FileOperations[3].To)
+	assert.Equal(t, model.ModTimeSource, copyDesc.FileOperations[3].DateSource, "The date source must be part of the plan")
 
 }
 
@@ -401,7 +492,7 @@ func TestPerpareCopyCreatesDescJsonWithCorrectOperationType(t *testing.T) {
 	filesToCopy[2].CreationDate, _ = time.Parse("2006-01-02", "2021-03-03")
 
 	//WHEN
-	var result = file.PrepareCopy(tempDir, filesToCopy, "test_desc.json", cutoffDate)
+	var result = file.PrepareCopy(tempDir, filesToCopy, "test_desc.json", cutoffDate, file.CopyConfig{})
 
 	//THEN
 	assert.Nil(t, result, "No error must be thrown")
@@ -419,6 +510,50 @@ func TestPerpareCopyCreatesDescJsonWithCorrectOperationType(t *testing.T) {
 
 }
 
+func TestApplyFileOperationsCopiesAndMovesFiles(t *testing.T) {
+
+	//GIVEN
+	sourceDir := t.TempDir()
+	targetDir := t.TempDir()
+	var sourceFiles []model.FileInfo = copyFilesToTemp(basicTestDir, sourceDir)
+	cutoffDate, _ := time.Parse("2006-01-02", "2021-03-03")
+	sourceFiles[0].CreationDate, _ = time.Parse("2006-01-02", "2021-03-02")
+	file.PrepareCopy(targetDir, sourceFiles, "test_desc.json", cutoffDate, file.CopyConfig{})
+	fileOps, _ := file.ReadFileOperations(path.Join(targetDir, "test_desc.json"))
+
+	//WHEN
+	var result = file.Executor{}.Apply(fileOps)
+
+	//THEN
+	assert.Nil(t, result, "No error must be thrown")
+	var copiedFiles []model.FileInfo
+	file.CollectFiles(targetDir, &copiedFiles, basicCollectConfig)
+	assert.Equal(t, 12, len(copiedFiles), "All 12 files must be copied")
+	var keptFiles []model.FileInfo
+	file.CollectFiles(sourceDir, &keptFiles, basicCollectConfig)
+	assert.Equal(t, 11, len(keptFiles), "The moved file must be removed from the source")
+	assert.Empty(t, file.VerifyFileOperations(fileOps), "All operations must verify")
+
+}
+
+func TestVerifyFileOperationsReportsMissingDestinations(t *testing.T) {
+
+	//GIVEN
+	var testDir string = path.Join(basicTestDir, "subdir", "subsubdir")
+	var filesToCopy []model.FileInfo
+	file.CollectFiles(testDir, &filesToCopy, basicCollectConfig)
+	tempDir := t.TempDir()
+	file.PrepareCopy(tempDir, filesToCopy, "test_desc.json", time.Time{}, file.CopyConfig{})
+	fileOps, _ := file.ReadFileOperations(path.Join(tempDir, "test_desc.json"))
+
+	//WHEN
+	var problems = file.VerifyFileOperations(fileOps)
+
+	//THEN
+	assert.Equal(t, 4, len(problems), "No file has been copied yet")
+
+}
+
 // fileExists checks if a file exists and is not a directory before we
 // try using it to prevent further errors.
 func fileExists(filename string) bool {
@@ -434,7 +569,7 @@ func copyFilesToTemp(sourceDir string, tempDir string) []model.FileInfo {
 	var filesToCopy []model.FileInfo
 	file.CollectFiles(basicTestDir, &filesToCopy, basicCollectConfig)
 	//copy to temp dir
-	file.CopyFilesTo(tempDir, filesToCopy)
+	file.CopyFilesTo(tempDir, filesToCopy, file.CopyConfig{})
 	var copiedFiles []model.FileInfo
 	//find all the copied files
 	file.CollectFiles(tempDir, &copiedFiles, basicCollectConfig)
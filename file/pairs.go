@@ -0,0 +1,73 @@
+package file
+
+import (
+	"copy-images/model"
+	"copy-images/utils"
+	"path/filepath"
+	"strings"
+)
+
+//RawExtensions are the file extensions of the supported camera RAW formats
+var RawExtensions = []string{".cr2", ".cr3", ".nef", ".arw", ".dng", ".orf", ".rw2"}
+
+//isRaw checks if the file has one of the RawExtensions
+func isRaw(path string) bool {
+	return utils.ItemExists(RawExtensions, strings.ToLower(filepath.Ext(path)))
+}
+
+//fileGroup is a file with the companions which are copied, moved and deleted together with it
+type fileGroup struct {
+	primary    model.FileInfo
+	companions []model.FileInfo
+}
+
+//members returns the primary followed by the companions
+func (g fileGroup) members() []model.FileInfo {
+	return append([]model.FileInfo{g.primary}, g.companions...)
+}
+
+//groupFiles pairs every RAW file with the other photos sharing its basename in the same dir, like IMG_1.CR2 and
+//IMG_1.JPG. The first RAW file of a basename is the primary of the group, the others are its companions. Videos and
+//a second file with the same extension are never paired. The groups keep the order of their first member.
+func groupFiles(files []model.FileInfo) []fileGroup {
+	primaries := make(map[string]int)
+	for i, f := range files {
+		if _, ok := primaries[pairKey(f.Path)]; !ok && isRaw(f.Path) {
+			primaries[pairKey(f.Path)] = i
+		}
+	}
+	groups := make([]fileGroup, 0, len(files))
+	positions := make(map[string]int)
+	extensions := make(map[string][]string)
+	for i, f := range files {
+		key := pairKey(f.Path)
+		primary, paired := primaries[key]
+		extension := strings.ToLower(filepath.Ext(f.Path))
+		if paired && i != primary {
+			paired = f.MediaType != model.VideoMedia && extension != strings.ToLower(filepath.Ext(files[primary].Path)) &&
+				!utils.ItemExists(extensions[key], extension)
+		}
+		if !paired {
+			groups = append(groups, fileGroup{primary: f})
+			continue
+		}
+		position, ok := positions[key]
+		if !ok {
+			groups = append(groups, fileGroup{})
+			position = len(groups) - 1
+			positions[key] = position
+		}
+		if i == primary {
+			groups[position].primary = f
+			continue
+		}
+		groups[position].companions = append(groups[position].companions, f)
+		extensions[key] = append(extensions[key], extension)
+	}
+	return groups
+}
+
+//pairKey identifies the files of a dir sharing a basename
+func pairKey(path string) string {
+	return filepath.Join(filepath.Dir(path), strings.ToLower(strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))))
+}
@@ -0,0 +1,150 @@
+package file_test
+
+import (
+	"copy-images/file"
+	"copy-images/model"
+	"copy-images/runs"
+	"copy-images/utils"
+	"io/ioutil"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRawAndJpegArePlannedAsOnePair(t *testing.T) {
+
+	//GIVEN
+	sourceDir := t.TempDir()
+	targetDir := t.TempDir()
+	march, _ := time.Parse("2006-01-02", "2021-03-03")
+	april, _ := time.Parse("2006-01-02", "2021-04-04")
+	writeFile(t, path.Join(targetDir, "2021", "March", "IMG_1.JPG"), "earlier import")
+	filesToCopy := []model.FileInfo{
+		{Path: path.Join(sourceDir, "IMG_1.CR2"), CreationDate: march},
+		{Path: path.Join(sourceDir, "IMG_1.JPG"), CreationDate: april},
+		{Path: path.Join(sourceDir, "IMG_1.MP4"), CreationDate: march, MediaType: model.VideoMedia},
+		{Path: path.Join(sourceDir, "other", "IMG_1.JPG"), CreationDate: march},
+	}
+	planner := file.Planner{TargetDir: targetDir}
+
+	//WHEN
+	fileOps, err := planner.Plan(filesToCopy)
+
+	//THEN
+	assert.Nil(t, err, "No error must be thrown")
+	assert.Equal(t, 3, len(fileOps.FileOperations), "The pair must be one operation")
+	pair := fileOps.FileOperations[0]
+	assert.Equal(t, path.Join(sourceDir, "IMG_1.CR2"), pair.From, "The RAW file must be the primary of the pair")
+	assert.Equal(t, path.Join(targetDir, "2021", "March", "IMG_1_1.CR2"), pair.To, "The pair must get the same suffix")
+	assert.Equal(t, []model.Companion{{From: path.Join(sourceDir, "IMG_1.JPG"), To: path.Join(targetDir, "2021", "March", "IMG_1_1.JPG")}}, pair.Companions, "The JPEG must follow the date of the RAW file")
+	assert.Equal(t, path.Join(sourceDir, "IMG_1.MP4"), fileOps.FileOperations[1].From, "Videos must not be paired")
+	assert.Empty(t, fileOps.FileOperations[1].Companions)
+	assert.Equal(t, path.Join(targetDir, "2021", "March", "IMG_1_2.JPG"), fileOps.FileOperations[2].To, "Files of other dirs must not be paired")
+
+}
+
+func TestPairIsOnlySkippedIfBothHalvesAre(t *testing.T) {
+
+	//GIVEN
+	sourceDir := t.TempDir()
+	targetDir := t.TempDir()
+	march, _ := time.Parse("2006-01-02", "2021-03-03")
+	writeFile(t, path.Join(targetDir, "2021", "March", "IMG_1.jpg"), "jpeg 1")
+	writeFile(t, path.Join(targetDir, "2021", "March", "IMG_2.jpg"), "jpeg 2")
+	writeFile(t, path.Join(targetDir, "2021", "March", "IMG_2.nef"), "raw 2")
+	filesToCopy := []model.FileInfo{
+		{Path: writeFile(t, path.Join(sourceDir, "IMG_1.nef"), "raw 1"), CreationDate: march},
+		{Path: writeFile(t, path.Join(sourceDir, "IMG_1.jpg"), "jpeg 1"), CreationDate: march},
+		{Path: writeFile(t, path.Join(sourceDir, "IMG_2.nef"), "raw 2"), CreationDate: march},
+		{Path: writeFile(t, path.Join(sourceDir, "IMG_2.jpg"), "jpeg 2"), CreationDate: march},
+	}
+	planner := file.Planner{TargetDir: targetDir, CopyConfig: file.CopyConfig{SkipDuplicates: true}}
+
+	//WHEN
+	fileOps, err := planner.Plan(filesToCopy)
+
+	//THEN
+	assert.Nil(t, err, "No error must be thrown")
+	assert.Equal(t, model.CopyOp, fileOps.FileOperations[0].OpType, "A pair with a new half must be copied as a whole")
+	assert.Equal(t, path.Join(targetDir, "2021", "March", "IMG_1_1.jpg"), fileOps.FileOperations[0].Companions[0].To)
+	assert.Equal(t, model.SkipOp, fileOps.FileOperations[1].OpType)
+	assert.Equal(t, "duplicate of 2021/March/IMG_2.nef", fileOps.FileOperations[1].Reason)
+	assert.Equal(t, path.Join(sourceDir, "IMG_2.jpg"), fileOps.FileOperations[1].Companions[0].From)
+
+}
+
+func TestMovedPairKeepsBothSourcesIfOneHalfMismatches(t *testing.T) {
+
+	//GIVEN
+	sourceDir := t.TempDir()
+	targetDir := t.TempDir()
+	raw := writeFile(t, path.Join(sourceDir, "IMG_1.ARW"), "raw")
+	jpeg := writeFile(t, path.Join(sourceDir, "IMG_1.JPG"), "jpeg")
+	fileOps := model.FileOperations{FileOperations: []model.FileOperation{
+		{From: raw, To: path.Join(targetDir, "IMG_1.ARW"), OpType: model.MoveOp, Companions: []model.Companion{{From: jpeg, To: path.Join(targetDir, "IMG_1.JPG")}}},
+	}}
+	manifest := runs.NewManifest()
+	defer file.SetHashDestination(func(filePath string) (string, error) {
+		if filePath == path.Join(targetDir, "IMG_1.JPG") {
+			return "written garbage", nil
+		}
+		return utils.HashFile(filePath)
+	})()
+
+	//WHEN
+	err := file.Executor{Manifest: manifest, Progress: ioutil.Discard}.Apply(fileOps)
+
+	//THEN
+	assert.NotNil(t, err, "The mismatch must be reported")
+	assert.True(t, fileExists(raw), "The intact half must not be removed without the other one")
+	assert.True(t, fileExists(jpeg), "The source of the mismatching copy must be kept")
+	assert.Equal(t, 2, len(manifest.Entries), "Both halves must be recorded")
+	assert.Equal(t, runs.Verified, manifest.Entries[0].Status)
+	assert.Equal(t, runs.Mismatch, manifest.Entries[1].Status)
+
+}
+
+func TestMovedPairRemovesBothSources(t *testing.T) {
+
+	//GIVEN
+	sourceDir := t.TempDir()
+	targetDir := t.TempDir()
+	raw := writeFile(t, path.Join(sourceDir, "IMG_1.dng"), "raw")
+	jpeg := writeFile(t, path.Join(sourceDir, "IMG_1.jpg"), "jpeg")
+	march, _ := time.Parse("2006-01-02", "2021-03-03")
+	april, _ := time.Parse("2006-01-02", "2021-04-04")
+	planner := file.Planner{TargetDir: targetDir, CutoffDate: april}
+	fileOps, _ := planner.Plan([]model.FileInfo{{Path: jpeg, CreationDate: march}, {Path: raw, CreationDate: march}})
+
+	//WHEN
+	err := file.Executor{Progress: ioutil.Discard}.Apply(fileOps)
+
+	//THEN
+	assert.Nil(t, err, "No error must be thrown")
+	assert.False(t, fileExists(raw))
+	assert.False(t, fileExists(jpeg))
+	assert.True(t, fileExists(path.Join(targetDir, "2021", "March", "IMG_1.dng")))
+	assert.True(t, fileExists(path.Join(targetDir, "2021", "March", "IMG_1.jpg")))
+	assert.Empty(t, file.VerifyFileOperations(fileOps))
+
+}
+
+func TestCompanionsNeedADestination(t *testing.T) {
+
+	//GIVEN
+	fileOps := model.FileOperations{FileOperations: []model.FileOperation{
+		{From: "/a/IMG_1.CR2", To: "/b/IMG_1.CR2", OpType: model.CopyOp, Companions: []model.Companion{{From: "/a/IMG_1.JPG"}}},
+		{From: "/a/IMG_2.JPG", To: "/b/IMG_1.CR2", OpType: model.CopyOp},
+	}}
+
+	//WHEN
+	err := file.ValidateFileOperations(fileOps)
+
+	//THEN
+	assert.NotNil(t, err, "The invalid companion must be reported")
+	assert.Contains(t, err.Error(), "operation 1: from and to are required")
+	assert.Contains(t, err.Error(), "operation 2: destination /b/IMG_1.CR2 is already written by operation 1")
+
+}
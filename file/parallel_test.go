@@ -0,0 +1,91 @@
+package file_test
+
+import (
+	"bytes"
+	"copy-images/file"
+	"copy-images/index"
+	"copy-images/model"
+	"copy-images/runs"
+	"fmt"
+	"io/ioutil"
+	"path"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParallelExecutorCopiesEveryFileToItsPlannedDestination(t *testing.T) {
+
+	//GIVEN
+	sourceDir := t.TempDir()
+	targetDir := t.TempDir()
+	march, _ := time.Parse("2006-01-02", "2021-03-03")
+	var filesToCopy []model.FileInfo
+	for i := 0; i < 100; i++ {
+		//every second file has the same name as another one in a different dir
+		name := path.Join(sourceDir, fmt.Sprintf("dir%d", i%2), fmt.Sprintf("IMG_%d.jpg", i/2))
+		filesToCopy = append(filesToCopy, model.FileInfo{Path: writeFile(t, name, fmt.Sprintf("photo %d", i)), CreationDate: march})
+	}
+	idx, _ := index.Load(targetDir)
+	planner := file.Planner{TargetDir: targetDir, CopyConfig: file.CopyConfig{Index: idx}}
+	fileOps, _ := planner.Plan(filesToCopy)
+	secondPlan, _ := planner.Plan(filesToCopy)
+	manifest := runs.NewManifest()
+	var progress bytes.Buffer
+	executor := file.Executor{Workers: 8, Index: idx, Manifest: manifest, VerifyCopies: true, Progress: &progress}
+
+	//WHEN
+	err := executor.Apply(fileOps)
+
+	//THEN
+	assert.Nil(t, err, "No error must be thrown")
+	assert.Equal(t, fileOps, secondPlan, "The destinations must be assigned deterministically")
+	for i, fileOp := range fileOps.FileOperations {
+		copied, _ := ioutil.ReadFile(fileOp.To)
+		assert.Equal(t, fmt.Sprintf("photo %d", i), string(copied))
+		assert.Equal(t, fileOp.From, manifest.Entries[i].From, "The manifest must list the operations in plan order")
+		assert.Equal(t, runs.Verified, manifest.Entries[i].Status)
+	}
+	assert.Equal(t, 100, len(idx.Entries()))
+	lines := strings.Split(strings.TrimSpace(progress.String()), "\n")
+	assert.Equal(t, 100, len(lines))
+	for _, line := range lines {
+		assert.Regexp(t, `^Copying \d+/100 \S+ \.\.\. ?$`, line, "Progress lines must not interleave")
+	}
+
+}
+
+func TestParallelExecutorReportsFailuresAndStopsStartingOperations(t *testing.T) {
+
+	//GIVEN
+	sourceDir := t.TempDir()
+	targetDir := t.TempDir()
+	var ops []model.FileOperation
+	for i := 0; i < 50; i++ {
+		source := path.Join(sourceDir, fmt.Sprintf("IMG_%d.jpg", i))
+		if i != 10 {
+			writeFile(t, source, "photo")
+		}
+		ops = append(ops, model.FileOperation{From: source, To: path.Join(targetDir, fmt.Sprintf("IMG_%d.jpg", i)), OpType: model.MoveOp})
+	}
+	manifest := runs.NewManifest()
+	executor := file.Executor{Workers: 4, Manifest: manifest, Progress: ioutil.Discard}
+
+	//WHEN
+	err := executor.Apply(model.FileOperations{FileOperations: ops})
+
+	//THEN
+	assert.NotNil(t, err, "The missing source must be reported")
+	assert.Contains(t, err.Error(), "IMG_10.jpg")
+	assert.Less(t, len(manifest.Entries), 50, "No operations must be started after the failure")
+	assert.Equal(t, 1, manifest.Count(runs.Failed))
+	for _, entry := range manifest.Entries {
+		if entry.Status == runs.Verified {
+			assert.False(t, fileExists(entry.From), "Finished moves must have removed their source")
+			assert.True(t, fileExists(entry.To))
+		}
+	}
+
+}
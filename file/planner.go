@@ -0,0 +1,258 @@
+package file
+
+import (
+	"copy-images/index"
+	"copy-images/model"
+	"copy-images/retention"
+	"copy-images/utils"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+//Planner creates the model.FileOperations needed to bring files into a target dir.
+//Executor applies them, so plan and copy can never disagree about where a file goes.
+type Planner struct {
+	TargetDir string
+	// CutoffDate separates the files which are moved from the ones which are only copied, a zero date copies all files.
+	// It is the policy of PrepareCopy, the commands use the Retention. Only one of both may be set.
+	CutoffDate time.Time
+	// Retention decides which files are moved, see CutoffDate
+	Retention *retention.Policy
+	// Facts are what the Retention knows beyond the files
+	Facts      retention.Facts
+	CopyConfig CopyConfig
+}
+
+//ErrTwoPolicies is returned by Planner.Plan if both a cutoff date and a retention policy decide which files are moved
+var ErrTwoPolicies = errors.New("either a cutoff date or a retention policy decides which files are moved, not both")
+
+//Plan returns one operation for every file in the order of the files. RAW files and the photos sharing their basename
+//are planned as one operation with companions, see groupFiles, so that they are never separated. The sidecars of all
+//files of the operation are companions as well, they follow the decisions taken for their file.
+//Files the CopyConfig.Index knows as imported and, with CopyConfig.SkipDuplicates, files whose content already exists
+//in the target get a model.SkipOp. A group is only skipped if all of its files are, otherwise all of them are copied.
+//A skipped group which would be moved gets a model.DeleteOp instead if the copies of all its files still exist.
+//With a Retention the groups it deletes are moved, skipped groups it deletes get a model.DeleteOp. A skipped group is
+//only deleted if the copies of all its files still exist, see existingCopies. Setting both the CutoffDate and a
+//Retention returns ErrTwoPolicies.
+func (p Planner) Plan(files []model.FileInfo) (model.FileOperations, error) {
+	if p.Retention != nil && !p.CutoffDate.IsZero() {
+		return model.FileOperations{}, ErrTwoPolicies
+	}
+	namer := newDestinationNamer(p.TargetDir, p.CopyConfig)
+	var contents *contentIndex
+	if p.CopyConfig.SkipDuplicates {
+		var err error
+		if contents, err = newContentIndex(p.TargetDir, files, p.CopyConfig.Index); err != nil {
+			return model.FileOperations{}, err
+		}
+	}
+
+	fileOps := model.FileOperations{FileOperations: make([]model.FileOperation, 0, len(files))}
+	groups := groupFiles(files)
+	retained := make([]retention.Group, 0, len(groups))
+	//existing holds for every operation the destinations of the copies a skipped group already has
+	existing := make([][]string, 0, len(groups))
+	for _, group := range groups {
+		members := group.members()
+		fromPaths := make([]string, len(members))
+		hashes := make([]string, len(members))
+		reasons := make([]string, len(members))
+		copies := make([]string, len(members))
+		skipped := 0
+		for i, member := range members {
+			var err error
+			if fromPaths[i], err = filepath.Abs(member.Path); err != nil {
+				return fileOps, err
+			}
+			if imported, ok := p.imported(member); ok {
+				hashes[i], reasons[i], copies[i] = imported.Hash, "already imported to "+imported.Destination, imported.Destination
+			} else if contents != nil {
+				if hashes[i], err = utils.ContentHash(member); err != nil {
+					return fileOps, err
+				}
+				if existing, ok := contents.duplicateOf(hashes[i]); ok {
+					reasons[i], copies[i] = "duplicate of "+existing, existing
+				}
+			}
+			if reasons[i] != "" {
+				skipped++
+			}
+		}
+		fileToCopy := group.primary
+		fileOp := model.FileOperation{
+			From:          fromPaths[0],
+			OpType:        operationType(fileToCopy, p.CutoffDate),
+			Date:          fileToCopy.CreationDate,
+			DateSource:    fileToCopy.DateSource,
+			Location:      fileToCopy.Location,
+			DateConflicts: fileToCopy.DateConflicts,
+		}
+		//a skipped group is verified by the copies it has, the others by their copies made by the run
+		var copied []string
+		verified := skipped < len(members)
+		if !verified {
+			copied, verified = p.existingCopies(members, copies)
+		}
+		retained = append(retained, retention.Group{Files: members, Verified: verified})
+		existing = append(existing, copied)
+		if skipped == len(members) {
+			//the index and the duplicates only decide that no copy is needed, the source may still be deleted
+			deleteOp := fileOp.OpType == model.MoveOp
+			fileOp.OpType = model.SkipOp
+			fileOp.Hash = hashes[0]
+			fileOp.Reason = reasons[0]
+			for i, member := range members {
+				if i > 0 {
+					fileOp.Companions = append(fileOp.Companions, model.Companion{From: fromPaths[i], Hash: hashes[i]})
+				}
+				for _, sidecar := range member.Sidecars {
+					fileOp.Companions = append(fileOp.Companions, model.Companion{From: absolute(sidecar), Sidecar: true})
+				}
+			}
+			if deleteOp && copied != nil {
+				fileOp = deleteExisting(fileOp, copied)
+			}
+			fileOps.FileOperations = append(fileOps.FileOperations, fileOp)
+			continue
+		}
+		if contents != nil {
+			//a {hash} in the layout must not hash the file a second time
+			fileOp.Hash = hashes[0]
+			fileToCopy.Hash = hashes[0]
+		}
+		//the namer makes sure that no two files get the same destination
+		group.primary = fileToCopy
+		destinations, sidecarDestinations, err := namer.destinations(group)
+		if err != nil {
+			return fileOps, err
+		}
+		fileOp.To = destinations[0]
+		//every member is followed by its sidecars, they are not hashed as they follow their file
+		for i, member := range members {
+			if i > 0 {
+				companion := model.Companion{From: fromPaths[i], To: destinations[i]}
+				if contents != nil {
+					companion.Hash = hashes[i]
+				}
+				fileOp.Companions = append(fileOp.Companions, companion)
+			}
+			for j, sidecar := range member.Sidecars {
+				fileOp.Companions = append(fileOp.Companions, model.Companion{From: absolute(sidecar), To: sidecarDestinations[i][j], Sidecar: true})
+			}
+		}
+		if contents != nil {
+			for i, destination := range destinations {
+				contents.add(hashes[i], destination)
+			}
+		}
+		fileOps.FileOperations = append(fileOps.FileOperations, fileOp)
+	}
+	if p.Retention != nil {
+		for i, decision := range p.Retention.Decide(retained, p.Facts) {
+			fileOp := &fileOps.FileOperations[i]
+			fileOp.Retention = decision.String()
+			if fileOp.OpType == model.SkipOp && decision.Delete {
+				*fileOp = deleteExisting(*fileOp, existing[i])
+			} else if fileOp.OpType != model.SkipOp {
+				fileOp.OpType = model.CopyOp
+				if decision.Delete {
+					fileOp.OpType = model.MoveOp
+				}
+			}
+		}
+	}
+	return fileOps, nil
+}
+
+//existingCopies returns the destinations of all parts of a skipped group in the order of model.FileOperation.Parts,
+//copies are the paths relative to the target its members are known to have been copied to. It returns false unless
+//the copies of all members exist with the size of their source and the copies of all their sidecars exist next to
+//them. Their content is checked by the Executor before the sources are deleted.
+func (p Planner) existingCopies(members []model.FileInfo, copies []string) ([]string, bool) {
+	var destinations []string
+	for i, member := range members {
+		if copies[i] == "" {
+			return nil, false
+		}
+		destination := filepath.Join(p.TargetDir, filepath.FromSlash(copies[i]))
+		size, err := fileSize(member)
+		if info, statErr := os.Stat(destination); err != nil || statErr != nil || info.Size() != size {
+			return nil, false
+		}
+		destinations = append(destinations, destination)
+		extension := filepath.Ext(destination)
+		for _, sidecar := range member.Sidecars {
+			sidecarDestination := strings.TrimSuffix(destination, extension) + sidecarSuffix(member.Path, extension, sidecar)
+			if _, err := os.Stat(sidecarDestination); err != nil {
+				return nil, false
+			}
+			destinations = append(destinations, sidecarDestination)
+		}
+	}
+	return destinations, true
+}
+
+//deleteExisting turns a skipped operation into a model.DeleteOp removing its parts whose copies exist at the
+//destinations given in the order of model.FileOperation.Parts
+func deleteExisting(fileOp model.FileOperation, destinations []string) model.FileOperation {
+	fileOp.OpType = model.DeleteOp
+	fileOp.To = destinations[0]
+	companions := make([]model.Companion, len(fileOp.Companions))
+	for i, companion := range fileOp.Companions {
+		companion.To = destinations[i+1]
+		companions[i] = companion
+	}
+	fileOp.Companions = companions
+	return fileOp
+}
+
+//absolute returns the absolute path of a sidecar, the path is kept if it cannot be resolved
+func absolute(filePath string) string {
+	if absolutePath, err := filepath.Abs(filePath); err == nil {
+		return absolutePath
+	}
+	return filePath
+}
+
+//imported returns the index entry of a file which has been imported by an earlier run
+func (p Planner) imported(f model.FileInfo) (index.Entry, bool) {
+	if p.CopyConfig.Index == nil {
+		return index.Entry{}, false
+	}
+	return p.CopyConfig.Index.Imported(f)
+}
+
+//operationType returns the a valid model.ActionType according to the cutoffDate. All files created on and after the cutoffDate will be copied
+func operationType(fileInfo model.FileInfo, cutoffDate time.Time) model.OpType {
+
+	if fileInfo.CreationDate.Before(cutoffDate) {
+		return model.MoveOp
+	}
+	return model.CopyOp
+}
+
+// WriteFileOperations writes the operations as json file which can be reviewed, edited and applied later on
+func WriteFileOperations(descFile string, fileOps model.FileOperations) error {
+	desc, err := json.MarshalIndent(fileOps, "", "     ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(descFile, desc, 0644)
+}
+
+// ReadFileOperations reads a json file written by WriteFileOperations
+func ReadFileOperations(descFile string) (model.FileOperations, error) {
+	fileOps := model.FileOperations{FileOperations: make([]model.FileOperation, 0)}
+	input, err := ioutil.ReadFile(descFile)
+	if err != nil {
+		return fileOps, err
+	}
+	err = json.Unmarshal(input, &fileOps)
+	return fileOps, err
+}
@@ -0,0 +1,162 @@
+package file_test
+
+import (
+	"copy-images/file"
+	"copy-images/model"
+	"copy-images/runs"
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestResumeSkipsTheCompletedOperations(t *testing.T) {
+
+	//GIVEN
+	sourceDir := t.TempDir()
+	targetDir := t.TempDir()
+	first := model.FileInfo{Path: writeFile(t, path.Join(sourceDir, "IMG_1.jpg"), "first"), CreationDate: time.Now()}
+	second := model.FileInfo{Path: writeFile(t, path.Join(sourceDir, "IMG_2.jpg"), "second"), CreationDate: time.Now()}
+	fileOps, _ := file.Planner{TargetDir: targetDir}.Plan([]model.FileInfo{first, second})
+	planFile := path.Join(targetDir, "plan.json")
+	checkpoint, _ := runs.NewCheckpoint(planFile)
+	checkpoint.Complete(fileOps.FileOperations[0].From, fileOps.FileOperations[0].To)
+	checkpoint.Close()
+	resumed, _ := runs.OpenCheckpoint(planFile)
+	manifest := runs.NewManifest()
+
+	//WHEN
+	err := file.Executor{Progress: ioutil.Discard, Manifest: manifest, Checkpoint: resumed}.Apply(fileOps)
+	resumed.Close()
+	state, _ := runs.OpenCheckpoint(planFile)
+
+	//THEN
+	assert.Nil(t, err, "No error must be thrown")
+	assert.False(t, fileExists(fileOps.FileOperations[0].To), "A completed operation must not be executed again")
+	assert.True(t, fileExists(fileOps.FileOperations[1].To))
+	assert.Equal(t, runs.Skipped, manifest.Entries[0].Status)
+	assert.Equal(t, runs.Copied, manifest.Entries[1].Status)
+	assert.Equal(t, 2, state.Count(), "The operation completed by the resumed run must be checkpointed")
+
+}
+
+func TestResumingARunAppendsToItsManifestAndJournal(t *testing.T) {
+
+	//GIVEN
+	sourceDir := t.TempDir()
+	targetDir := t.TempDir()
+	first := model.FileInfo{Path: writeFile(t, path.Join(sourceDir, "IMG_1.jpg"), "first"), CreationDate: time.Now()}
+	second := model.FileInfo{Path: writeFile(t, path.Join(sourceDir, "IMG_2.jpg"), "second"), CreationDate: time.Now()}
+	fileOps, _ := file.Planner{TargetDir: targetDir}.Plan([]model.FileInfo{first, second})
+	manifest := runs.NewManifest()
+	planFile := runs.PlanPath(targetDir, manifest.RunID)
+	checkpoint, _ := runs.NewCheckpoint(planFile)
+	journal := runs.NewJournal(targetDir, manifest.RunID)
+	interrupted := model.FileOperations{FileOperations: fileOps.FileOperations[:1]}
+	file.Executor{Progress: ioutil.Discard, Manifest: manifest, Journal: journal, Checkpoint: checkpoint}.Apply(interrupted)
+	checkpoint.Close()
+	journal.Close()
+	manifest.Write(targetDir)
+	continued, _ := runs.OpenManifest(targetDir, runs.RunOf(targetDir, planFile))
+	resumed, _ := runs.OpenCheckpoint(planFile)
+	resumedJournal := runs.NewJournal(targetDir, continued.RunID)
+
+	//WHEN
+	err := file.Executor{Progress: ioutil.Discard, Manifest: continued, Journal: resumedJournal, Checkpoint: resumed}.Apply(fileOps)
+	resumed.Close()
+	resumedJournal.Close()
+	records, _ := runs.ReadJournal(targetDir, manifest.RunID)
+
+	//THEN
+	assert.Nil(t, err, "No error must be thrown")
+	assert.Equal(t, manifest.RunID, continued.RunID)
+	assert.Equal(t, 2, len(continued.Entries), "The operation completed before the run was resumed must not be recorded twice")
+	assert.Equal(t, fileOps.FileOperations[1].To, continued.Entries[1].To)
+	report := file.Undo(targetDir, records)
+	assert.ElementsMatch(t, []string{fileOps.FileOperations[0].To, fileOps.FileOperations[1].To}, report.Removed, "Undo must remove the copies of both parts of the run")
+
+}
+
+func TestResumeRedoesAPartiallyWrittenCopy(t *testing.T) {
+
+	//GIVEN
+	sourceDir := t.TempDir()
+	targetDir := t.TempDir()
+	photo := model.FileInfo{Path: writeFile(t, path.Join(sourceDir, "IMG_1.jpg"), "complete photo"), CreationDate: time.Now()}
+	fileOps, _ := file.Planner{TargetDir: targetDir}.Plan([]model.FileInfo{photo})
+	copyPath := fileOps.FileOperations[0].To
+	os.MkdirAll(path.Dir(copyPath), os.ModePerm)
+	writeFile(t, copyPath, "complete")
+	checkpoint, _ := runs.OpenCheckpoint(path.Join(targetDir, "plan.json"))
+
+	//WHEN
+	err := file.Executor{Progress: ioutil.Discard, Checkpoint: checkpoint}.Apply(fileOps)
+	checkpoint.Close()
+
+	//THEN
+	assert.Nil(t, err, "No error must be thrown")
+	content, _ := ioutil.ReadFile(copyPath)
+	assert.Equal(t, "complete photo", string(content))
+
+}
+
+func TestResumeKeepsACompleteCopyAndFinishesTheMove(t *testing.T) {
+
+	//GIVEN
+	sourceDir := t.TempDir()
+	targetDir := t.TempDir()
+	march, _ := time.Parse("2006-01-02", "2021-03-03")
+	april, _ := time.Parse("2006-01-02", "2021-04-04")
+	photo := model.FileInfo{Path: writeFile(t, path.Join(sourceDir, "IMG_1.jpg"), "photo"), CreationDate: march}
+	fileOps, _ := file.Planner{TargetDir: targetDir, CutoffDate: april}.Plan([]model.FileInfo{photo})
+	copyPath := fileOps.FileOperations[0].To
+	os.MkdirAll(path.Dir(copyPath), os.ModePerm)
+	writeFile(t, copyPath, "photo")
+	past := time.Now().Add(-time.Hour)
+	os.Chtimes(copyPath, past, past)
+	checkpoint, _ := runs.OpenCheckpoint(path.Join(targetDir, "plan.json"))
+	manifest := runs.NewManifest()
+
+	//WHEN
+	err := file.Executor{Progress: ioutil.Discard, Manifest: manifest, Checkpoint: checkpoint}.Apply(fileOps)
+	checkpoint.Close()
+
+	//THEN
+	assert.Nil(t, err, "No error must be thrown")
+	info, _ := os.Stat(copyPath)
+	assert.Equal(t, past.Unix(), info.ModTime().Unix(), "The complete copy must be kept")
+	assert.False(t, fileExists(photo.Path), "The source of the move must be deleted")
+	assert.Equal(t, runs.Verified, manifest.Entries[0].Status)
+	assert.True(t, manifest.Entries[0].SourceDeleted)
+
+}
+
+func TestResumeAcceptsAMoveWhoseSourceIsGone(t *testing.T) {
+
+	//GIVEN
+	sourceDir := t.TempDir()
+	targetDir := t.TempDir()
+	march, _ := time.Parse("2006-01-02", "2021-03-03")
+	april, _ := time.Parse("2006-01-02", "2021-04-04")
+	photo := model.FileInfo{Path: writeFile(t, path.Join(sourceDir, "IMG_1.jpg"), "photo"), CreationDate: march}
+	fileOps, _ := file.Planner{TargetDir: targetDir, CutoffDate: april}.Plan([]model.FileInfo{photo})
+	copyPath := fileOps.FileOperations[0].To
+	os.MkdirAll(path.Dir(copyPath), os.ModePerm)
+	os.Rename(photo.Path, copyPath)
+	checkpoint, _ := runs.OpenCheckpoint(path.Join(targetDir, "plan.json"))
+	manifest := runs.NewManifest()
+
+	//WHEN
+	err := file.Executor{Progress: ioutil.Discard, Manifest: manifest, Checkpoint: checkpoint}.Apply(fileOps)
+	checkpoint.Close()
+
+	//THEN
+	assert.Nil(t, err, "No error must be thrown")
+	assert.True(t, fileExists(copyPath))
+	assert.Equal(t, runs.Verified, manifest.Entries[0].Status)
+	assert.True(t, manifest.Entries[0].SourceDeleted)
+
+}
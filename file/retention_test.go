@@ -0,0 +1,99 @@
+package file_test
+
+import (
+	"copy-images/file"
+	"copy-images/index"
+	"copy-images/model"
+	"copy-images/retention"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPlannerMovesTheFilesTheRetentionDeletesAndDeletesDuplicatesInTheTarget(t *testing.T) {
+
+	//GIVEN
+	sourceDir := t.TempDir()
+	targetDir := t.TempDir()
+	now, _ := time.Parse("2006-01-02", "2021-06-06")
+	january, _ := time.Parse("2006-01-02", "2021-01-01")
+	writeFile(t, path.Join(targetDir, "2021", "January", "IMG_1.jpg"), "holiday")
+	filesToCopy := []model.FileInfo{
+		{Path: writeFile(t, path.Join(sourceDir, "IMG_1.jpg"), "holiday"), CreationDate: january},
+		{Path: writeFile(t, path.Join(sourceDir, "IMG_2.jpg"), "beach"), CreationDate: january},
+		{Path: writeFile(t, path.Join(sourceDir, "Family", "IMG_3.jpg"), "birthday"), CreationDate: january},
+		{Path: writeFile(t, path.Join(sourceDir, "IMG_4.jpg"), "garden"), CreationDate: now},
+	}
+	policy := retention.Policy{KeepMonths: 2, KeepAlbums: []string{"family"}}
+	planner := file.Planner{TargetDir: targetDir, Retention: &policy, Facts: retention.Facts{Now: now}, CopyConfig: file.CopyConfig{SkipDuplicates: true}}
+
+	//WHEN
+	fileOps, err := planner.Plan(filesToCopy)
+
+	//THEN
+	assert.Nil(t, err, "No error must be thrown")
+	ops := fileOps.FileOperations
+	assert.Equal(t, []model.OpType{model.DeleteOp, model.MoveOp, model.CopyOp, model.CopyOp}, []model.OpType{ops[0].OpType, ops[1].OpType, ops[2].OpType, ops[3].OpType})
+	assert.Equal(t, "delete (age: taken 2021-01-01, older than 2 months)", ops[0].Retention, "A duplicate already in the target is deleted without copying it")
+	assert.Equal(t, path.Join(targetDir, "2021", "January", "IMG_1.jpg"), ops[0].To)
+	assert.Equal(t, "delete (age: taken 2021-01-01, older than 2 months)", ops[1].Retention)
+	assert.Equal(t, "keep (album: in album Family)", ops[2].Retention)
+
+}
+
+func TestMoveAfterCopyDeletesTheImportedFilesTheRetentionDeletes(t *testing.T) {
+
+	//GIVEN
+	sourceDir := t.TempDir()
+	targetDir := t.TempDir()
+	january, _ := time.Parse("2006-01-02", "2019-01-01")
+	filesToCopy := []model.FileInfo{
+		{Path: writeFile(t, path.Join(sourceDir, "IMG_1.jpg"), "holiday"), CreationDate: january},
+		{Path: writeFile(t, path.Join(sourceDir, "IMG_2.jpg"), "beach"), CreationDate: january},
+	}
+	idx, _ := index.Load(targetDir)
+	assert.Nil(t, file.CopyFilesTo(targetDir, filesToCopy, file.CopyConfig{Index: idx, SkipDuplicates: true}))
+	os.Remove(path.Join(targetDir, "2019", "January", "IMG_2.jpg"))
+	reloaded, _ := index.Load(targetDir)
+	policy := retention.Policy{}
+	planner := file.Planner{TargetDir: targetDir, Retention: &policy, CopyConfig: file.CopyConfig{Index: reloaded, SkipDuplicates: true}}
+
+	//WHEN
+	fileOps, err := planner.Plan(filesToCopy)
+	applyErr := file.Executor{Progress: ioutil.Discard}.Apply(fileOps)
+
+	//THEN
+	assert.Nil(t, err, "No error must be thrown")
+	assert.Nil(t, applyErr, "No error must be thrown")
+	ops := fileOps.FileOperations
+	assert.Equal(t, model.DeleteOp, ops[0].OpType, "The imported file must be deleted without copying it again")
+	assert.Equal(t, "already imported to 2019/January/IMG_1.jpg", ops[0].Reason)
+	assert.Equal(t, "delete (age: taken 2019-01-01, older than 0 months)", ops[0].Retention)
+	assert.Equal(t, model.MoveOp, ops[1].OpType, "A file whose copy is gone must be copied again")
+	assert.False(t, fileExists(filesToCopy[0].Path))
+	assert.False(t, fileExists(filesToCopy[1].Path))
+	assert.True(t, fileExists(path.Join(targetDir, "2019", "January", "IMG_1.jpg")))
+	assert.True(t, fileExists(path.Join(targetDir, "2019", "January", "IMG_2.jpg")))
+
+}
+
+func TestPlannerRejectsACutoffDateWithARetentionPolicy(t *testing.T) {
+
+	//GIVEN
+	sourceDir := t.TempDir()
+	january, _ := time.Parse("2006-01-02", "2021-01-01")
+	filesToCopy := []model.FileInfo{{Path: writeFile(t, path.Join(sourceDir, "IMG_1.jpg"), "holiday"), CreationDate: january}}
+	planner := file.Planner{TargetDir: t.TempDir(), CutoffDate: time.Now(), Retention: &retention.Policy{KeepMonths: 2}}
+
+	//WHEN
+	_, err := planner.Plan(filesToCopy)
+
+	//THEN
+	assert.True(t, errors.Is(err, file.ErrTwoPolicies), "It must be unambiguous which policy moves the files")
+
+}
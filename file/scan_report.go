@@ -0,0 +1,85 @@
+package file
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+//ErrSymlinkedDir is the error of a symlink to a dir below the source, only a symlinked source itself is followed
+var ErrSymlinkedDir = errors.New("symlinked dir is not followed")
+
+//ScanProblem is the error of a single path met while walking the source, the path is skipped
+type ScanProblem struct {
+	Path string
+	// Op is the operation which failed, e.g. open or stat
+	Op  string
+	Err error
+}
+
+func (p ScanProblem) Error() string {
+	return p.Path + ": " + p.Op + ": " + p.Err.Error()
+}
+
+func (p ScanProblem) Unwrap() error {
+	return p.Err
+}
+
+//PermissionDenied checks if the path was skipped because it must not be read
+func (p ScanProblem) PermissionDenied() bool {
+	return errors.Is(p.Err, os.ErrPermission)
+}
+
+//ScanReport is returned by a walk which skipped paths because of errors, all other files have been collected
+type ScanReport struct {
+	Root     string
+	Problems []ScanProblem
+}
+
+//newScanProblem converts the error of an operation on a path into a ScanProblem
+func newScanProblem(path string, op string, err error) ScanProblem {
+	var pathErr *os.PathError
+	if errors.As(err, &pathErr) {
+		return ScanProblem{Path: pathErr.Path, Op: pathErr.Op, Err: pathErr.Err}
+	}
+	return ScanProblem{Path: path, Op: op, Err: err}
+}
+
+//PermissionDenied returns the problems of paths which must not be read
+func (r *ScanReport) PermissionDenied() []ScanProblem {
+	var denied []ScanProblem
+	for _, problem := range r.Problems {
+		if problem.PermissionDenied() {
+			denied = append(denied, problem)
+		}
+	}
+	return denied
+}
+
+//Others returns all problems besides denied permissions
+func (r *ScanReport) Others() []ScanProblem {
+	var others []ScanProblem
+	for _, problem := range r.Problems {
+		if !problem.PermissionDenied() {
+			others = append(others, problem)
+		}
+	}
+	return others
+}
+
+//Error summarizes the skipped paths, the ones without permission are listed together
+func (r *ScanReport) Error() string {
+	var report strings.Builder
+	fmt.Fprintf(&report, "scanning %s skipped %d paths", r.Root, len(r.Problems))
+	if denied := r.PermissionDenied(); len(denied) > 0 {
+		fmt.Fprintf(&report, "\n  permission denied for %d paths:", len(denied))
+		for _, problem := range denied {
+			fmt.Fprintf(&report, "\n    %s", problem.Path)
+		}
+	}
+	for _, problem := range r.Others() {
+		fmt.Fprintf(&report, "\n  %s", problem.Error())
+	}
+	return report.String()
+}
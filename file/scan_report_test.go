@@ -0,0 +1,89 @@
+package file_test
+
+import (
+	"copy-images/file"
+	"copy-images/model"
+	"errors"
+	"os"
+	"path"
+	"syscall"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestScanSkipsUnreadableDirsAndReportsThem(t *testing.T) {
+
+	//GIVEN
+	rootDir := t.TempDir()
+	writeFile(t, path.Join(rootDir, "DCIM", "IMG_1.jpg"), "holiday")
+	writeFile(t, path.Join(rootDir, "Android", "data", "app", "IMG_2.jpg"), "cache")
+	writeFile(t, path.Join(rootDir, "Broken", "IMG_3.jpg"), "beach")
+	denied := path.Join(rootDir, "Android", "data")
+	broken := path.Join(rootDir, "Broken")
+	defer file.SetReadDir(func(dir string) ([]os.DirEntry, error) {
+		switch dir {
+		case denied:
+			return nil, &os.PathError{Op: "open", Path: dir, Err: syscall.EACCES}
+		case broken:
+			return nil, &os.PathError{Op: "readdirent", Path: dir, Err: syscall.EIO}
+		}
+		return os.ReadDir(dir)
+	})()
+	var files []model.FileInfo
+
+	//WHEN
+	err := file.CollectFiles(rootDir, &files, file.CollectFilesConfig{SupportedExtensions: []string{".jpg"}})
+
+	//THEN
+	var report *file.ScanReport
+	assert.True(t, errors.As(err, &report), "The skipped paths must be reported")
+	assert.Equal(t, 1, len(files), "All readable files must be collected")
+	assert.Equal(t, path.Join(rootDir, "DCIM", "IMG_1.jpg"), files[0].Path)
+	assert.Equal(t, 2, len(report.Problems))
+	assert.Equal(t, []file.ScanProblem{{Path: denied, Op: "open", Err: syscall.EACCES}}, report.PermissionDenied())
+	assert.Equal(t, []file.ScanProblem{{Path: broken, Op: "readdirent", Err: syscall.EIO}}, report.Others())
+	assert.Contains(t, err.Error(), "permission denied for 1 paths:\n    "+denied)
+	assert.Contains(t, err.Error(), broken+": readdirent: input/output error")
+
+}
+
+func TestScanOfMissingRootIsNoReport(t *testing.T) {
+
+	//GIVEN
+	rootDir := path.Join(t.TempDir(), "missing")
+	var files []model.FileInfo
+
+	//WHEN
+	err := file.CollectFiles(rootDir, &files, file.CollectFilesConfig{SupportedExtensions: []string{".jpg"}})
+
+	//THEN
+	var report *file.ScanReport
+	assert.False(t, errors.As(err, &report), "A root which cannot be read must fail the scan")
+	assert.True(t, os.IsNotExist(err))
+
+}
+
+func TestScanReportsSymlinkedDirsWhichAreNotFollowed(t *testing.T) {
+
+	//GIVEN
+	rootDir := t.TempDir()
+	otherDir := t.TempDir()
+	writeFile(t, path.Join(rootDir, "DCIM", "IMG_1.jpg"), "holiday")
+	writeFile(t, path.Join(otherDir, "IMG_2.jpg"), "beach")
+	linked := path.Join(rootDir, "Pictures")
+	os.Symlink(otherDir, linked)
+	os.Symlink(otherDir, path.Join(rootDir, "DCIM", ".thumbnails"))
+	var files []model.FileInfo
+
+	//WHEN
+	err := file.CollectFiles(rootDir, &files, file.CollectFilesConfig{SupportedExtensions: []string{".jpg"}, ExcludedDirs: []string{".thumbnails"}})
+
+	//THEN
+	var report *file.ScanReport
+	assert.True(t, errors.As(err, &report), "The skipped subtree must be reported")
+	assert.Equal(t, 1, len(files), "The symlinked dir must not be followed")
+	assert.Equal(t, []file.ScanProblem{{Path: linked, Op: "symlink", Err: file.ErrSymlinkedDir}}, report.Problems, "An excluded symlink must not be reported")
+	assert.Contains(t, err.Error(), linked+": symlink: symlinked dir is not followed")
+
+}
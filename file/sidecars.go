@@ -0,0 +1,66 @@
+package file
+
+import (
+	"copy-images/utils"
+	"path/filepath"
+	"strings"
+)
+
+//SidecarExtensions are the file extensions of the metadata files which are copied, moved and deleted together with
+//the photo or video they describe, XMP files of Lightroom and darktable, AAE edits of iOS and Google Takeout json files
+var SidecarExtensions = []string{".xmp", ".aae", ".json"}
+
+//isSidecar checks if the file has one of the SidecarExtensions
+func isSidecar(name string) bool {
+	return utils.ItemExists(SidecarExtensions, strings.ToLower(filepath.Ext(name)))
+}
+
+//matchSidecars assigns the sidecars of a dir to the files they describe and returns them by the lower case name of
+//their file. A sidecar belongs to the file whose full name it extends, like IMG_1.CR2.xmp or IMG_1.jpg.json, or
+//otherwise to a file sharing its basename, like IMG_1.xmp or IMG_1.AAE. If several files share the basename a RAW file
+//is preferred, otherwise the first one is taken. Names are compared ignoring case.
+func matchSidecars(files []string, sidecars []string) map[string][]string {
+	byName := make(map[string]string)
+	byBasename := make(map[string]string)
+	for _, name := range files {
+		byName[strings.ToLower(name)] = name
+		basename := strings.ToLower(strings.TrimSuffix(name, filepath.Ext(name)))
+		if owner, ok := byBasename[basename]; !ok || (isRaw(name) && !isRaw(owner)) {
+			byBasename[basename] = name
+		}
+	}
+	matched := make(map[string][]string)
+	taken := make(map[string]bool)
+	for _, sidecar := range sidecars {
+		// a second sidecar differing only in case would get the same destination
+		if taken[strings.ToLower(sidecar)] {
+			continue
+		}
+		stem := strings.ToLower(strings.TrimSuffix(sidecar, filepath.Ext(sidecar)))
+		owner, ok := byName[stem]
+		if !ok {
+			owner, ok = byBasename[stem]
+		}
+		if ok {
+			matched[strings.ToLower(owner)] = append(matched[strings.ToLower(owner)], sidecar)
+			taken[strings.ToLower(sidecar)] = true
+		}
+	}
+	return matched
+}
+
+//sidecarSuffix returns what follows the basename in the destination of a sidecar, the destination of its file ends
+//with the fileSuffix. IMG_1.jpg.json keeps the extension of its file, IMG_1.xmp only its own. Other names, like the
+//IMG_1.jpg(1).json of IMG_1(1).jpg or the cut names of Google Takeout, get the extension of the file and their own.
+func sidecarSuffix(filePath string, fileSuffix string, sidecarPath string) string {
+	fileName := strings.ToLower(filepath.Base(filePath))
+	sidecarName := filepath.Base(sidecarPath)
+	if strings.HasPrefix(strings.ToLower(sidecarName), fileName) {
+		return fileSuffix + sidecarName[len(fileName):]
+	}
+	basename := strings.TrimSuffix(fileName, filepath.Ext(fileName))
+	if strings.EqualFold(sidecarName, basename+filepath.Ext(sidecarName)) {
+		return sidecarName[len(basename):]
+	}
+	return fileSuffix + filepath.Ext(sidecarName)
+}
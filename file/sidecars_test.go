@@ -0,0 +1,91 @@
+package file_test
+
+import (
+	"copy-images/file"
+	"copy-images/model"
+	"io/ioutil"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCollectFilesAttachesSidecarsToTheirFiles(t *testing.T) {
+
+	//GIVEN
+	rootDir := t.TempDir()
+	for _, name := range []string{"IMG_1.jpg", "IMG_1.jpg.json", "IMG_1.XMP", "IMG_2.CR2", "IMG_2.JPG", "IMG_2.xmp", "IMG_2.JPG.xmp", "IMG_3.AAE", "orphan.xmp", "notes.json"} {
+		writeFile(t, path.Join(rootDir, name), name)
+	}
+	config := file.CollectFilesConfig{SupportedExtensions: []string{".jpg", ".cr2"}}
+	var files []model.FileInfo
+
+	//WHEN
+	err := file.CollectFiles(rootDir, &files, config)
+
+	//THEN
+	assert.Nil(t, err, "No error must be thrown")
+	assert.Equal(t, 3, len(files), "Sidecars must not be collected as files")
+	assert.Equal(t, []string{path.Join(rootDir, "IMG_1.XMP"), path.Join(rootDir, "IMG_1.jpg.json")}, files[0].Sidecars)
+	assert.Equal(t, path.Join(rootDir, "IMG_2.CR2"), files[1].Path)
+	assert.Equal(t, []string{path.Join(rootDir, "IMG_2.xmp")}, files[1].Sidecars, "A shared basename must belong to the RAW file")
+	assert.Equal(t, []string{path.Join(rootDir, "IMG_2.JPG.xmp")}, files[2].Sidecars)
+
+}
+
+func TestSidecarsAreRenamedWithTheirFile(t *testing.T) {
+
+	//GIVEN
+	sourceDir := t.TempDir()
+	targetDir := t.TempDir()
+	march, _ := time.Parse("2006-01-02", "2021-03-03")
+	writeFile(t, path.Join(targetDir, "2021", "March", "IMG_1.jpg"), "earlier import")
+	writeFile(t, path.Join(targetDir, "2021", "March", "IMG_2.xmp"), "orphaned sidecar")
+	filesToCopy := []model.FileInfo{
+		{Path: path.Join(sourceDir, "IMG_1.jpg"), CreationDate: march, Sidecars: []string{path.Join(sourceDir, "IMG_1.xmp"), path.Join(sourceDir, "IMG_1.jpg.json")}},
+		{Path: path.Join(sourceDir, "IMG_2.jpg"), CreationDate: march, Sidecars: []string{path.Join(sourceDir, "IMG_2.xmp")}},
+	}
+	planner := file.Planner{TargetDir: targetDir}
+
+	//WHEN
+	fileOps, err := planner.Plan(filesToCopy)
+
+	//THEN
+	assert.Nil(t, err, "No error must be thrown")
+	march2021 := path.Join(targetDir, "2021", "March")
+	assert.Equal(t, path.Join(march2021, "IMG_1_1.jpg"), fileOps.FileOperations[0].To)
+	assert.Equal(t, []model.Companion{
+		{From: path.Join(sourceDir, "IMG_1.xmp"), To: path.Join(march2021, "IMG_1_1.xmp"), Sidecar: true},
+		{From: path.Join(sourceDir, "IMG_1.jpg.json"), To: path.Join(march2021, "IMG_1_1.jpg.json"), Sidecar: true},
+	}, fileOps.FileOperations[0].Companions)
+	assert.Equal(t, path.Join(march2021, "IMG_2_1.jpg"), fileOps.FileOperations[1].To, "A taken sidecar name must rename the file as well")
+	assert.Equal(t, path.Join(march2021, "IMG_2_1.xmp"), fileOps.FileOperations[1].Companions[0].To)
+
+}
+
+func TestSidecarsAreMovedAndDeletedWithTheirFile(t *testing.T) {
+
+	//GIVEN
+	sourceDir := t.TempDir()
+	targetDir := t.TempDir()
+	march, _ := time.Parse("2006-01-02", "2021-03-03")
+	april, _ := time.Parse("2006-01-02", "2021-04-04")
+	moved := model.FileInfo{Path: writeFile(t, path.Join(sourceDir, "IMG_1.jpg"), "photo"), CreationDate: march, Sidecars: []string{writeFile(t, path.Join(sourceDir, "IMG_1.AAE"), "edit")}}
+	deleted := model.FileInfo{Path: writeFile(t, path.Join(sourceDir, "IMG_2.jpg"), "photo"), CreationDate: march, Sidecars: []string{writeFile(t, path.Join(sourceDir, "IMG_2.xmp"), "rating")}}
+	kept := model.FileInfo{Path: writeFile(t, path.Join(sourceDir, "IMG_3.jpg"), "photo"), CreationDate: april, Sidecars: []string{writeFile(t, path.Join(sourceDir, "IMG_3.xmp"), "rating")}}
+	fileOps, _ := file.Planner{TargetDir: targetDir, CutoffDate: april}.Plan([]model.FileInfo{moved})
+
+	//WHEN
+	err := file.Executor{Progress: ioutil.Discard}.Apply(fileOps)
+	removed := file.DeleteFilesCreatedBefore(april, []model.FileInfo{deleted, kept}, nil)
+
+	//THEN
+	assert.Nil(t, err, "No error must be thrown")
+	assert.False(t, fileExists(moved.Sidecars[0]), "The sidecar must be moved with its file")
+	assert.True(t, fileExists(path.Join(targetDir, "2021", "March", "IMG_1.AAE")))
+	assert.Equal(t, 1, len(removed))
+	assert.False(t, fileExists(deleted.Sidecars[0]), "The sidecar must be deleted with its file")
+	assert.True(t, fileExists(kept.Sidecars[0]))
+
+}
@@ -0,0 +1,86 @@
+package file_test
+
+import (
+	"copy-images/file"
+	"copy-images/model"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const heicHeader = "\x00\x00\x00\x18ftypheic\x00\x00\x00\x00mif1heic"
+
+func TestSniffingCollectsMisnamedAndExtensionlessFiles(t *testing.T) {
+
+	//GIVEN
+	rootDir := t.TempDir()
+	heic := writeFile(t, path.Join(rootDir, "IMG_1.jpg"), heicHeader)
+	extensionless := writeFile(t, path.Join(rootDir, "cache", "a1b2c3"), "\xFF\xD8\xFF\xE0 jpeg")
+	video := writeFile(t, path.Join(rootDir, "cache", "d4e5f6"), "\x00\x00\x00\x14ftypmp42\x00\x00\x00\x00isom")
+	unknown := writeFile(t, path.Join(rootDir, "IMG_2.jpg"), "")
+	writeFile(t, path.Join(rootDir, "notes"), "just some notes")
+	config := file.CollectFilesConfig{SupportedExtensions: append([]string{".jpg"}, file.VideoExtensions...), Sniff: true}
+	var files []model.FileInfo
+
+	//WHEN
+	err := file.CollectFiles(rootDir, &files, config)
+
+	//THEN
+	assert.Nil(t, err, "No error must be thrown")
+	assert.Equal(t, 4, len(files), "Files must be collected by their content or their extension")
+	byPath := make(map[string]model.FileInfo)
+	for _, f := range files {
+		byPath[f.Path] = f
+	}
+	assert.Equal(t, "image/heic", byPath[heic].MimeType)
+	assert.Equal(t, "image/jpeg", byPath[extensionless].MimeType)
+	assert.Equal(t, "video/mp4", byPath[video].MimeType)
+	assert.Equal(t, model.VideoMedia, byPath[video].MediaType)
+	assert.Equal(t, "", byPath[unknown].MimeType, "A supported extension must be collected whatever its content")
+
+}
+
+func TestWithoutSniffingOnlyTheExtensionCounts(t *testing.T) {
+
+	//GIVEN
+	rootDir := t.TempDir()
+	writeFile(t, path.Join(rootDir, "IMG_1.jpg"), heicHeader)
+	writeFile(t, path.Join(rootDir, "a1b2c3"), "\xFF\xD8\xFF\xE0 jpeg")
+	var files []model.FileInfo
+
+	//WHEN
+	err := file.CollectFiles(rootDir, &files, file.CollectFilesConfig{SupportedExtensions: []string{".jpg"}})
+
+	//THEN
+	assert.Nil(t, err, "No error must be thrown")
+	assert.Equal(t, 1, len(files))
+	assert.Equal(t, "", files[0].MimeType)
+
+}
+
+func TestFixExtensionsRenamesDestinationsToTheDetectedType(t *testing.T) {
+
+	//GIVEN
+	targetDir := t.TempDir()
+	march, _ := time.Parse("2006-01-02", "2021-03-03")
+	filesToCopy := []model.FileInfo{
+		{Path: "/phone/IMG_1.jpg", CreationDate: march, MimeType: "image/heic"},
+		{Path: "/phone/cache/a1b2c3", CreationDate: march, MimeType: "image/jpeg"},
+		{Path: "/phone/IMG_2.JPEG", CreationDate: march, MimeType: "image/jpeg"},
+		{Path: "/phone/IMG_3.jpg", CreationDate: march},
+	}
+	planner := file.Planner{TargetDir: targetDir, CopyConfig: file.CopyConfig{FixExtensions: true}}
+
+	//WHEN
+	fileOps, err := planner.Plan(filesToCopy)
+
+	//THEN
+	assert.Nil(t, err, "No error must be thrown")
+	assert.Equal(t, path.Join(targetDir, "2021", "March", "IMG_1.heic"), fileOps.FileOperations[0].To)
+	assert.Equal(t, path.Join(targetDir, "2021", "March", "a1b2c3.jpg"), fileOps.FileOperations[1].To)
+	assert.Equal(t, path.Join(targetDir, "2021", "March", "IMG_2.JPEG"), fileOps.FileOperations[2].To, "A matching extension must be kept")
+	assert.Equal(t, path.Join(targetDir, "2021", "March", "IMG_3.jpg"), fileOps.FileOperations[3].To, "Files without detected type must be kept")
+
+}
@@ -0,0 +1,208 @@
+package file
+
+import (
+	"copy-images/model"
+	"copy-images/utils"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+//DefaultSpaceReserve is the number of bytes kept free on every file system of the target if no other reserve is given
+const DefaultSpaceReserve = 1000 * 1000 * 1000
+
+//freeSpace reads the free space of a file system, it is replaced by tests to simulate full disks
+var freeSpace = utils.FreeSpace
+
+//SpaceError reports that the operations do not fit onto the file systems of the target
+type SpaceError struct {
+	Estimate model.SpaceEstimate
+}
+
+func (e *SpaceError) Error() string {
+	var short []string
+	for _, fileSystem := range e.Estimate.FileSystems {
+		if !fileSystem.Sufficient() {
+			short = append(short, describeSpace(fileSystem))
+		}
+	}
+	return "not enough space in the target: " + strings.Join(short, ", ")
+}
+
+//describeSpace describes the space needed on a file system of the target
+func describeSpace(fileSystem model.FileSystemSpace) string {
+	if fileSystem.Free < 0 {
+		return fmt.Sprintf("%s needed on %s, free space unknown", utils.FormatSize(fileSystem.Needed), fileSystem.Dir)
+	}
+	return fmt.Sprintf("%s needed on %s, %s free, %s reserved", utils.FormatSize(fileSystem.Needed), fileSystem.Dir,
+		utils.FormatSize(fileSystem.Free), utils.FormatSize(fileSystem.Reserve))
+}
+
+//DescribeSpace returns a line for every file system of the target telling how much space the operations need there
+func DescribeSpace(estimate model.SpaceEstimate) []string {
+	lines := make([]string, 0, len(estimate.FileSystems))
+	for _, fileSystem := range estimate.FileSystems {
+		lines = append(lines, describeSpace(fileSystem))
+	}
+	return lines
+}
+
+//spaceMeasure is what the operations write to the file systems of the target
+type spaceMeasure struct {
+	fileSystems []model.FileSystemSpace
+	//needs holds for every operation the bytes it writes by the position of the file system in fileSystems
+	needs []map[int]int64
+}
+
+//measureSpace sums the sizes of the sources of all copies and moves by the file system of their destination and reads
+//the free space of every file system. Sources which cannot be read are left out, executing them fails anyway, and so
+//are destinations already holding a file of the size of their source.
+func measureSpace(fileOps model.FileOperations, reserve int64) (spaceMeasure, error) {
+	measure := spaceMeasure{needs: make([]map[int]int64, len(fileOps.FileOperations))}
+	positions := make(map[uint64]int)
+	dirs := make(map[string]int)
+	for i, fileOp := range fileOps.FileOperations {
+		measure.needs[i] = make(map[int]int64)
+		if fileOp.OpType == model.SkipOp || fileOp.OpType == model.DeleteOp {
+			continue
+		}
+		for _, part := range fileOp.Parts() {
+			info, err := os.Stat(part.From)
+			if err != nil || part.To == "" {
+				continue
+			}
+			//the copy of an interrupted run is kept if it is complete, see Executor
+			if existing, err := os.Stat(part.To); err == nil && existing.Size() == info.Size() {
+				continue
+			}
+			dir := filepath.Dir(part.To)
+			position, ok := dirs[dir]
+			if !ok {
+				existing, err := existingDir(dir)
+				if err != nil {
+					return measure, err
+				}
+				id, err := utils.FileSystemID(existing)
+				if err != nil {
+					return measure, err
+				}
+				if position, ok = positions[id]; !ok {
+					free, err := freeSpace(existing)
+					if err != nil {
+						free = -1
+					}
+					position = len(measure.fileSystems)
+					positions[id] = position
+					measure.fileSystems = append(measure.fileSystems, model.FileSystemSpace{Dir: existing, Free: free, Reserve: reserve})
+				}
+				dirs[dir] = position
+			}
+			measure.needs[i][position] += info.Size()
+		}
+	}
+	return measure, nil
+}
+
+//estimate returns the estimate of the operations which are not trimmed
+func (m spaceMeasure) estimate(trimmed map[int]bool) model.SpaceEstimate {
+	estimate := model.SpaceEstimate{FileSystems: append([]model.FileSystemSpace(nil), m.fileSystems...)}
+	for i, needs := range m.needs {
+		for position, size := range needs {
+			if !trimmed[i] {
+				estimate.FileSystems[position].Needed += size
+				estimate.Needed += size
+			}
+		}
+	}
+	return estimate
+}
+
+//existingDir returns the dir or the nearest of its parents which exists, the destination dirs are created while copying
+func existingDir(dir string) (string, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return "", err
+	}
+	for {
+		if info, err := os.Stat(dir); err == nil && info.IsDir() {
+			return dir, nil
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", fmt.Errorf("%s: no existing dir", dir)
+		}
+		dir = parent
+	}
+}
+
+//EstimateSpace compares the bytes the copies and moves write with the free space of every file system of the target,
+//of which reserve bytes have to stay free
+func EstimateSpace(fileOps model.FileOperations, reserve int64) (model.SpaceEstimate, error) {
+	measure, err := measureSpace(fileOps, reserve)
+	if err != nil {
+		return model.SpaceEstimate{}, err
+	}
+	return measure.estimate(nil), nil
+}
+
+//TrimToSpace skips the operations which do not fit onto the file systems of the target. The operations are taken in
+//the order of their dates, once an operation does not fit all newer ones on the same file system are skipped as well,
+//so the oldest files are copied first. It returns the operations with their estimate and the number of skipped ones.
+func TrimToSpace(fileOps model.FileOperations, reserve int64) (model.FileOperations, int, error) {
+	measure, err := measureSpace(fileOps, reserve)
+	if err != nil {
+		return fileOps, 0, err
+	}
+	order := make([]int, len(fileOps.FileOperations))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool {
+		return fileOps.FileOperations[order[a]].Date.Before(fileOps.FileOperations[order[b]].Date)
+	})
+	used := make([]int64, len(measure.fileSystems))
+	full := make([]bool, len(measure.fileSystems))
+	trimmed := make(map[int]bool)
+	for _, i := range order {
+		for position, size := range measure.needs[i] {
+			fileSystem := measure.fileSystems[position]
+			if full[position] || (fileSystem.Free >= 0 && used[position]+size > fileSystem.Free-fileSystem.Reserve) {
+				full[position] = true
+				trimmed[i] = true
+			}
+		}
+		if trimmed[i] {
+			continue
+		}
+		for position, size := range measure.needs[i] {
+			used[position] += size
+		}
+	}
+	result := model.FileOperations{FileOperations: make([]model.FileOperation, len(fileOps.FileOperations))}
+	for i, fileOp := range fileOps.FileOperations {
+		if trimmed[i] {
+			fileOp = skipForSpace(fileOp)
+		}
+		result.FileOperations[i] = fileOp
+	}
+	estimate := measure.estimate(trimmed)
+	result.Space = &estimate
+	return result, len(trimmed), nil
+}
+
+//skipForSpace turns the operation into a model.SkipOp keeping its source
+func skipForSpace(fileOp model.FileOperation) model.FileOperation {
+	fileOp.OpType = model.SkipOp
+	fileOp.To = ""
+	fileOp.Reason = "not enough space in the target"
+	fileOp.Retention = ""
+	companions := make([]model.Companion, len(fileOp.Companions))
+	for i, companion := range fileOp.Companions {
+		companion.To = ""
+		companions[i] = companion
+	}
+	fileOp.Companions = companions
+	return fileOp
+}
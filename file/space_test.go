@@ -0,0 +1,115 @@
+package file_test
+
+import (
+	"copy-images/file"
+	"copy-images/model"
+	"errors"
+	"path"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+//freeBytes simulates a target with the given free space
+func freeBytes(free int64) func(string) (int64, error) {
+	return func(string) (int64, error) { return free, nil }
+}
+
+func TestEstimateSpaceSumsTheSizesOfTheCopiedFiles(t *testing.T) {
+
+	//GIVEN
+	defer file.SetFreeSpace(freeBytes(5000))()
+	sourceDir := t.TempDir()
+	targetDir := t.TempDir()
+	fileOps := model.FileOperations{FileOperations: []model.FileOperation{
+		{From: writeFile(t, path.Join(sourceDir, "IMG_1.CR2"), strings.Repeat("r", 3000)), To: path.Join(targetDir, "2021", "IMG_1.CR2"), OpType: model.MoveOp,
+			Companions: []model.Companion{{From: writeFile(t, path.Join(sourceDir, "IMG_1.jpg"), strings.Repeat("j", 1000)), To: path.Join(targetDir, "2021", "IMG_1.jpg")}}},
+		{From: writeFile(t, path.Join(sourceDir, "IMG_2.jpg"), strings.Repeat("s", 2000)), OpType: model.SkipOp},
+	}}
+
+	//WHEN
+	estimate, err := file.EstimateSpace(fileOps, 500)
+	short, shortErr := file.EstimateSpace(fileOps, 1500)
+
+	//THEN
+	assert.Nil(t, err, "No error must be thrown")
+	assert.Nil(t, shortErr, "No error must be thrown")
+	assert.Equal(t, int64(4000), estimate.Needed, "Skipped files must not be counted")
+	assert.Equal(t, []model.FileSystemSpace{{Dir: targetDir, Needed: 4000, Free: 5000, Reserve: 500}}, estimate.FileSystems)
+	assert.True(t, estimate.Sufficient())
+	assert.False(t, short.Sufficient(), "The reserve must stay free")
+
+}
+
+func TestTrimToSpaceCopiesTheOldestFilesWhichFit(t *testing.T) {
+
+	//GIVEN
+	defer file.SetFreeSpace(freeBytes(2500))()
+	sourceDir := t.TempDir()
+	targetDir := t.TempDir()
+	march, _ := time.Parse("2006-01-02", "2021-03-03")
+	var files []model.FileInfo
+	for i, month := range []int{2, 0, 1} {
+		name := string(rune('a'+i)) + ".jpg"
+		files = append(files, model.FileInfo{Path: writeFile(t, path.Join(sourceDir, name), strings.Repeat("x", 1000)), CreationDate: march.AddDate(0, month, 0)})
+	}
+	fileOps, _ := file.Planner{TargetDir: targetDir}.Plan(files)
+
+	//WHEN
+	trimmed, count, err := file.TrimToSpace(fileOps, 0)
+
+	//THEN
+	assert.Nil(t, err, "No error must be thrown")
+	assert.Equal(t, 1, count)
+	assert.Equal(t, model.SkipOp, trimmed.FileOperations[0].OpType, "The newest file must be skipped")
+	assert.Equal(t, "not enough space in the target", trimmed.FileOperations[0].Reason)
+	assert.Equal(t, "", trimmed.FileOperations[0].To)
+	assert.Equal(t, model.CopyOp, trimmed.FileOperations[1].OpType)
+	assert.Equal(t, model.CopyOp, trimmed.FileOperations[2].OpType)
+	assert.Equal(t, int64(2000), trimmed.Space.Needed)
+	assert.True(t, trimmed.Space.Sufficient())
+	assert.Equal(t, model.CopyOp, fileOps.FileOperations[0].OpType, "The given operations must not be changed")
+
+}
+
+func TestCopyFilesToRefusesToStartIfTheTargetIsTooSmall(t *testing.T) {
+
+	//GIVEN
+	defer file.SetFreeSpace(freeBytes(500))()
+	sourceDir := t.TempDir()
+	targetDir := t.TempDir()
+	photo := model.FileInfo{Path: writeFile(t, path.Join(sourceDir, "IMG_1.jpg"), strings.Repeat("x", 1000)), CreationDate: time.Now()}
+
+	//WHEN
+	err := file.CopyFilesTo(targetDir, []model.FileInfo{photo}, file.CopyConfig{})
+
+	//THEN
+	var spaceErr *file.SpaceError
+	assert.True(t, errors.As(err, &spaceErr), "A *SpaceError must be returned")
+	assert.Contains(t, err.Error(), "1.0 kB needed on "+targetDir)
+	var copied []model.FileInfo
+	file.CollectFiles(targetDir, &copied, file.CollectFilesConfig{SupportedExtensions: []string{".jpg"}})
+	assert.Empty(t, copied, "Nothing must be copied")
+
+}
+
+func TestCopyFilesToReservesNoSpace(t *testing.T) {
+
+	//GIVEN
+	defer file.SetFreeSpace(freeBytes(1500))()
+	sourceDir := t.TempDir()
+	targetDir := t.TempDir()
+	photo := model.FileInfo{Path: writeFile(t, path.Join(sourceDir, "IMG_1.jpg"), strings.Repeat("x", 1000)), CreationDate: time.Now()}
+
+	//WHEN
+	err := file.CopyFilesTo(targetDir, []model.FileInfo{photo}, file.CopyConfig{})
+
+	//THEN
+	assert.Nil(t, err, "Files which fit must be copied even if less than the DefaultSpaceReserve is left")
+	var copied []model.FileInfo
+	file.CollectFiles(targetDir, &copied, file.CollectFilesConfig{SupportedExtensions: []string{".jpg"}})
+	assert.Equal(t, 1, len(copied))
+
+}
@@ -0,0 +1,75 @@
+package file_test
+
+import (
+	"copy-images/file"
+	"copy-images/model"
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCollectFilesReadsTakeoutSidecars(t *testing.T) {
+
+	//GIVEN
+	rootDir := t.TempDir()
+	longName := "Screenshot_2021-03-03-14-15-16-123_com.example.gallery.jpg"
+	writeFile(t, path.Join(rootDir, "IMG_1(1).jpg"), "duplicate")
+	writeFile(t, path.Join(rootDir, "IMG_1.jpg(1).json"), `{"photoTakenTime": {"timestamp": "1614780916"}, "geoData": {"latitude": 48.1374, "longitude": 11.5755}}`)
+	writeFile(t, path.Join(rootDir, longName), "screenshot")
+	writeFile(t, path.Join(rootDir, longName[:46]+".json"), `{"photoTakenTime": {"timestamp": "1614867316"}}`)
+	config := file.CollectFilesConfig{SupportedExtensions: []string{".jpg"}, Takeout: true}
+	var files []model.FileInfo
+
+	//WHEN
+	err := file.CollectFiles(rootDir, &files, config)
+
+	//THEN
+	assert.Nil(t, err, "No error must be thrown")
+	assert.Equal(t, 2, len(files))
+	assert.Equal(t, time.Unix(1614780916, 0), files[0].CreationDate)
+	assert.Equal(t, model.SidecarSource, files[0].DateSource)
+	assert.Empty(t, files[0].DateConflicts, "The modification time of the export must not be consulted")
+	assert.Equal(t, &model.Location{Latitude: 48.1374, Longitude: 11.5755}, files[0].Location)
+	assert.Equal(t, []string{path.Join(rootDir, "IMG_1.jpg(1).json")}, files[0].Sidecars)
+	assert.Equal(t, time.Unix(1614867316, 0), files[1].CreationDate, "The cut json name must be matched")
+	assert.Equal(t, []string{path.Join(rootDir, longName[:46]+".json")}, files[1].Sidecars)
+	assert.Nil(t, files[1].Location)
+
+}
+
+func TestTakeoutFilesKeepTheirLocationAndDate(t *testing.T) {
+
+	//GIVEN
+	sourceDir := t.TempDir()
+	targetDir := t.TempDir()
+	taken := time.Unix(1614780916, 0)
+	location := &model.Location{Latitude: 48.1374, Longitude: 11.5755}
+	filesToCopy := []model.FileInfo{{
+		Path:         writeFile(t, path.Join(sourceDir, "IMG_1(1).jpg"), "photo"),
+		CreationDate: taken,
+		DateSource:   model.SidecarSource,
+		Location:     location,
+		Sidecars:     []string{writeFile(t, path.Join(sourceDir, "IMG_1.jpg(1).json"), "{}")},
+	}}
+	fileOps, _ := file.Planner{TargetDir: targetDir}.Plan(filesToCopy)
+
+	//WHEN
+	err := file.Executor{Progress: ioutil.Discard, SetModTime: true}.Apply(fileOps)
+
+	//THEN
+	assert.Nil(t, err, "No error must be thrown")
+	assert.Equal(t, location, fileOps.FileOperations[0].Location)
+	destination := fileOps.FileOperations[0].To
+	sidecar := fileOps.FileOperations[0].Companions[0].To
+	assert.Equal(t, destination+".json", sidecar)
+	for _, copied := range []string{destination, sidecar} {
+		info, err := os.Stat(copied)
+		assert.Nil(t, err, "The file must be copied")
+		assert.True(t, taken.Equal(info.ModTime()), "The modification time must be the date of the file")
+	}
+
+}
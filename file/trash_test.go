@@ -0,0 +1,63 @@
+package file_test
+
+import (
+	"copy-images/file"
+	"copy-images/model"
+	"copy-images/runs"
+	"copy-images/trash"
+	"io/ioutil"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMovedSourcesArePutIntoTheTrash(t *testing.T) {
+
+	//GIVEN
+	sourceDir := t.TempDir()
+	targetDir := t.TempDir()
+	march, _ := time.Parse("2006-01-02", "2021-03-03")
+	april, _ := time.Parse("2006-01-02", "2021-04-04")
+	photo := model.FileInfo{Path: writeFile(t, path.Join(sourceDir, "DCIM", "IMG_1.jpg"), "photo"), CreationDate: march}
+	fileOps, _ := file.Planner{TargetDir: targetDir, CutoffDate: april}.Plan([]model.FileInfo{photo})
+	manifest := runs.NewManifest()
+	quarantine := trash.Quarantine{Dir: path.Join(sourceDir, trash.QuarantineDirName), Root: sourceDir, RunID: manifest.RunID}
+
+	//WHEN
+	err := file.Executor{Progress: ioutil.Discard, Manifest: manifest, Trash: quarantine}.Apply(fileOps)
+
+	//THEN
+	assert.Nil(t, err, "No error must be thrown")
+	assert.False(t, fileExists(photo.Path), "The source must be moved")
+	trashed := path.Join(sourceDir, trash.QuarantineDirName, manifest.RunID, "DCIM", "IMG_1.jpg")
+	assert.True(t, fileExists(trashed), "The source must be kept in the quarantine")
+	assert.True(t, manifest.Entries[0].SourceDeleted)
+	assert.Equal(t, trashed, manifest.Entries[0].Trashed)
+
+}
+
+func TestDeletedFilesArePutIntoTheTrashAndNotCollectedAgain(t *testing.T) {
+
+	//GIVEN
+	sourceDir := t.TempDir()
+	march, _ := time.Parse("2006-01-02", "2021-03-03")
+	april, _ := time.Parse("2006-01-02", "2021-04-04")
+	old := model.FileInfo{Path: writeFile(t, path.Join(sourceDir, "IMG_1.jpg"), "photo"), CreationDate: march, Sidecars: []string{writeFile(t, path.Join(sourceDir, "IMG_1.xmp"), "rating")}}
+	writeFile(t, path.Join(sourceDir, ".Trash-1000", "files", "IMG_0.jpg"), "trashed by the desktop")
+	quarantine := trash.Quarantine{Dir: path.Join(sourceDir, trash.QuarantineDirName), Root: sourceDir, RunID: "20210404-120000-abcdef"}
+	var files []model.FileInfo
+
+	//WHEN
+	removed := file.DeleteFilesCreatedBefore(april, []model.FileInfo{old}, quarantine)
+	err := file.CollectFiles(sourceDir, &files, file.CollectFilesConfig{SupportedExtensions: []string{".jpg"}})
+
+	//THEN
+	assert.Equal(t, 1, len(removed))
+	assert.True(t, fileExists(path.Join(quarantine.Dir, quarantine.RunID, "IMG_1.jpg")))
+	assert.True(t, fileExists(path.Join(quarantine.Dir, quarantine.RunID, "IMG_1.xmp")), "The sidecar must be trashed with its file")
+	assert.Nil(t, err, "No error must be thrown")
+	assert.Empty(t, files, "Trashed files must not be collected")
+
+}
@@ -0,0 +1,177 @@
+package file
+
+import (
+	"copy-images/runs"
+	"copy-images/trash"
+	"copy-images/utils"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+//UndoReport lists what Undo changed and what it left alone
+type UndoReport struct {
+	// Removed are the copies which have been removed from the target
+	Removed []string
+	// Restored are the sources which have been put back
+	Restored []string
+	// Problems describe the paths which have been left alone
+	Problems []string
+}
+
+//Undo reverts the actions recorded in the journal of a run, the last one first. A copy is only removed and a source
+//only put back if its content still has the hash recorded by the run, files modified since are left alone and
+//reported as problems. Actions a crashed run announced without recording them as done are undone as far as they took
+//place. Sources removed for good are copied back from their copy before it is removed. Dirs of the target emptied by
+//removing copies are removed as well. Actions which have already been undone are skipped, so Undo can be repeated.
+func Undo(targetDir string, records []runs.Record) UndoReport {
+	//merge the intent and the done record of every action, keeping the order of the intents
+	var actions []runs.Record
+	positions := make(map[string]int)
+	for _, record := range records {
+		key := string(record.Action) + "\x00" + record.Path
+		i, ok := positions[key]
+		if !ok {
+			positions[key] = len(actions)
+			actions = append(actions, record)
+			continue
+		}
+		if record.Done {
+			if record.Hash == "" {
+				record.Hash = actions[i].Hash
+			}
+			actions[i] = record
+		}
+	}
+	copies := make(map[string]string)
+	for _, action := range actions {
+		if action.Action == runs.Create {
+			copies[action.From] = action.Path
+		}
+	}
+	var report UndoReport
+	for i := len(actions) - 1; i >= 0; i-- {
+		var err error
+		switch action := actions[i]; action.Action {
+		case runs.Create:
+			err = undoCreate(targetDir, action, &report)
+		case runs.Trash:
+			err = undoTrash(action, &report)
+		case runs.Remove:
+			copyPath, ok := copies[action.Path]
+			if !ok {
+				//a source deleted because its copy already existed
+				copyPath = action.From
+			}
+			err = undoRemove(action, copyPath, &report)
+		}
+		if err != nil {
+			report.Problems = append(report.Problems, err.Error())
+		}
+	}
+	return report
+}
+
+//undoCreate removes a copy the run created
+func undoCreate(targetDir string, action runs.Record, report *UndoReport) error {
+	if !exists(action.Path) {
+		return nil
+	}
+	expected := action.Hash
+	//the copy of a crashed run is only known by the content of its source
+	if expected == "" && exists(action.From) {
+		var err error
+		if expected, err = utils.HashFile(action.From); err != nil {
+			return err
+		}
+	}
+	if expected == "" {
+		return fmt.Errorf("%s: cannot tell if it is the copy of %s, keeping it", action.Path, action.From)
+	}
+	if err := checkHash(action.Path, expected); err != nil {
+		return err
+	}
+	if err := os.Remove(action.Path); err != nil {
+		return err
+	}
+	report.Removed = append(report.Removed, action.Path)
+	removeEmptyDirs(filepath.Dir(action.Path), targetDir)
+	return nil
+}
+
+//undoTrash restores a source the run put into the trash
+func undoTrash(action runs.Record, report *UndoReport) error {
+	if !action.Done {
+		if exists(action.Path) {
+			return nil
+		}
+		return fmt.Errorf("%s may have been put into the trash by the crashed run, it has to be restored by hand", action.Path)
+	}
+	if !exists(action.To) {
+		if exists(action.Path) {
+			return nil
+		}
+		return fmt.Errorf("%s is no longer in the trash at %s", action.Path, action.To)
+	}
+	if action.Hash != "" {
+		if err := checkHash(action.To, action.Hash); err != nil {
+			return err
+		}
+	}
+	if err := trash.Restore(action.To, action.Path); err != nil {
+		return err
+	}
+	report.Restored = append(report.Restored, action.Path)
+	return nil
+}
+
+//undoRemove copies a source the run removed for good back from its copy
+func undoRemove(action runs.Record, copyPath string, report *UndoReport) error {
+	if exists(action.Path) {
+		return nil
+	}
+	if copyPath == "" || action.Hash == "" || !exists(copyPath) {
+		return fmt.Errorf("%s has been removed for good and has no copy to restore it from", action.Path)
+	}
+	if err := checkHash(copyPath, action.Hash); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(action.Path), os.ModePerm); err != nil {
+		return err
+	}
+	if _, err := copyFile(copyPath, action.Path, CopyAuto, 0, nil, ""); err != nil {
+		return err
+	}
+	report.Restored = append(report.Restored, action.Path)
+	return nil
+}
+
+//checkHash returns an error if the content of the file does not have the expected hash
+func checkHash(path string, expected string) error {
+	actual, err := utils.HashFile(path)
+	if err != nil {
+		return err
+	}
+	if actual != expected {
+		return fmt.Errorf("%s has been modified since the run, keeping it", path)
+	}
+	return nil
+}
+
+//exists checks if there is a file at the path
+func exists(path string) bool {
+	_, err := os.Lstat(path)
+	return err == nil
+}
+
+//removeEmptyDirs removes the dir and its parents up to the root as long as they are empty
+func removeEmptyDirs(dir string, root string) {
+	root = filepath.Clean(root)
+	for strings.HasPrefix(dir, root+string(filepath.Separator)) {
+		if os.Remove(dir) != nil {
+			return
+		}
+		dir = filepath.Dir(dir)
+	}
+}
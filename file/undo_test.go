@@ -0,0 +1,137 @@
+package file_test
+
+import (
+	"copy-images/file"
+	"copy-images/index"
+	"copy-images/model"
+	"copy-images/runs"
+	"copy-images/trash"
+	"copy-images/utils"
+	"io/ioutil"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUndoRemovesTheCopiesAndRestoresTheTrashedSourcesOfAMove(t *testing.T) {
+
+	//GIVEN
+	sourceDir := t.TempDir()
+	targetDir := t.TempDir()
+	march, _ := time.Parse("2006-01-02", "2021-03-03")
+	april, _ := time.Parse("2006-01-02", "2021-04-04")
+	photo := model.FileInfo{Path: writeFile(t, path.Join(sourceDir, "IMG_1.jpg"), "photo"), CreationDate: march}
+	fileOps, _ := file.Planner{TargetDir: targetDir, CutoffDate: april}.Plan([]model.FileInfo{photo})
+	runID := runs.NewID()
+	quarantine := trash.Quarantine{Dir: path.Join(sourceDir, trash.QuarantineDirName), Root: sourceDir, RunID: runID}
+	journal := runs.NewJournal(targetDir, runID)
+	err := file.Executor{Progress: ioutil.Discard, Trash: quarantine, Journal: journal}.Apply(fileOps)
+	journal.Close()
+	records, readErr := runs.ReadJournal(targetDir, runID)
+
+	//WHEN
+	report := file.Undo(targetDir, records)
+	again := file.Undo(targetDir, records)
+
+	//THEN
+	assert.Nil(t, err, "No error must be thrown")
+	assert.Nil(t, readErr, "No error must be thrown")
+	assert.Equal(t, 4, len(records), "Every action must be recorded before and after it is carried out")
+	copyPath := fileOps.FileOperations[0].To
+	assert.Equal(t, []string{copyPath}, report.Removed)
+	assert.Equal(t, []string{photo.Path}, report.Restored)
+	assert.Empty(t, report.Problems)
+	assert.False(t, fileExists(copyPath), "The copy must be removed")
+	assert.False(t, fileExists(path.Dir(copyPath)), "The dirs emptied must be removed")
+	assert.True(t, fileExists(photo.Path), "The source must be restored")
+	assert.Equal(t, file.UndoReport{}, again, "A second undo must not change anything")
+
+}
+
+func TestUndoKeepsFilesModifiedSinceTheRun(t *testing.T) {
+
+	//GIVEN
+	sourceDir := t.TempDir()
+	targetDir := t.TempDir()
+	photo := model.FileInfo{Path: writeFile(t, path.Join(sourceDir, "IMG_1.jpg"), "photo"), CreationDate: time.Now()}
+	fileOps, _ := file.Planner{TargetDir: targetDir}.Plan([]model.FileInfo{photo})
+	runID := runs.NewID()
+	journal := runs.NewJournal(targetDir, runID)
+	file.Executor{Progress: ioutil.Discard, Journal: journal}.Apply(fileOps)
+	journal.Close()
+	copyPath := fileOps.FileOperations[0].To
+	writeFile(t, copyPath, "edited photo")
+	records, _ := runs.ReadJournal(targetDir, runID)
+
+	//WHEN
+	report := file.Undo(targetDir, records)
+
+	//THEN
+	assert.Empty(t, report.Removed)
+	assert.Equal(t, 1, len(report.Problems))
+	assert.Contains(t, report.Problems[0], "modified since the run")
+	assert.True(t, fileExists(copyPath), "The modified copy must be kept")
+
+}
+
+func TestUndoRevertsACrashedRunRemovingSourcesForGood(t *testing.T) {
+
+	//GIVEN
+	sourceDir := t.TempDir()
+	targetDir := t.TempDir()
+	first := writeFile(t, path.Join(targetDir, "2021", "IMG_1.jpg"), "first photo")
+	second := writeFile(t, path.Join(targetDir, "2021", "IMG_2.jpg"), "second photo")
+	firstHash, _ := utils.HashFile(first)
+	firstSource := path.Join(sourceDir, "IMG_1.jpg")
+	secondSource := writeFile(t, path.Join(sourceDir, "IMG_2.jpg"), "second photo")
+	//the run died after removing the first source and after renaming the second copy into place
+	records := []runs.Record{
+		{Action: runs.Create, Path: first, From: firstSource},
+		{Action: runs.Create, Done: true, Path: first, From: firstSource, Hash: firstHash},
+		{Action: runs.Remove, Path: firstSource, Hash: firstHash},
+		{Action: runs.Create, Path: second, From: secondSource},
+	}
+
+	//WHEN
+	report := file.Undo(targetDir, records)
+
+	//THEN
+	assert.Empty(t, report.Problems)
+	assert.ElementsMatch(t, []string{first, second}, report.Removed)
+	assert.Equal(t, []string{firstSource}, report.Restored)
+	restored, _ := ioutil.ReadFile(firstSource)
+	assert.Equal(t, "first photo", string(restored), "The removed source must be copied back from its copy")
+	assert.False(t, fileExists(path.Join(targetDir, "2021")))
+
+}
+
+func TestUndoRestoresASourceDeletedBecauseItWasImportedBefore(t *testing.T) {
+
+	//GIVEN
+	sourceDir := t.TempDir()
+	targetDir := t.TempDir()
+	march, _ := time.Parse("2006-01-02", "2021-03-03")
+	april, _ := time.Parse("2006-01-02", "2021-04-04")
+	photo := model.FileInfo{Path: writeFile(t, path.Join(sourceDir, "IMG_1.jpg"), "photo"), CreationDate: march}
+	idx, _ := index.Load(targetDir)
+	file.CopyFilesTo(targetDir, []model.FileInfo{photo}, file.CopyConfig{Index: idx})
+	reloaded, _ := index.Load(targetDir)
+	fileOps, _ := file.Planner{TargetDir: targetDir, CutoffDate: april, CopyConfig: file.CopyConfig{Index: reloaded}}.Plan([]model.FileInfo{photo})
+	runID := runs.NewID()
+	journal := runs.NewJournal(targetDir, runID)
+	file.Executor{Progress: ioutil.Discard, Journal: journal}.Apply(fileOps)
+	journal.Close()
+	records, _ := runs.ReadJournal(targetDir, runID)
+
+	//WHEN
+	report := file.Undo(targetDir, records)
+
+	//THEN
+	assert.Equal(t, []string{photo.Path}, report.Restored, "The source must be copied back from the existing copy")
+	assert.Empty(t, report.Removed, "The copy has not been created by the run")
+	assert.Empty(t, report.Problems)
+	assert.True(t, fileExists(fileOps.FileOperations[0].To))
+
+}
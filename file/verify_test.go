@@ -0,0 +1,94 @@
+package file_test
+
+import (
+	"copy-images/file"
+	"copy-images/model"
+	"copy-images/runs"
+	"copy-images/utils"
+	"io/ioutil"
+	"path"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMovedFilesAreVerifiedBeforeTheSourceIsDeleted(t *testing.T) {
+
+	//GIVEN
+	sourceDir := t.TempDir()
+	targetDir := t.TempDir()
+	moved := writeFile(t, path.Join(sourceDir, "IMG_1.jpg"), "holiday")
+	copied := writeFile(t, path.Join(sourceDir, "IMG_2.jpg"), "beach")
+	fileOps := model.FileOperations{FileOperations: []model.FileOperation{
+		{From: moved, To: path.Join(targetDir, "IMG_1.jpg"), OpType: model.MoveOp},
+		{From: copied, To: path.Join(targetDir, "IMG_2.jpg"), OpType: model.CopyOp},
+	}}
+	manifest := runs.NewManifest()
+
+	//WHEN
+	err := file.Executor{Manifest: manifest, Progress: ioutil.Discard}.Apply(fileOps)
+
+	//THEN
+	assert.Nil(t, err, "No error must be thrown")
+	assert.False(t, fileExists(moved), "The verified source must be removed")
+	assert.Equal(t, runs.Verified, manifest.Entries[0].Status)
+	assert.Equal(t, manifest.Entries[0].SourceHash, manifest.Entries[0].DestinationHash)
+	assert.True(t, manifest.Entries[0].SourceDeleted)
+	assert.Equal(t, runs.Copied, manifest.Entries[1].Status, "Copies are only verified on request")
+
+}
+
+func TestMismatchingCopyKeepsTheSource(t *testing.T) {
+
+	//GIVEN
+	sourceDir := t.TempDir()
+	targetDir := t.TempDir()
+	corrupted := writeFile(t, path.Join(sourceDir, "IMG_1.jpg"), "holiday")
+	intact := writeFile(t, path.Join(sourceDir, "IMG_2.jpg"), "beach")
+	fileOps := model.FileOperations{FileOperations: []model.FileOperation{
+		{From: corrupted, To: path.Join(targetDir, "IMG_1.jpg"), OpType: model.MoveOp},
+		{From: intact, To: path.Join(targetDir, "IMG_2.jpg"), OpType: model.MoveOp},
+	}}
+	manifest := runs.NewManifest()
+	restore := file.SetHashDestination(func(filePath string) (string, error) {
+		if filePath == fileOps.FileOperations[0].To {
+			return "written garbage", nil
+		}
+		return utils.HashFile(filePath)
+	})
+	defer restore()
+
+	//WHEN
+	err := file.Executor{Manifest: manifest, Progress: ioutil.Discard}.Apply(fileOps)
+
+	//THEN
+	assert.NotNil(t, err, "The mismatch must be reported")
+	assert.Contains(t, err.Error(), "1 copies do not match their source")
+	assert.Contains(t, err.Error(), corrupted)
+	assert.True(t, fileExists(corrupted), "The source of the mismatching copy must be kept")
+	assert.False(t, fileExists(fileOps.FileOperations[0].To), "The mismatching copy must be removed")
+	assert.False(t, fileExists(intact), "The other operations must be carried out")
+	assert.Equal(t, runs.Mismatch, manifest.Entries[0].Status)
+	assert.False(t, manifest.Entries[0].SourceDeleted)
+	assert.Equal(t, runs.Verified, manifest.Entries[1].Status)
+
+}
+
+func TestVerifyCopiesReadsBackCopies(t *testing.T) {
+
+	//GIVEN
+	sourceDir := t.TempDir()
+	targetDir := t.TempDir()
+	source := writeFile(t, path.Join(sourceDir, "IMG_1.jpg"), "holiday")
+	fileOps := model.FileOperations{FileOperations: []model.FileOperation{{From: source, To: path.Join(targetDir, "IMG_1.jpg"), OpType: model.CopyOp}}}
+	manifest := runs.NewManifest()
+
+	//WHEN
+	err := file.Executor{VerifyCopies: true, Manifest: manifest, Progress: ioutil.Discard}.Apply(fileOps)
+
+	//THEN
+	assert.Nil(t, err, "No error must be thrown")
+	assert.Equal(t, runs.Verified, manifest.Entries[0].Status)
+	assert.True(t, fileExists(source), "A verified copy must keep its source")
+
+}
@@ -0,0 +1,343 @@
+package file
+
+import (
+	"copy-images/dates"
+	"copy-images/ignore"
+	"copy-images/model"
+	"copy-images/sniff"
+	"copy-images/takeout"
+	"copy-images/trash"
+	"copy-images/utils"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+//DefaultWalkWorkers is the number of dirs read concurrently if CollectFilesConfig.Workers is not set
+const DefaultWalkWorkers = 8
+
+//WalkFiles streams all files below rootDir matching the collectFilesConfig over the files channel and closes it once
+//the walk is done. Dirs are read by a pool of CollectFilesConfig.Workers goroutines with os.ReadDir, which does not
+//need to stat every entry, so the order of the files is not defined. Paths which cannot be read, like permission
+//denied dirs below Android/data, are skipped and returned as *ScanReport after all other files have been sent, so
+//are symlinks to dirs which are not followed, see ErrSymlinkedDir.
+//Only a root which cannot be read and invalid exclusion rules are returned as is.
+func WalkFiles(rootDir string, files chan<- model.FileInfo, collectFilesConfig CollectFilesConfig) error {
+	defer close(files)
+	exclusions, err := ignore.Compile(collectFilesConfig.ExcludedDirs)
+	if err != nil {
+		return err
+	}
+	// unlike the entries below it the root is followed if it is a symlink
+	info, err := os.Stat(rootDir)
+	if err != nil {
+		return err
+	}
+	w := newWalker(rootDir, files, collectFilesConfig)
+	if !info.IsDir() {
+		w.visit(rootDir, info, nil)
+		return nil
+	}
+	ignoreFile, err := ignore.ReadFile(filepath.Join(rootDir, ignore.FileName))
+	if err != nil {
+		return err
+	}
+	w.exclusions = exclusions.Append(ignoreFile)
+
+	workers := collectFilesConfig.Workers
+	if workers < 1 {
+		workers = DefaultWalkWorkers
+	}
+	w.queue.push(rootDir)
+	var wg sync.WaitGroup
+	for worker := 0; worker < workers; worker++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for dir, ok := w.queue.pop(); ok; dir, ok = w.queue.pop() {
+				w.readDir(dir)
+				w.queue.done()
+			}
+		}()
+	}
+	wg.Wait()
+	if len(w.problems) > 0 {
+		sortScanProblems(w.problems)
+		return &ScanReport{Root: rootDir, Problems: w.problems}
+	}
+	return nil
+}
+
+//walker holds the state shared by the workers of a walk
+type walker struct {
+	root          string
+	exclusions    *ignore.Matcher
+	files         chan<- model.FileInfo
+	config        CollectFilesConfig
+	dateResolvers dates.Chain
+	queue         *dirQueue
+	mu            sync.Mutex
+	problems      []ScanProblem
+}
+
+//readDir reads the entries of a dir, it is replaced by tests to simulate unreadable dirs
+var readDir = os.ReadDir
+
+//newWalker creates a walker of the root sending to the files channel, it excludes nothing
+func newWalker(rootDir string, files chan<- model.FileInfo, collectFilesConfig CollectFilesConfig) *walker {
+	dateResolvers := collectFilesConfig.DateResolvers
+	if len(dateResolvers) == 0 && collectFilesConfig.Takeout {
+		dateResolvers = dates.TakeoutChain()
+	} else if len(dateResolvers) == 0 {
+		dateResolvers = dates.DefaultChain()
+	}
+	return &walker{root: rootDir, exclusions: &ignore.Matcher{}, files: files, config: collectFilesConfig, dateResolvers: dateResolvers, queue: newDirQueue()}
+}
+
+//readDir visits all files of the dir and queues its sub dirs which are not excluded
+func (w *walker) readDir(dir string) {
+	entries, err := readDir(dir)
+	if err != nil {
+		w.fail(dir, "readdir", err)
+	}
+	// ReadDir returns the entries it could read before the error
+	var candidates []os.DirEntry
+	var names, sidecars []string
+	for _, entry := range entries {
+		path := filepath.Join(dir, entry.Name())
+		if entry.Type()&os.ModeSymlink != 0 && isDirLink(path) {
+			// symlinked dirs are not followed as they may loop or leave the source, a skipped subtree is reported
+			if !trash.IsTrashDir(entry.Name()) && !w.exclusions.SkipDir(w.relative(path)) {
+				w.fail(path, "symlink", ErrSymlinkedDir)
+			}
+			continue
+		}
+		if entry.IsDir() {
+			// trashed files must not be collected again
+			if !trash.IsTrashDir(entry.Name()) && !w.exclusions.SkipDir(w.relative(path)) {
+				w.queue.push(path)
+			}
+			continue
+		}
+		if w.exclusions.Excluded(w.relative(path), false) {
+			continue
+		}
+		switch {
+		case w.supported(path) || (w.config.Sniff && !isSidecar(path)):
+			candidates = append(candidates, entry)
+			names = append(names, entry.Name())
+		case isSidecar(path):
+			sidecars = append(sidecars, entry.Name())
+		}
+	}
+	var jsons []string
+	if w.config.Takeout {
+		jsons, sidecars = splitJSON(sidecars)
+	}
+	matched := matchSidecars(names, sidecars)
+	for name, json := range takeout.Match(names, jsons) {
+		matched[strings.ToLower(name)] = append(matched[strings.ToLower(name)], json)
+	}
+	for _, entry := range candidates {
+		path := filepath.Join(dir, entry.Name())
+		// only the matching files are stat'ed, with sniffing all files have to be read
+		info, err := entry.Info()
+		if err != nil {
+			w.fail(path, "stat", err)
+			continue
+		}
+		var sidecarPaths []string
+		for _, sidecar := range matched[strings.ToLower(entry.Name())] {
+			sidecarPaths = append(sidecarPaths, filepath.Join(dir, sidecar))
+		}
+		w.visit(path, info, sidecarPaths)
+	}
+}
+
+//isDirLink checks if the symlink points to a dir, a broken symlink does not
+func isDirLink(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && info.IsDir()
+}
+
+//relative returns the path relative to the root the exclusion rules are matched against
+func (w *walker) relative(path string) string {
+	rel, err := filepath.Rel(w.root, path)
+	if err != nil {
+		return path
+	}
+	return rel
+}
+
+//supported checks if the file has one of the SupportedExtensions
+func (w *walker) supported(path string) bool {
+	return utils.ItemExists(w.config.SupportedExtensions, strings.ToLower(filepath.Ext(path)))
+}
+
+//visit resolves the date of a matching file, hashes it if CollectFilesConfig.Hash selects it and sends it with its
+//sidecars. A file which cannot be hashed is skipped.
+func (w *walker) visit(path string, info os.FileInfo, sidecars []string) {
+	if info.IsDir() {
+		return
+	}
+	supported := w.supported(path)
+	f := model.FileInfo{Path: path, Size: info.Size(), MediaType: mediaType(path), Sidecars: sidecars}
+	// only regular files are sniffed, opening a fifo would block
+	if w.config.Sniff && info.Mode().IsRegular() {
+		detected, ok, err := sniff.DetectFile(path)
+		if err != nil {
+			w.fail(path, "sniff", err)
+		} else if ok {
+			f.MimeType = detected.MimeType
+			f.MediaType = model.PhotoMedia
+			if detected.Video() {
+				f.MediaType = model.VideoMedia
+			}
+			supported = supported || w.supportedType(detected)
+		}
+	}
+	if !supported {
+		return
+	}
+	dateResolvers := w.dateResolvers
+	if json := takeoutJSON(f); w.config.Takeout && json != "" {
+		dateResolvers = dateResolvers.WithSidecar(json)
+		metadata, err := takeout.Read(json)
+		if err != nil {
+			w.fail(json, "takeout", err)
+		}
+		f.Location = metadata.Location
+	}
+	resolution := dateResolvers.Resolve(path, info)
+	f.CreationDate, f.DateSource, f.DateConflicts = resolution.Date, resolution.Source, resolution.Conflicts
+	f.CameraModel = resolution.CameraModel
+	if w.config.Hash != nil && w.config.Hash(f) {
+		hash, err := utils.HashFile(path)
+		if err != nil {
+			w.fail(path, "hash", err)
+			return
+		}
+		f.Hash = hash
+	}
+	w.files <- f
+}
+
+//splitJSON separates the json sidecars from the others
+func splitJSON(sidecars []string) (jsons []string, others []string) {
+	for _, sidecar := range sidecars {
+		if strings.EqualFold(filepath.Ext(sidecar), ".json") {
+			jsons = append(jsons, sidecar)
+		} else {
+			others = append(others, sidecar)
+		}
+	}
+	return jsons, others
+}
+
+//takeoutJSON returns the path of the json sidecar of the file, it is empty if there is none
+func takeoutJSON(f model.FileInfo) string {
+	for _, sidecar := range f.Sidecars {
+		if strings.EqualFold(filepath.Ext(sidecar), ".json") {
+			return sidecar
+		}
+	}
+	return ""
+}
+
+//supportedType checks if files of the type may be named with one of the SupportedExtensions
+func (w *walker) supportedType(t sniff.Type) bool {
+	for _, extension := range t.Extensions {
+		if utils.ItemExists(w.config.SupportedExtensions, extension) {
+			return true
+		}
+	}
+	return false
+}
+
+//fail records a problem of the walk
+func (w *walker) fail(path string, op string, err error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.problems = append(w.problems, newScanProblem(path, op, err))
+}
+
+//dirQueue holds the dirs still to be read. It is closed once every pushed dir has been marked as done.
+type dirQueue struct {
+	mu      sync.Mutex
+	cond    *sync.Cond
+	dirs    []string
+	pending int
+	closed  bool
+}
+
+//newDirQueue creates an empty queue
+func newDirQueue() *dirQueue {
+	q := &dirQueue{}
+	q.cond = sync.NewCond(&q.mu)
+	return q
+}
+
+//push queues a dir
+func (q *dirQueue) push(dir string) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.dirs = append(q.dirs, dir)
+	q.pending++
+	q.cond.Signal()
+}
+
+//pop waits for the next dir, it returns false once the queue is closed.
+//The last pushed dir is returned first so that the walk goes deep and the queue stays short.
+func (q *dirQueue) pop() (string, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for len(q.dirs) == 0 && !q.closed {
+		q.cond.Wait()
+	}
+	if len(q.dirs) == 0 {
+		return "", false
+	}
+	dir := q.dirs[len(q.dirs)-1]
+	q.dirs = q.dirs[:len(q.dirs)-1]
+	return dir, true
+}
+
+//done marks a popped dir as read, all its sub dirs have been pushed before
+func (q *dirQueue) done() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.pending--
+	if q.pending == 0 {
+		q.closed = true
+		q.cond.Broadcast()
+	}
+}
+
+//SortFiles sorts the files in the order filepath.Walk visits them, the files of a dir before the files of the
+//sub dirs whose names sort after them
+func SortFiles(files []model.FileInfo) {
+	sort.SliceStable(files, func(i, j int) bool {
+		return walkOrderLess(files[i].Path, files[j].Path)
+	})
+}
+
+//sortScanProblems sorts the problems by their paths in the order filepath.Walk visits them
+func sortScanProblems(problems []ScanProblem) {
+	sort.SliceStable(problems, func(i, j int) bool {
+		return walkOrderLess(problems[i].Path, problems[j].Path)
+	})
+}
+
+//walkOrderLess compares the paths element by element like filepath.Walk orders them
+func walkOrderLess(a string, b string) bool {
+	aElements := strings.Split(filepath.ToSlash(a), "/")
+	bElements := strings.Split(filepath.ToSlash(b), "/")
+	for i := 0; i < len(aElements) && i < len(bElements); i++ {
+		if aElements[i] != bElements[i] {
+			return aElements[i] < bElements[i]
+		}
+	}
+	return len(aElements) < len(bElements)
+}
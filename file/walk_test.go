@@ -0,0 +1,146 @@
+package file_test
+
+import (
+	"copy-images/file"
+	"copy-images/index"
+	"copy-images/model"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCollectFilesKeepsTheOrderOfFilepathWalk(t *testing.T) {
+
+	//GIVEN
+	rootDir := t.TempDir()
+	for _, name := range []string{"a/x.jpg", "a-b.jpg", "a/b/c.jpg", "a/b.jpg", "B/IMG_1.JPG", "b.jpg", "a/b/d/e.jpg", "z.txt"} {
+		writeFile(t, path.Join(rootDir, name), name)
+	}
+	var expected []string
+	filepath.Walk(rootDir, func(filePath string, info os.FileInfo, err error) error {
+		if !info.IsDir() && strings.EqualFold(filepath.Ext(filePath), ".jpg") {
+			expected = append(expected, filePath)
+		}
+		return nil
+	})
+	var files []model.FileInfo
+
+	//WHEN
+	err := file.CollectFiles(rootDir, &files, file.CollectFilesConfig{SupportedExtensions: []string{".jpg"}, Workers: 3})
+
+	//THEN
+	assert.Nil(t, err, "No error must be thrown")
+	var collected []string
+	for _, f := range files {
+		collected = append(collected, f.Path)
+	}
+	assert.Equal(t, expected, collected)
+
+}
+
+func TestWalkFilesStreamsMatchingFilesAndClosesTheChannel(t *testing.T) {
+
+	//GIVEN
+	rootDir := t.TempDir()
+	for i, name := range []string{"DCIM/IMG_1.jpg", "DCIM/.thumbnails/IMG_1.jpg", "DCIM/Camera/VID_1.mp4", "notes.txt"} {
+		writeFile(t, path.Join(rootDir, name), strings.Repeat("x", i+1))
+	}
+	config := file.CollectFilesConfig{SupportedExtensions: []string{".jpg", ".mp4"}, ExcludedDirs: []string{".thumbnails"}}
+	found := make(chan model.FileInfo)
+	walkErr := make(chan error, 1)
+
+	//WHEN
+	go func() {
+		walkErr <- file.WalkFiles(rootDir, found, config)
+	}()
+	streamed := make(map[string]model.FileInfo)
+	for f := range found {
+		streamed[f.Path] = f
+	}
+
+	//THEN
+	assert.Nil(t, <-walkErr, "No error must be thrown")
+	assert.Equal(t, 2, len(streamed))
+	assert.Equal(t, int64(1), streamed[path.Join(rootDir, "DCIM", "IMG_1.jpg")].Size)
+	assert.Equal(t, model.VideoMedia, streamed[path.Join(rootDir, "DCIM", "Camera", "VID_1.mp4")].MediaType)
+
+}
+
+func TestWalkFilesReportsAMissingRoot(t *testing.T) {
+
+	//GIVEN
+	found := make(chan model.FileInfo)
+
+	//WHEN
+	err := file.WalkFiles(path.Join(t.TempDir(), "missing"), found, basicCollectConfig)
+
+	//THEN
+	assert.True(t, os.IsNotExist(err), "The missing root must be reported")
+	_, open := <-found
+	assert.False(t, open, "The channel must be closed")
+
+}
+
+func TestCollectFilesAppliesExclusionRulesAndTheIgnoreFile(t *testing.T) {
+
+	//GIVEN
+	rootDir := t.TempDir()
+	for _, name := range []string{"DCIM/Camera/IMG_1.jpg", "DCIM/Camera/keep/IMG_2.jpg", "Pictures/Metadata Trips/IMG_3.jpg", "Android/data/app/IMG_4.jpg", "Pictures/IMG_5.jpg"} {
+		writeFile(t, path.Join(rootDir, name), name)
+	}
+	writeFile(t, path.Join(rootDir, ".copyimagesignore"), "# keep the selection\n!DCIM/Camera/keep/**\n/Pictures/*.jpg\n")
+	config := file.CollectFilesConfig{SupportedExtensions: []string{".jpg"}, ExcludedDirs: []string{"data", "DCIM/Camera"}}
+	var files []model.FileInfo
+
+	//WHEN
+	err := file.CollectFiles(rootDir, &files, config)
+
+	//THEN
+	assert.Nil(t, err, "No error must be thrown")
+	var collected []string
+	for _, f := range files {
+		collected = append(collected, f.Path)
+	}
+	assert.Equal(t, []string{path.Join(rootDir, "DCIM/Camera/keep/IMG_2.jpg"), path.Join(rootDir, "Pictures/Metadata Trips/IMG_3.jpg")}, collected)
+
+}
+
+func TestCollectFilesRejectsInvalidExclusionRules(t *testing.T) {
+
+	//GIVEN
+	var files []model.FileInfo
+
+	//WHEN
+	err := file.CollectFiles(t.TempDir(), &files, file.CollectFilesConfig{SupportedExtensions: []string{".jpg"}, ExcludedDirs: []string{"[a-"}})
+
+	//THEN
+	assert.NotNil(t, err, "The invalid rule must be reported")
+
+}
+
+func TestCollectFilesHashesTheFilesThePlannerNeedsWhileWalking(t *testing.T) {
+
+	//GIVEN
+	sourceDir := t.TempDir()
+	targetDir := t.TempDir()
+	imported := writeFile(t, path.Join(sourceDir, "IMG_1.jpg"), "holiday")
+	idx, _ := index.Load(targetDir)
+	file.CopyFilesTo(targetDir, []model.FileInfo{{Path: imported}}, file.CopyConfig{Index: idx})
+	writeFile(t, path.Join(sourceDir, "IMG_2.jpg"), "abc")
+	copyConfig := file.CopyConfig{SkipDuplicates: true, Index: idx}
+	var files []model.FileInfo
+
+	//WHEN
+	err := file.CollectFiles(sourceDir, &files, file.CollectFilesConfig{SupportedExtensions: []string{".jpg"}, Hash: copyConfig.HashNeeded})
+
+	//THEN
+	assert.Nil(t, err, "No error must be thrown")
+	assert.Equal(t, 2, len(files))
+	assert.Equal(t, "", files[0].Hash, "A file imported before must not be hashed")
+	assert.Equal(t, "ba7816bf8f01cfea414140de5dae2223b00361a396177a9cb410ff61f20015ad", files[1].Hash)
+
+}
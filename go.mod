@@ -1,5 +1,8 @@
 module copy-images
 
-go 1.14
+go 1.16
 
-require github.com/stretchr/testify v1.7.0
+require (
+	github.com/stretchr/testify v1.7.0
+	gopkg.in/yaml.v3 v3.0.1
+)
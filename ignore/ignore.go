@@ -0,0 +1,246 @@
+// Package ignore decides which paths below a source are skipped by gitignore-style exclusion rules like
+// "Android/data", "*.tmp", "/DCIM/Camera/**" or "!DCIM/Camera/keep/**".
+//
+// Rules are matched against the path relative to the source root with "/" separators, ignoring case:
+//   - A rule matches whole path segments, "data" matches the dir "data" but not "Metadata Trips".
+//   - "*" matches any part of a single segment, "?" a single character and "[a-z]" a character class.
+//   - "**" as a whole segment matches zero or more segments, a trailing "/**" matches everything below a dir.
+//   - A rule starting with "/" is anchored to the source root, all other rules match at any depth.
+//   - A rule ending with "/" only matches dirs.
+//   - A rule starting with "!" re-includes the paths it matches. A "\" escapes a leading "!" or "#".
+//   - A rule matching a dir matches everything below it. The last rule matching a path or one of its dirs wins, so
+//     unlike with git a negation can re-include paths below an excluded dir. An excluded dir is only read if a negation
+//     names a path along it, see SkipDir.
+package ignore
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// FileName is the name of the file in the source root whose rules are added to the configured ones
+const FileName = ".copyimagesignore"
+
+// rule is a single compiled pattern
+type rule struct {
+	segments []string
+	negate   bool
+	dirOnly  bool
+}
+
+// Matcher holds compiled rules, the zero value excludes nothing
+type Matcher struct {
+	rules []rule
+}
+
+// Compile compiles the patterns, the later ones take precedence
+func Compile(patterns []string) (*Matcher, error) {
+	m := &Matcher{}
+	for _, pattern := range patterns {
+		r, err := compile(pattern)
+		if err != nil {
+			return nil, err
+		}
+		m.rules = append(m.rules, r)
+	}
+	return m, nil
+}
+
+// compile parses a single pattern
+func compile(pattern string) (rule, error) {
+	r := rule{}
+	p := strings.TrimSpace(pattern)
+	if strings.HasPrefix(p, "!") {
+		r.negate = true
+		p = p[1:]
+	} else if strings.HasPrefix(p, `\!`) || strings.HasPrefix(p, `\#`) {
+		p = p[1:]
+	}
+	if strings.HasSuffix(p, "/") {
+		r.dirOnly = true
+		p = strings.TrimRight(p, "/")
+	}
+	anchored := strings.HasPrefix(p, "/")
+	if !anchored {
+		r.segments = append(r.segments, "**")
+	}
+	for _, segment := range strings.Split(strings.ToLower(p), "/") {
+		if segment == "" || segment == "." {
+			continue
+		}
+		if segment == ".." {
+			return rule{}, fmt.Errorf("pattern %q must not leave the source", pattern)
+		}
+		if _, err := path.Match(segment, ""); err != nil {
+			return rule{}, fmt.Errorf("pattern %q: %v", pattern, err)
+		}
+		r.segments = append(r.segments, segment)
+	}
+	if len(r.segments) == 0 || (!anchored && len(r.segments) == 1) {
+		return rule{}, fmt.Errorf("pattern %q does not match any path", pattern)
+	}
+	return r, nil
+}
+
+// Literal returns a rule anchored to the source root which matches the relative path exactly, even if it contains
+// characters like "*" or "["
+func Literal(relPath string) string {
+	var escaped strings.Builder
+	escaped.WriteString("/")
+	for _, r := range filepath.ToSlash(relPath) {
+		if strings.ContainsRune(`\*?[`, r) {
+			escaped.WriteRune('\\')
+		}
+		escaped.WriteRune(r)
+	}
+	return escaped.String()
+}
+
+// ReadFile compiles the rules of an ignore file, one pattern per line. Blank lines and lines starting with "#" are
+// skipped. A file which does not exist has no rules.
+func ReadFile(filePath string) (*Matcher, error) {
+	f, err := os.Open(filePath)
+	if os.IsNotExist(err) {
+		return &Matcher{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	m := &Matcher{}
+	scanner := bufio.NewScanner(f)
+	for line := 1; scanner.Scan(); line++ {
+		pattern := strings.TrimSpace(scanner.Text())
+		if pattern == "" || strings.HasPrefix(pattern, "#") {
+			continue
+		}
+		r, err := compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", filePath, line, err)
+		}
+		m.rules = append(m.rules, r)
+	}
+	return m, scanner.Err()
+}
+
+// Append returns a matcher holding the rules of m followed by the ones of other, which take precedence
+func (m *Matcher) Append(other *Matcher) *Matcher {
+	rules := make([]rule, 0, len(m.rules)+len(other.rules))
+	return &Matcher{rules: append(append(rules, m.rules...), other.rules...)}
+}
+
+// Excluded checks if the path relative to the source root is excluded. The root itself is never excluded.
+func (m *Matcher) Excluded(relPath string, isDir bool) bool {
+	segments := split(relPath)
+	if len(segments) == 0 {
+		return false
+	}
+	excluded := false
+	for _, r := range m.rules {
+		if r.matches(segments, isDir) {
+			excluded = !r.negate
+		}
+	}
+	return excluded
+}
+
+// SkipDir checks if nothing below the dir relative to the source root can be included, so that it does not need to
+// be read. That is the case if it is excluded and no negation can match a path below it. A negation with several
+// segments which is not anchored, like "DCIM/Camera/keep/**", only keeps the dirs along its path like "DCIM/Camera" or
+// "Backup/DCIM" from being skipped, not every excluded dir such as "Android/data".
+func (m *Matcher) SkipDir(relPath string) bool {
+	if !m.Excluded(relPath, true) {
+		return false
+	}
+	segments := split(relPath)
+	for _, r := range m.rules {
+		if r.negate && matchesBelow(r.segments, segments) {
+			return false
+		}
+	}
+	return true
+}
+
+// split splits the relative path into lower case segments
+func split(relPath string) []string {
+	relPath = strings.Trim(filepath.ToSlash(relPath), "/")
+	if relPath == "" || relPath == "." {
+		return nil
+	}
+	return strings.Split(strings.ToLower(relPath), "/")
+}
+
+// matches checks if the rule matches the path or one of its dirs
+func (r rule) matches(segments []string, isDir bool) bool {
+	if (isDir || !r.dirOnly) && match(r.segments, segments) {
+		return true
+	}
+	for i := len(segments) - 1; i > 0; i-- {
+		if match(r.segments, segments[:i]) {
+			return true
+		}
+	}
+	return false
+}
+
+// match checks if the pattern segments match all path segments
+func match(pattern []string, segments []string) bool {
+	for len(pattern) > 0 {
+		if len(pattern) == 1 && pattern[0] == "**" {
+			return len(segments) > 0
+		}
+		if pattern[0] == "**" {
+			for i := 0; i <= len(segments); i++ {
+				if match(pattern[1:], segments[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(segments) == 0 {
+			return false
+		}
+		if ok, _ := path.Match(pattern[0], segments[0]); !ok {
+			return false
+		}
+		pattern, segments = pattern[1:], segments[1:]
+	}
+	return len(segments) == 0
+}
+
+// matchesBelow checks if the pattern segments can match a path below the dir segments. A rule which is not anchored
+// only reaches into the dir if the rest of it could continue one of the dir's suffixes, like "DCIM/Camera/keep/**"
+// below "Backup/DCIM", or if it is a single segment like "keep.jpg" which can match in every dir.
+func matchesBelow(pattern []string, dir []string) bool {
+	if len(pattern) > 0 && pattern[0] == "**" {
+		for i := range dir {
+			if prefixMatches(pattern[1:], dir[i:]) {
+				return true
+			}
+		}
+		return len(pattern) == 2
+	}
+	return prefixMatches(pattern, dir)
+}
+
+// prefixMatches checks if the dir segments match a prefix of the pattern segments which leaves segments to match below
+// the dir
+func prefixMatches(pattern []string, dir []string) bool {
+	for len(dir) > 0 {
+		if len(pattern) == 0 {
+			return false
+		}
+		if pattern[0] == "**" {
+			return true
+		}
+		if ok, _ := path.Match(pattern[0], dir[0]); !ok {
+			return false
+		}
+		pattern, dir = pattern[1:], dir[1:]
+	}
+	return len(pattern) > 0
+}
@@ -0,0 +1,158 @@
+package ignore_test
+
+import (
+	"copy-images/ignore"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExcluded(t *testing.T) {
+	tests := []struct {
+		name     string
+		patterns []string
+		path     string
+		isDir    bool
+		excluded bool
+	}{
+		{"a name matches a whole segment", []string{"data"}, "Android/data", true, true},
+		{"a name does not match part of a segment", []string{"data"}, "Pictures/Metadata Trips", true, false},
+		{"a name matches at any depth", []string{".thumbnails"}, "DCIM/Camera/.thumbnails/1.jpg", false, true},
+		{"matching ignores case", []string{"Android/Data"}, "android/DATA/com.app/cache.jpg", false, true},
+		{"a path matches at any depth", []string{"WhatsApp/.Shared"}, "Android/media/com.whatsapp/WhatsApp/.Shared", true, true},
+		{"a path does not match a prefix of a segment", []string{"WhatsApp/.Shared"}, "WhatsApp/.SharedOld", true, false},
+		{"a leading slash anchors to the root", []string{"/DCIM"}, "Backup/DCIM/IMG_1.jpg", false, false},
+		{"an anchored rule matches at the root", []string{"/DCIM"}, "DCIM/IMG_1.jpg", false, true},
+		{"the root is never excluded", []string{"**"}, ".", true, false},
+		{"a star matches within a segment", []string{"*.tmp"}, "DCIM/IMG_1.jpg.tmp", false, true},
+		{"a star does not cross segments", []string{"/DCIM/*.jpg"}, "DCIM/Camera/IMG_1.jpg", false, false},
+		{"a question mark matches one character", []string{"IMG_?.jpg"}, "IMG_12.jpg", false, false},
+		{"a character class", []string{"IMG_[0-4].jpg"}, "DCIM/IMG_3.jpg", false, true},
+		{"a double star matches no segment", []string{"/DCIM/**/IMG_1.jpg"}, "DCIM/IMG_1.jpg", false, true},
+		{"a double star matches many segments", []string{"/DCIM/**/IMG_1.jpg"}, "DCIM/a/b/c/IMG_1.jpg", false, true},
+		{"a trailing double star matches the content only", []string{"/DCIM/**"}, "DCIM", true, false},
+		{"a trailing slash matches dirs", []string{"cache/"}, "app/cache", true, true},
+		{"a trailing slash does not match files", []string{"cache/"}, "app/cache", false, false},
+		{"a trailing slash matches files in dirs", []string{"cache/"}, "app/cache/IMG_1.jpg", false, true},
+		{"a negation re-includes a file", []string{"*.jpg", "!keep.jpg"}, "DCIM/keep.jpg", false, false},
+		{"a negation re-includes below an excluded dir", []string{"DCIM/Camera", "!DCIM/Camera/keep/**"}, "DCIM/Camera/keep/IMG_1.jpg", false, false},
+		{"a negation keeps the rest of the dir excluded", []string{"DCIM/Camera", "!DCIM/Camera/keep/**"}, "DCIM/Camera/IMG_1.jpg", false, true},
+		{"the last matching rule wins", []string{"!keep.jpg", "*.jpg"}, "keep.jpg", false, true},
+		{"a later exclusion below a negation", []string{"DCIM", "!DCIM/Camera", "DCIM/Camera/.trash"}, "DCIM/Camera/.trash/IMG_1.jpg", false, true},
+		{"an escaped exclamation mark is literal", []string{`\!important`}, "!important", true, true},
+		{"windows separators", []string{"Android/data"}, `Android\data\IMG_1.jpg`, false, filepath.Separator == '\\'},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			//GIVEN
+			matcher, err := ignore.Compile(tt.patterns)
+			assert.Nil(t, err, "No error must be thrown")
+
+			//WHEN
+			excluded := matcher.Excluded(tt.path, tt.isDir)
+
+			//THEN
+			assert.Equal(t, tt.excluded, excluded)
+
+		})
+	}
+}
+
+func TestSkipDir(t *testing.T) {
+	tests := []struct {
+		name     string
+		patterns []string
+		dir      string
+		skip     bool
+	}{
+		{"an excluded dir is skipped", []string{"Android/data"}, "Android/data", true},
+		{"an included dir is read", []string{"Android/data"}, "Android", false},
+		{"a dir with a negation below it is read", []string{"DCIM/Camera", "!DCIM/Camera/keep/**"}, "DCIM/Camera", false},
+		{"a dir beside a negation is skipped", []string{"DCIM/Camera", "!/DCIM/Camera/keep/**"}, "DCIM/Camera/other", true},
+		{"a negation at any depth can match below every dir", []string{"DCIM", "!keep.jpg"}, "DCIM/Camera", false},
+		{"a negation of the dir itself", []string{"DCIM", "!/DCIM"}, "DCIM", false},
+		{"a negation at any depth reads the dirs along its path", []string{"Backup", "!DCIM/Camera/keep/**"}, "Backup/DCIM", false},
+		{"a negation at any depth does not read other excluded dirs", append(defaultExclusions(), "!DCIM/Camera/keep/**"), "Android/data", true},
+		{"a negation beside the default exclusions is read", append(defaultExclusions(), "DCIM/Camera", "!DCIM/Camera/keep/**"), "DCIM/Camera", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			//GIVEN
+			matcher, err := ignore.Compile(tt.patterns)
+			assert.Nil(t, err, "No error must be thrown")
+
+			//WHEN
+			skip := matcher.SkipDir(tt.dir)
+
+			//THEN
+			assert.Equal(t, tt.skip, skip)
+
+		})
+	}
+}
+
+// defaultExclusions returns the exclusion rules used if no --exclude flag is given
+func defaultExclusions() []string {
+	return []string{"Android/Data", ".thumbnails", "WhatsApp/.Shared", "WhatsApp/Media/.Statuses", "WhatsApp/.Thumbs"}
+}
+
+func TestInvalidPatternsAreRejected(t *testing.T) {
+	for _, pattern := range []string{"", "!", "/", "[a-", "../DCIM"} {
+		t.Run(pattern, func(t *testing.T) {
+
+			//WHEN
+			_, err := ignore.Compile([]string{pattern})
+
+			//THEN
+			assert.NotNil(t, err, "The invalid pattern must be rejected")
+
+		})
+	}
+}
+
+func TestReadFileSkipsCommentsAndReportsTheLine(t *testing.T) {
+
+	//GIVEN
+	dir := t.TempDir()
+	valid := filepath.Join(dir, "valid")
+	invalid := filepath.Join(dir, "invalid")
+	ioutil.WriteFile(valid, []byte("# phone caches\n\n.thumbnails\n\\#1\n"), 0644)
+	ioutil.WriteFile(invalid, []byte("# phone caches\n[a-\n"), 0644)
+
+	//WHEN
+	matcher, err := ignore.ReadFile(valid)
+	missing, missingErr := ignore.ReadFile(filepath.Join(dir, "missing"))
+	_, invalidErr := ignore.ReadFile(invalid)
+
+	//THEN
+	assert.Nil(t, err, "No error must be thrown")
+	assert.True(t, matcher.Excluded("DCIM/.thumbnails", true))
+	assert.True(t, matcher.Excluded("#1", false), "An escaped hash must be a pattern")
+	assert.False(t, matcher.Excluded("phone caches", true), "Comments must not be patterns")
+	assert.Nil(t, missingErr, "A missing file must have no rules")
+	assert.False(t, missing.Excluded("DCIM", true))
+	assert.Contains(t, invalidErr.Error(), "invalid:2")
+
+}
+
+func TestLiteralMatchesThePathExactly(t *testing.T) {
+
+	//GIVEN
+	matcher, err := ignore.Compile([]string{ignore.Literal("Quarantine [old]/*")})
+	assert.Nil(t, err, "No error must be thrown")
+
+	//WHEN
+	excluded := matcher.Excluded("Quarantine [old]/*", true)
+	pattern := matcher.Excluded("Quarantine o/IMG_1.jpg", false)
+	nested := matcher.Excluded("DCIM/Quarantine [old]/*", true)
+
+	//THEN
+	assert.True(t, excluded)
+	assert.False(t, pattern, "The special characters must be matched literally")
+	assert.False(t, nested, "The path must be anchored to the root")
+
+}
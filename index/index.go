@@ -0,0 +1,262 @@
+// Package index keeps track of all files imported into a target library. The index is a JSON-lines file in the
+// target root, every imported file appends one line so that an interrupted run keeps what it has done.
+package index
+
+import (
+	"bytes"
+	"copy-images/model"
+	"copy-images/utils"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// Dir is the dir within the target holding the metadata of copy-images
+const Dir = ".copy-images"
+
+// FileName is the name of the index file within Dir
+const FileName = "index.jsonl"
+
+// Entry describes a single imported file
+type Entry struct {
+	// Source is the absolute path the file has been imported from, it is empty for entries found by Rebuild
+	Source string `json:"source,omitempty"`
+	// Profile is the config profile of the import, it is empty if no profile was used
+	Profile string `json:"profile,omitempty"`
+	// Hash is the hex sha-256 of the content
+	Hash string `json:"hash"`
+	Size int64  `json:"size"`
+	// CaptureDate is the creation date the destination has been chosen by
+	CaptureDate time.Time `json:"capture_date"`
+	// Destination is the slash separated path of the file relative to the target
+	Destination string    `json:"destination"`
+	ImportedAt  time.Time `json:"imported_at"`
+}
+
+// Index holds all entries of the index file of a target. It is safe for concurrent use.
+type Index struct {
+	// Profile is recorded in all entries added to the index
+	Profile   string
+	targetDir string
+	mu        sync.Mutex
+	entries   []Entry
+	bySource  map[string]int
+	// byHash only holds the entries without source
+	byHash        map[string]int
+	byDestination map[string]int
+}
+
+// Path returns the path of the index file of the target
+func Path(targetDir string) string {
+	return filepath.Join(targetDir, Dir, FileName)
+}
+
+// Load reads the index of the target. A target without an index file results in an empty index.
+func Load(targetDir string) (*Index, error) {
+	idx, err := newIndex(targetDir)
+	if err != nil {
+		return nil, err
+	}
+	// a last line cut by a crash while it was recorded is ignored, the duplicate check still finds the copy it describes
+	err = utils.ReadLines(Path(targetDir), func(line []byte) error {
+		var entry Entry
+		if err := json.Unmarshal(line, &entry); err != nil {
+			return err
+		}
+		idx.insert(entry)
+		return nil
+	})
+	if os.IsNotExist(err) {
+		return idx, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return idx, nil
+}
+
+// newIndex creates an empty index of the target
+func newIndex(targetDir string) (*Index, error) {
+	absoluteDir, err := filepath.Abs(targetDir)
+	if err != nil {
+		return nil, err
+	}
+	return &Index{targetDir: absoluteDir, bySource: make(map[string]int), byHash: make(map[string]int), byDestination: make(map[string]int)}, nil
+}
+
+// insert adds the entry to the in-memory index, a later entry of the same source replaces the earlier one
+func (idx *Index) insert(entry Entry) {
+	position, ok := idx.bySource[entry.Source]
+	if ok && entry.Source != "" {
+		idx.entries[position] = entry
+	} else {
+		position = len(idx.entries)
+		if entry.Source != "" {
+			idx.bySource[entry.Source] = position
+		}
+		idx.entries = append(idx.entries, entry)
+	}
+	if entry.Source == "" && entry.Hash != "" {
+		idx.byHash[entry.Hash] = position
+	}
+	idx.byDestination[entry.Destination] = position
+}
+
+// Entries returns all entries of the index
+func (idx *Index) Entries() []Entry {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	return append([]Entry(nil), idx.entries...)
+}
+
+// Imported returns the entry of a file which has been imported from the same source path with the same size before
+// and whose destination still exists in the target. Entries without source, like the ones found by Rebuild, are only
+// found by the content of a file which has been hashed already.
+func (idx *Index) Imported(f model.FileInfo) (Entry, bool) {
+	source, err := filepath.Abs(f.Path)
+	if err != nil {
+		return Entry{}, false
+	}
+	idx.mu.Lock()
+	position, ok := idx.bySource[source]
+	if !ok && f.Hash != "" {
+		position, ok = idx.byHash[f.Hash]
+	}
+	var entry Entry
+	if ok {
+		entry = idx.entries[position]
+	}
+	idx.mu.Unlock()
+	if !ok {
+		return Entry{}, false
+	}
+	size := f.Size
+	if size == 0 {
+		if info, err := os.Stat(f.Path); err == nil {
+			size = info.Size()
+		}
+	}
+	if entry.Size != size {
+		return Entry{}, false
+	}
+	if _, err := os.Stat(filepath.Join(idx.targetDir, filepath.FromSlash(entry.Destination))); err != nil {
+		return Entry{}, false
+	}
+	return entry, true
+}
+
+// Destination returns the last entry recorded for the slash separated path relative to the target, the file at the
+// path may have been changed since
+func (idx *Index) Destination(relative string) (Entry, bool) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	position, ok := idx.byDestination[relative]
+	if !ok || idx.entries[position].Destination != relative {
+		return Entry{}, false
+	}
+	return idx.entries[position], true
+}
+
+// Record appends an entry for a file copied by the operation to the index file
+func (idx *Index) Record(fileOp model.FileOperation, hash string, size int64) error {
+	entry := Entry{
+		Source:      fileOp.From,
+		Profile:     idx.Profile,
+		Hash:        hash,
+		Size:        size,
+		CaptureDate: fileOp.Date,
+		Destination: idx.relative(fileOp.To),
+		ImportedAt:  time.Now(),
+	}
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	if err := os.MkdirAll(filepath.Dir(Path(idx.targetDir)), os.ModePerm); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(Path(idx.targetDir), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	// the line is synced to disk so that a crash can at most cut the last line, which Load ignores
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	idx.insert(entry)
+	return nil
+}
+
+// Rebuild regenerates the index of the target from the given files found in it. Source, profile and date of entries
+// whose destination still holds the same content are kept, everything else only knows the content and the date.
+func Rebuild(targetDir string, files []model.FileInfo) (*Index, error) {
+	idx, err := newIndex(targetDir)
+	if err != nil {
+		return nil, err
+	}
+	known := make(map[string]Entry)
+	// a corrupt index is exactly what rebuild is for, in that case nothing is kept
+	if previous, err := Load(targetDir); err == nil {
+		for _, entry := range previous.entries {
+			known[entry.Destination] = entry
+		}
+	}
+
+	var content bytes.Buffer
+	for _, f := range files {
+		hash, err := utils.HashFile(f.Path)
+		if err != nil {
+			return nil, err
+		}
+		info, err := os.Stat(f.Path)
+		if err != nil {
+			return nil, err
+		}
+		entry := Entry{Hash: hash, Size: info.Size(), CaptureDate: f.CreationDate, Destination: idx.relative(f.Path), ImportedAt: info.ModTime()}
+		if old, ok := known[entry.Destination]; ok && old.Hash == hash {
+			entry.Source, entry.Profile, entry.CaptureDate, entry.ImportedAt = old.Source, old.Profile, old.CaptureDate, old.ImportedAt
+		}
+		line, err := json.Marshal(entry)
+		if err != nil {
+			return nil, err
+		}
+		content.Write(append(line, '\n'))
+		idx.insert(entry)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(Path(targetDir)), os.ModePerm); err != nil {
+		return nil, err
+	}
+	// the old index is only replaced once the new one is complete
+	temp := Path(targetDir) + ".tmp"
+	if err := ioutil.WriteFile(temp, content.Bytes(), 0644); err != nil {
+		return nil, err
+	}
+	return idx, os.Rename(temp, Path(targetDir))
+}
+
+// relative returns the slash separated path of a file within the target
+func (idx *Index) relative(path string) string {
+	absolutePath, err := filepath.Abs(path)
+	if err != nil {
+		return filepath.ToSlash(path)
+	}
+	relative, err := filepath.Rel(idx.targetDir, absolutePath)
+	if err != nil {
+		return filepath.ToSlash(path)
+	}
+	return filepath.ToSlash(relative)
+}
@@ -0,0 +1,176 @@
+package index_test
+
+import (
+	"copy-images/index"
+	"copy-images/model"
+	"copy-images/utils"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoadWithoutIndexFileIsEmpty(t *testing.T) {
+
+	//GIVEN
+	targetDir := t.TempDir()
+
+	//WHEN
+	idx, err := index.Load(targetDir)
+
+	//THEN
+	assert.Nil(t, err, "No error must be thrown")
+	assert.Empty(t, idx.Entries())
+
+}
+
+func TestRecordedFilesAreImportedAfterLoad(t *testing.T) {
+
+	//GIVEN
+	sourceDir := t.TempDir()
+	targetDir := t.TempDir()
+	source := writeFile(t, filepath.Join(sourceDir, "IMG_1.jpg"), "holiday")
+	destination := writeFile(t, filepath.Join(targetDir, "2021", "March", "IMG_1.jpg"), "holiday")
+	march, _ := time.Parse("2006-01-02", "2021-03-03")
+	idx, _ := index.Load(targetDir)
+	idx.Profile = "pixel6"
+	fileOp := model.FileOperation{From: source, To: destination, OpType: model.CopyOp, Date: march}
+
+	//WHEN
+	err := idx.Record(fileOp, "abc", 7)
+	reloaded, loadErr := index.Load(targetDir)
+
+	//THEN
+	assert.Nil(t, err, "No error must be thrown")
+	assert.Nil(t, loadErr, "No error must be thrown")
+	entry, ok := reloaded.Imported(model.FileInfo{Path: source})
+	assert.True(t, ok, "The recorded file must be known as imported")
+	assert.Equal(t, "2021/March/IMG_1.jpg", entry.Destination)
+	assert.Equal(t, "pixel6", entry.Profile)
+	assert.Equal(t, "abc", entry.Hash)
+	assert.True(t, march.Equal(entry.CaptureDate))
+	recorded, ok := reloaded.Destination("2021/March/IMG_1.jpg")
+	assert.True(t, ok, "The entry must be found by its destination")
+	assert.Equal(t, source, recorded.Source)
+
+}
+
+func TestChangedOrRemovedFilesAreNotImported(t *testing.T) {
+
+	//GIVEN
+	sourceDir := t.TempDir()
+	targetDir := t.TempDir()
+	changed := writeFile(t, filepath.Join(sourceDir, "IMG_1.jpg"), "holiday")
+	removed := writeFile(t, filepath.Join(sourceDir, "IMG_2.jpg"), "beach")
+	idx, _ := index.Load(targetDir)
+	idx.Record(model.FileOperation{From: changed, To: writeFile(t, filepath.Join(targetDir, "IMG_1.jpg"), "holiday")}, "abc", 7)
+	idx.Record(model.FileOperation{From: removed, To: filepath.Join(targetDir, "IMG_2.jpg")}, "def", 5)
+
+	//WHEN
+	writeFile(t, changed, "holiday edited")
+	_, changedImported := idx.Imported(model.FileInfo{Path: changed})
+	_, removedImported := idx.Imported(model.FileInfo{Path: removed})
+
+	//THEN
+	assert.False(t, changedImported, "A source with a different size must be imported again")
+	assert.False(t, removedImported, "A file whose destination is gone must be imported again")
+
+}
+
+func TestLoadReportsTheCorruptLine(t *testing.T) {
+
+	//GIVEN
+	targetDir := t.TempDir()
+	writeFile(t, index.Path(targetDir), "{\"hash\":\"abc\"}\nnot json\n")
+
+	//WHEN
+	_, err := index.Load(targetDir)
+
+	//THEN
+	assert.NotNil(t, err, "The corrupt index must be reported")
+	assert.Contains(t, err.Error(), "index.jsonl:2")
+
+}
+
+func TestLoadIgnoresALastLineCutByACrash(t *testing.T) {
+
+	//GIVEN
+	targetDir := t.TempDir()
+	writeFile(t, index.Path(targetDir), "{\"hash\":\"abc\",\"destination\":\"IMG_1.jpg\"}\n{\"hash\":\"de")
+
+	//WHEN
+	idx, err := index.Load(targetDir)
+
+	//THEN
+	assert.Nil(t, err, "A line cut by a crash must not fail later runs")
+	assert.Equal(t, 1, len(idx.Entries()))
+	assert.Equal(t, "abc", idx.Entries()[0].Hash)
+
+}
+
+func TestRebuildIndexesLibraryAndKeepsKnownSources(t *testing.T) {
+
+	//GIVEN
+	targetDir := t.TempDir()
+	edited := writeFile(t, filepath.Join(targetDir, "2021", "March", "IMG_1.jpg"), "holiday")
+	unchanged := writeFile(t, filepath.Join(targetDir, "2021", "April", "IMG_2.jpg"), "beach")
+	unchangedHash, _ := utils.HashFile(unchanged)
+	april, _ := time.Parse("2006-01-02", "2021-04-04")
+	idx, _ := index.Load(targetDir)
+	idx.Record(model.FileOperation{From: "/media/phone/IMG_1.jpg", To: edited}, "hash of the content before editing", 7)
+	idx.Record(model.FileOperation{From: "/media/phone/IMG_2.jpg", To: unchanged, Date: april}, unchangedHash, 5)
+
+	//WHEN
+	rebuilt, err := index.Rebuild(targetDir, []model.FileInfo{{Path: edited}, {Path: unchanged}})
+	reloaded, loadErr := index.Load(targetDir)
+
+	//THEN
+	assert.Nil(t, err, "No error must be thrown")
+	assert.Nil(t, loadErr, "The rebuilt index must be readable")
+	assert.Equal(t, 2, len(rebuilt.Entries()))
+	assert.Equal(t, "2021/March/IMG_1.jpg", reloaded.Entries()[0].Destination)
+	assert.Equal(t, "", reloaded.Entries()[0].Source, "A source whose content does not match must not be kept")
+	assert.Equal(t, "2021/April/IMG_2.jpg", reloaded.Entries()[1].Destination)
+	assert.Equal(t, "/media/phone/IMG_2.jpg", reloaded.Entries()[1].Source)
+	assert.Equal(t, int64(5), reloaded.Entries()[1].Size)
+	assert.True(t, april.Equal(reloaded.Entries()[1].CaptureDate))
+
+}
+
+func TestFilesOfARebuiltIndexAreImportedByTheirContent(t *testing.T) {
+
+	//GIVEN
+	sourceDir := t.TempDir()
+	targetDir := t.TempDir()
+	writeFile(t, filepath.Join(targetDir, "2021", "March", "IMG_1.jpg"), "holiday")
+	source := writeFile(t, filepath.Join(sourceDir, "IMG_1.jpg"), "holiday")
+	other := writeFile(t, filepath.Join(sourceDir, "IMG_2.jpg"), "beach")
+	hash, _ := utils.HashFile(source)
+	otherHash, _ := utils.HashFile(other)
+	idx, _ := index.Rebuild(targetDir, []model.FileInfo{{Path: filepath.Join(targetDir, "2021", "March", "IMG_1.jpg")}})
+
+	//WHEN
+	entry, hashedImported := idx.Imported(model.FileInfo{Path: source, Hash: hash})
+	_, unhashedImported := idx.Imported(model.FileInfo{Path: source})
+	_, otherImported := idx.Imported(model.FileInfo{Path: other, Hash: otherHash})
+
+	//THEN
+	assert.True(t, hashedImported, "A rebuilt entry knows no source, it must be found by the content")
+	assert.Equal(t, "2021/March/IMG_1.jpg", entry.Destination)
+	assert.False(t, unhashedImported, "A file which has not been hashed cannot be found by its content")
+	assert.False(t, otherImported)
+
+}
+
+func writeFile(t *testing.T, filePath string, content string) string {
+	if err := os.MkdirAll(filepath.Dir(filePath), os.ModePerm); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filePath, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return filePath
+}
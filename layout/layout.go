@@ -0,0 +1,206 @@
+// Package layout renders the destination path of a file inside the target from a template like
+// "{year}/{month:02}-{monthname}/{day}/{basename}{ext}"
+package layout
+
+import (
+	"copy-images/model"
+	"copy-images/utils"
+	"fmt"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Default is the layout used if none is configured, it sorts the files into <year>/<month name> dirs
+const Default = "{year}/{monthname}/{basename}{ext}"
+
+// UnknownValue is rendered for placeholders whose value is not known, e.g. the camera of a file without exif
+const UnknownValue = "Unknown"
+
+// placeholder renders a single value of a file. Numeric placeholders are zero padded to the width given in the
+// format, string placeholders are truncated to it.
+type placeholder struct {
+	numeric bool
+	value   func(f model.FileInfo) (string, error)
+}
+
+// placeholders contains all placeholders which can be used in a template
+var placeholders = map[string]placeholder{
+	"year":       numeric(func(f model.FileInfo) int { return f.CreationDate.Year() }),
+	"month":      numeric(func(f model.FileInfo) int { return int(f.CreationDate.Month()) }),
+	"monthname":  text(func(f model.FileInfo) string { return f.CreationDate.Month().String() }),
+	"day":        numeric(func(f model.FileInfo) int { return f.CreationDate.Day() }),
+	"hour":       numeric(func(f model.FileInfo) int { return f.CreationDate.Hour() }),
+	"minute":     numeric(func(f model.FileInfo) int { return f.CreationDate.Minute() }),
+	"second":     numeric(func(f model.FileInfo) int { return f.CreationDate.Second() }),
+	"basename":   text(basename),
+	"ext":        text(func(f model.FileInfo) string { return filepath.Ext(f.Path) }),
+	"parent":     text(func(f model.FileInfo) string { return filepath.Base(filepath.Dir(f.Path)) }),
+	"camera":     text(func(f model.FileInfo) string { return orUnknown(f.CameraModel) }),
+	"datesource": text(func(f model.FileInfo) string { return orUnknown(string(f.DateSource)) }),
+	"mediatype":  text(func(f model.FileInfo) string { return orUnknown(string(f.MediaType)) }),
+	"hash":       {value: utils.ContentHash},
+}
+
+// numeric creates a placeholder rendering a number
+func numeric(value func(f model.FileInfo) int) placeholder {
+	return placeholder{numeric: true, value: func(f model.FileInfo) (string, error) { return strconv.Itoa(value(f)), nil }}
+}
+
+// text creates a placeholder rendering a string
+func text(value func(f model.FileInfo) string) placeholder {
+	return placeholder{value: func(f model.FileInfo) (string, error) { return value(f), nil }}
+}
+
+// part is either a literal or a placeholder of a template
+type part struct {
+	literal string
+	name    string
+	width   int
+}
+
+// Template is a parsed layout
+type Template struct {
+	raw   string
+	parts []part
+}
+
+// Names returns the sorted names of all placeholders
+func Names() []string {
+	names := make([]string, 0, len(placeholders))
+	for name := range placeholders {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Parse parses a layout. Placeholders are written as {name} or {name:width}, literal braces as {{ and }}.
+func Parse(layout string) (*Template, error) {
+	t := &Template{raw: layout}
+	var literal strings.Builder
+	for i := 0; i < len(layout); i++ {
+		c := layout[i]
+		switch {
+		case c == '{' && i+1 < len(layout) && layout[i+1] == '{', c == '}' && i+1 < len(layout) && layout[i+1] == '}':
+			literal.WriteByte(c)
+			i++
+		case c == '}':
+			return nil, fmt.Errorf("layout %q: unexpected } at position %d", layout, i)
+		case c == '{':
+			end := strings.IndexByte(layout[i:], '}')
+			if end < 0 {
+				return nil, fmt.Errorf("layout %q: unclosed { at position %d", layout, i)
+			}
+			p, err := parsePlaceholder(layout[i+1 : i+end])
+			if err != nil {
+				return nil, fmt.Errorf("layout %q: %w", layout, err)
+			}
+			if literal.Len() > 0 {
+				t.parts = append(t.parts, part{literal: literal.String()})
+				literal.Reset()
+			}
+			t.parts = append(t.parts, p)
+			i += end
+		default:
+			literal.WriteByte(c)
+		}
+	}
+	if literal.Len() > 0 {
+		t.parts = append(t.parts, part{literal: literal.String()})
+	}
+	if len(t.parts) == 0 {
+		return nil, fmt.Errorf("layout must not be empty")
+	}
+	if path.IsAbs(layout) || filepath.IsAbs(layout) {
+		return nil, fmt.Errorf("layout %q must be relative to the target", layout)
+	}
+	return t, nil
+}
+
+// MustParse is like Parse but panics if the layout is invalid
+func MustParse(layout string) *Template {
+	t, err := Parse(layout)
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+// parsePlaceholder parses the content between the braces of a placeholder
+func parsePlaceholder(content string) (part, error) {
+	name := content
+	p := part{}
+	if colon := strings.IndexByte(content, ':'); colon >= 0 {
+		name = content[:colon]
+		width, err := strconv.Atoi(content[colon+1:])
+		if err != nil || width <= 0 {
+			return p, fmt.Errorf("invalid width %q of placeholder {%s}", content[colon+1:], name)
+		}
+		p.width = width
+	}
+	if _, ok := placeholders[name]; !ok {
+		return p, fmt.Errorf("unknown placeholder {%s}, known placeholders: %s", name, strings.Join(Names(), ", "))
+	}
+	p.name = name
+	return p, nil
+}
+
+// String returns the layout the template has been parsed from
+func (t *Template) String() string {
+	return t.raw
+}
+
+// Uses checks if the template contains the named placeholder
+func (t *Template) Uses(name string) bool {
+	for _, p := range t.parts {
+		if p.name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Execute renders the slash separated destination path of the file relative to the target.
+// Path separators within placeholder values are replaced so that every value stays within its path element.
+func (t *Template) Execute(f model.FileInfo) (string, error) {
+	var rendered strings.Builder
+	for _, p := range t.parts {
+		if p.name == "" {
+			rendered.WriteString(p.literal)
+			continue
+		}
+		ph := placeholders[p.name]
+		value, err := ph.value(f)
+		if err != nil {
+			return "", err
+		}
+		switch {
+		case p.width > 0 && ph.numeric && len(value) < p.width:
+			value = strings.Repeat("0", p.width-len(value)) + value
+		case p.width > 0 && !ph.numeric && len(value) > p.width:
+			value = value[:p.width]
+		}
+		rendered.WriteString(strings.NewReplacer("/", "_", "\\", "_").Replace(value))
+	}
+	result := path.Clean(rendered.String())
+	if result == "." || result == ".." || strings.HasPrefix(result, "../") || path.IsAbs(result) {
+		return "", fmt.Errorf("layout %q renders %q for %s which is not within the target", t.raw, result, f.Path)
+	}
+	return result, nil
+}
+
+// basename returns the file name without its extension
+func basename(f model.FileInfo) string {
+	return strings.TrimSuffix(filepath.Base(f.Path), filepath.Ext(f.Path))
+}
+
+// orUnknown returns the value or UnknownValue if it is empty
+func orUnknown(value string) string {
+	if value == "" {
+		return UnknownValue
+	}
+	return value
+}
@@ -0,0 +1,104 @@
+package layout_test
+
+import (
+	"copy-images/layout"
+	"copy-images/model"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var testFile = model.FileInfo{
+	Path:         "/media/phone/DCIM/Camera/IMG_1.jpg",
+	CreationDate: time.Date(2021, time.March, 3, 14, 5, 9, 0, time.Local),
+	DateSource:   model.ExifSource,
+	MediaType:    model.PhotoMedia,
+	CameraModel:  "Google Pixel 6",
+	Hash:         "ba7816bf8f01cfea414140de5dae2223b00361a396177a9cb410ff61f20015ad",
+}
+
+func TestThatDefaultLayoutSortsIntoYearAndMonthName(t *testing.T) {
+
+	//GIVEN
+	var template = layout.MustParse(layout.Default)
+
+	//WHEN
+	result, err := template.Execute(testFile)
+
+	//THEN
+	assert.Nil(t, err, "No error must be thrown")
+	assert.Equal(t, "2021/March/IMG_1.jpg", result)
+
+}
+
+func TestThatAllPlaceholdersAreRendered(t *testing.T) {
+
+	//GIVEN
+	var layouts = map[string]string{
+		"{year}/{month:02}-{monthname}/{day}/{basename}{ext}":           "2021/03-March/3/IMG_1.jpg",
+		"{year}{month:02}{day:02}_{hour:02}{minute:02}{second:02}{ext}": "20210303_140509.jpg",
+		"{camera}/{datesource}/{mediatype}/{parent}/{basename}{ext}":    "Google Pixel 6/exif/photo/Camera/IMG_1.jpg",
+		"{year}/{hash:8}{ext}":       "2021/ba7816bf.jpg",
+		"{{{year}}}/{basename}{ext}": "{2021}/IMG_1.jpg",
+	}
+
+	for layoutString, expected := range layouts {
+		//WHEN
+		template, err := layout.Parse(layoutString)
+		assert.Nil(t, err, "Layout %s must be valid", layoutString)
+		result, err := template.Execute(testFile)
+
+		//THEN
+		assert.Nil(t, err, "No error must be thrown")
+		assert.Equal(t, expected, result)
+	}
+
+}
+
+func TestThatUnknownValuesAndSeparatorsAreReplaced(t *testing.T) {
+
+	//GIVEN
+	var template = layout.MustParse("{camera}/{basename}{ext}")
+	var withoutCamera = testFile
+	withoutCamera.CameraModel = ""
+	var withSlash = testFile
+	withSlash.CameraModel = "EOS 5D/II"
+
+	//WHEN
+	unknown, _ := template.Execute(withoutCamera)
+	slash, _ := template.Execute(withSlash)
+
+	//THEN
+	assert.Equal(t, "Unknown/IMG_1.jpg", unknown)
+	assert.Equal(t, "EOS 5D_II/IMG_1.jpg", slash, "Values must stay within their path element")
+
+}
+
+func TestThatInvalidLayoutsAreRejected(t *testing.T) {
+
+	//GIVEN
+	var layouts = []string{"", "{year", "year}", "{yaer}/{basename}{ext}", "{month:x}", "{month:0}", "/{year}/{basename}{ext}"}
+
+	for _, layoutString := range layouts {
+		//WHEN
+		_, err := layout.Parse(layoutString)
+
+		//THEN
+		assert.NotNil(t, err, "Layout %q must be rejected", layoutString)
+	}
+
+}
+
+func TestThatLayoutsLeavingTheTargetAreRejected(t *testing.T) {
+
+	//GIVEN
+	var template = layout.MustParse("../{basename}{ext}")
+
+	//WHEN
+	_, err := template.Execute(testFile)
+
+	//THEN
+	assert.NotNil(t, err, "A destination outside of the target must be rejected")
+
+}
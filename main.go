@@ -1,75 +1,111 @@
 package main
 
 import (
-	"copy-images/file"
-	"copy-images/model"
-	"copy-images/utils"
+	"errors"
+	"flag"
 	"fmt"
+	"io"
 	"os"
-	"time"
 )
 
 //TODO:
-// - use cli parser
-// - proper error handling
 // - logging
 
 func main() {
+	os.Exit(run(os.Args[1:], os.Stdout, os.Stderr))
+}
 
-	argsWithoutProg := os.Args[1:]
-	mode := argsWithoutProg[0]
-	source := argsWithoutProg[1]
-	target := argsWithoutProg[2]
-
-	var images []model.FileInfo
-	var supportedFileEndings []string = []string{".png", ".jpeg", ".jpg", ".gif"}
-	var excludedDirs []string = []string{"Android/Data", ".thumbnails", "WhatsApp/.Shared", "WhatsApp/Media/.Statuses", "WhatsApp/.Thumbs"}
-
-	var collectFilesConfig file.CollectFilesConfig = file.CollectFilesConfig{ExcludedDirs: excludedDirs, SupportedExtensions: supportedFileEndings}
-
-	err := file.CollectFiles(source, &images, collectFilesConfig)
-	if err != nil {
-		panic(err)
+// run dispatches the given arguments to the matching subcommand and returns the exit code of the program
+func run(args []string, stdout io.Writer, stderr io.Writer) int {
+	if len(args) == 0 {
+		printUsage(stderr)
+		return 2
 	}
-	for _, file := range images {
-		fmt.Println(file)
+	name := args[0]
+	if name == "help" || name == "-h" || name == "-help" || name == "--help" {
+		if cmd, _ := resolveCommand(args[1:]); cmd != nil {
+			cmd.flagSet(&options{}, stdout).Usage()
+			return 0
+		}
+		printUsage(stdout)
+		return 0
 	}
 
-	var cutoffDate time.Time = utils.RemoveMonths(time.Now(), 2)
+	cmd, rest := resolveCommand(args)
+	if cmd == nil {
+		fmt.Fprintf(stderr, "copy-images: unknown command %q\n\n", name)
+		printUsage(stderr)
+		return 2
+	}
 
-	//TODO: use cli parser
-	if mode == "--prepare" {
-		fmt.Println("Writing file op description", len(images))
-		currentTime := time.Now()
-		err = file.PrepareCopy(target, images, "copy_desc_"+currentTime.Format("2006-01-02-15:04:05")+".json", cutoffDate)
-		if err != nil {
-			panic(err)
+	opts := &options{}
+	fs := cmd.flagSet(opts, stderr)
+	if len(cmd.subcommands) > 0 {
+		if len(rest) == 0 {
+			fmt.Fprintf(stderr, "copy-images %s: missing command\n\n", cmd.name)
+			fs.Usage()
+			return 2
+		}
+		if rest[0] != "-h" && rest[0] != "-help" && rest[0] != "--help" {
+			fmt.Fprintf(stderr, "copy-images %s: unknown command %q\n\n", cmd.name, rest[0])
+			fs.Usage()
+			return 2
 		}
+		fs.SetOutput(stdout)
+		fs.Usage()
+		return 0
 	}
-	fmt.Println("Number of files to copy:", len(images))
-
-	if mode == "--copy" {
-		//copy the files to the target
-		err = file.CopyFilesTo(target, images)
-
-		if err != nil {
-			panic(err)
+	if err := fs.Parse(rest); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return 0
 		}
-
-		fmt.Println("Copied all files:", len(images))
+		// the flag package already printed the error and the usage
+		return 2
 	}
 
-	if mode == "--copyDelete" {
-		//copy the files to the target
-		err = file.CopyFilesTo(target, images)
-
-		if err != nil {
-			panic(err)
+	err := opts.applyProfile(fs)
+	if err == nil {
+		err = cmd.run(opts, fs.Args(), stdout)
+	}
+	if err != nil {
+		var usageErr *usageError
+		if errors.As(err, &usageErr) {
+			fmt.Fprintf(stderr, "copy-images %s: %s\n\n", cmd.fullName(), usageErr.msg)
+			fs.Usage()
+			return 2
 		}
+		fmt.Fprintf(stderr, "copy-images %s: %s\n", cmd.fullName(), err)
+		return 1
+	}
+	return 0
+}
 
-		fmt.Println("Copied all files:", len(images))
-		var deletedFiles []model.FileInfo = file.DeleteFilesCreatedBefore(cutoffDate, images)
-		fmt.Println("Deleted files:", len(deletedFiles))
+// resolveCommand looks up the (sub)command named by the leading arguments and returns it with the remaining arguments.
+// If a group is named without a known subcommand the group itself is returned.
+func resolveCommand(args []string) (*command, []string) {
+	if len(args) == 0 {
+		return nil, args
+	}
+	cmd := findCommand(commands, args[0])
+	rest := args[1:]
+	for cmd != nil && len(cmd.subcommands) > 0 && len(rest) > 0 {
+		sub := findCommand(cmd.subcommands, rest[0])
+		if sub == nil {
+			break
+		}
+		cmd, rest = sub, rest[1:]
 	}
+	return cmd, rest
+}
 
+// printUsage prints the overview of all available commands
+func printUsage(w io.Writer) {
+	fmt.Fprintln(w, "Usage: copy-images <command> [flags] [args]")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Commands:")
+	for _, cmd := range commands {
+		fmt.Fprintf(w, "  %-8s %s\n", cmd.name, cmd.summary)
+	}
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Run 'copy-images <command> --help' for the flags of a command.")
 }
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRunExitCodes(t *testing.T) {
+	source := t.TempDir()
+	tests := []struct {
+		name   string
+		args   []string
+		code   int
+		stdout string
+		stderr string
+	}{
+		{"no command prints the usage", []string{}, 2, "", "Usage: copy-images <command>"},
+		{"help prints the usage", []string{"--help"}, 0, "Usage: copy-images <command>", ""},
+		{"help of a command prints its flags", []string{"help", "scan"}, 0, "-source", ""},
+		{"the flags of a command are printed with --help", []string{"copy", "--help"}, 0, "", "-target"},
+		{"an unknown command is a usage error", []string{"sync"}, 2, "", `unknown command "sync"`},
+		{"an unknown subcommand is a usage error", []string{"index", "clear"}, 2, "", `unknown command "clear"`},
+		{"a group without subcommand is a usage error", []string{"index"}, 2, "", "copy-images index: missing command"},
+		{"the subcommands of a group are printed with --help", []string{"trash", "--help"}, 0, "restore", ""},
+		{"a missing source is a usage error", []string{"scan"}, 2, "", "missing required flag --source"},
+		{"a missing target is a usage error", []string{"plan", "--source", source}, 2, "", "missing required flag --target"},
+		{"an unknown flag is a usage error", []string{"scan", "--no-such-flag"}, 2, "", "flag provided but not defined: -no-such-flag"},
+		{"a bad flag value is a usage error", []string{"scan", "--scan-workers", "many"}, 2, "", "invalid value"},
+		{"an invalid flag combination is a usage error", []string{"copy", "--source", source, "--target", t.TempDir(), "--videos-dir", "../videos"}, 2, "", "must be relative to the target"},
+		{"a failing command exits with 1", []string{"undo", "--target", t.TempDir(), "20210101-120000-aaaaaa"}, 1, "", "run 20210101-120000-aaaaaa"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			//GIVEN
+			var stdout, stderr bytes.Buffer
+
+			//WHEN
+			code := run(tt.args, &stdout, &stderr)
+
+			//THEN
+			assert.Equal(t, tt.code, code, stderr.String())
+			assert.Contains(t, stdout.String(), tt.stdout)
+			assert.Contains(t, stderr.String(), tt.stderr)
+
+		})
+	}
+}
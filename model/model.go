@@ -6,21 +6,141 @@ import "time"
 type FileInfo struct {
 	Path         string
 	CreationDate time.Time
+	DateSource   DateSource
+	MediaType    MediaType
+	CameraModel  string
+	// MimeType is the type detected from the content, it is empty if the content has not been sniffed or is not known
+	MimeType string
+	// Size is the size of the content in bytes
+	Size int64
+	// Hash is the hex sha-256 of the content, it is empty until it has been computed
+	Hash string
+	// DateConflicts lists the dates of all other sources which disagree with the CreationDate
+	DateConflicts []DateConflict
+	// Sidecars are the paths of the metadata files describing the file, like IMG_1.xmp or IMG_1.jpg.json
+	Sidecars []string
+	// Location is where the photo has been taken, it is nil if it is not known
+	Location *Location
+}
+
+//Location is a position on earth in degrees with the altitude in meters
+type Location struct {
+	Latitude  float64 `json:"latitude"`
+	Longitude float64 `json:"longitude"`
+	Altitude  float64 `json:"altitude,omitempty"`
+}
+
+//DateSource names where the CreationDate of a FileInfo has been taken from
+type DateSource string
+
+const (
+	ExifSource      DateSource = "exif"
+	FilenameSource  DateSource = "filename"
+	SidecarSource   DateSource = "sidecar"
+	ContainerSource DateSource = "container"
+	ModTimeSource   DateSource = "mtime"
+)
+
+//MediaType distinguishes photos from videos
+type MediaType string
+
+const (
+	PhotoMedia MediaType = "photo"
+	VideoMedia MediaType = "video"
+)
+
+//DateConflict is a date of a source which lost against the source the CreationDate was taken from
+type DateConflict struct {
+	Source DateSource `json:"source"`
+	Date   time.Time  `json:"date"`
 }
 
 type FileOperations struct {
+	// Space is the estimate of the space the operations need in the target, it is nil if it has not been estimated
+	Space          *SpaceEstimate  `json:"space,omitempty"`
 	FileOperations []FileOperation `json:"operations,omitempty"`
 }
 
+//SpaceEstimate compares the bytes the operations write with the free space of the file systems of the target
+type SpaceEstimate struct {
+	// Needed is the size of all files copied or moved
+	Needed      int64             `json:"needed"`
+	FileSystems []FileSystemSpace `json:"file_systems,omitempty"`
+}
+
+//FileSystemSpace is the part of a SpaceEstimate on a single file system
+type FileSystemSpace struct {
+	// Dir is an existing dir on the file system the free space has been read from
+	Dir    string `json:"dir"`
+	Needed int64  `json:"needed"`
+	// Free is the space available on the file system, it is negative if it cannot be read
+	Free int64 `json:"free"`
+	// Reserve is the space which has to stay free
+	Reserve int64 `json:"reserve"`
+}
+
+//Sufficient checks if the operations leave the reserve free, a free space which cannot be read is assumed to suffice
+func (s FileSystemSpace) Sufficient() bool {
+	return s.Free < 0 || s.Needed <= s.Free-s.Reserve
+}
+
+//Sufficient checks if the operations fit onto all file systems of the target
+func (e SpaceEstimate) Sufficient() bool {
+	for _, fileSystem := range e.FileSystems {
+		if !fileSystem.Sufficient() {
+			return false
+		}
+	}
+	return true
+}
+
 type OpType string
 
 const (
 	MoveOp OpType = "MOVE"
 	CopyOp OpType = "COPY"
+	// SkipOp leaves the file alone, the Reason of the operation tells why
+	SkipOp OpType = "SKIP"
+	// DeleteOp removes a file whose copy already exists at To, like a file imported by an earlier run. The file is
+	// only removed if the copy has its content, nothing is copied.
+	DeleteOp OpType = "DELETE"
 )
 
 type FileOperation struct {
-	From   string `json:"from,omitempty"`
-	To     string `json:"to,omitempty"`
-	OpType OpType `json:"type,omitempty"`
+	From          string         `json:"from,omitempty"`
+	To            string         `json:"to,omitempty"`
+	OpType        OpType         `json:"type,omitempty"`
+	Date          time.Time      `json:"date"`
+	DateSource    DateSource     `json:"date_source,omitempty"`
+	DateConflicts []DateConflict `json:"date_conflicts,omitempty"`
+	Location      *Location      `json:"location,omitempty"`
+	Hash          string         `json:"hash,omitempty"`
+	Reason        string         `json:"reason,omitempty"`
+	// Retention explains why the source is deleted or kept, it is empty if no retention policy has been applied
+	Retention string `json:"retention,omitempty"`
+	// Companions are copied, moved and deleted together with the file, like the JPEG of a RAW+JPEG pair
+	Companions []Companion `json:"companions,omitempty"`
+}
+
+//Companion is a file which belongs to the file of a FileOperation and lands next to its destination
+type Companion struct {
+	From string `json:"from,omitempty"`
+	To   string `json:"to,omitempty"`
+	Hash string `json:"hash,omitempty"`
+	// Sidecar is set for the metadata files of the file or of another companion
+	Sidecar bool `json:"sidecar,omitempty"`
+}
+
+//Parts returns the operation of the file followed by an operation of the same type for every companion
+func (fileOp FileOperation) Parts() []FileOperation {
+	parts := make([]FileOperation, 0, 1+len(fileOp.Companions))
+	primary := fileOp
+	primary.Companions = nil
+	parts = append(parts, primary)
+	for _, companion := range fileOp.Companions {
+		part := primary
+		part.From, part.To, part.Hash = companion.From, companion.To, companion.Hash
+		parts = append(parts, part)
+	}
+	return parts
 }
@@ -0,0 +1,157 @@
+// Package mp4 is a minimal reader for the ISO base media file format used by MP4, MOV, 3GP and M4V files.
+// It only decodes the creation time of the movie and track headers.
+package mp4
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+)
+
+// ErrNoBoxes is returned if the file is not an ISO base media file
+var ErrNoBoxes = errors.New("mp4: no iso base media boxes found")
+
+// ErrNoDate is returned if neither the movie header nor a track header carry a creation time
+var ErrNoDate = errors.New("mp4: no creation time found")
+
+// epoch is the start of the mp4 time scale, 1904-01-01 UTC
+var epoch = time.Date(1904, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+// box is the header of a single box
+type box struct {
+	boxType string
+	// offset is the start of the payload
+	offset int64
+	// size is the size of the payload
+	size int64
+}
+
+// CreationTime returns the creation time of the media file at the given path
+func CreationTime(path string) (time.Time, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return time.Time{}, err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return time.Time{}, err
+	}
+	return Decode(f, info.Size())
+}
+
+// Decode returns the creation time of the movie header. If it is not set the first track header having a creation
+// time is used. The time is returned in the local time zone.
+func Decode(r io.ReaderAt, size int64) (time.Time, error) {
+	// a truncated file still yields the boxes in front of the damage
+	topLevel, _ := readBoxes(r, 0, size)
+	if len(topLevel) == 0 || !isKnownTopLevel(topLevel[0].boxType) {
+		return time.Time{}, ErrNoBoxes
+	}
+	moov, ok := find(topLevel, "moov")
+	if !ok {
+		return time.Time{}, ErrNoDate
+	}
+	children, err := readBoxes(r, moov.offset, moov.size)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if mvhd, ok := find(children, "mvhd"); ok {
+		if date, err := headerTime(r, mvhd); err == nil {
+			return date, nil
+		}
+	}
+	for _, trak := range children {
+		if trak.boxType != "trak" {
+			continue
+		}
+		trakChildren, err := readBoxes(r, trak.offset, trak.size)
+		if err != nil {
+			continue
+		}
+		if tkhd, ok := find(trakChildren, "tkhd"); ok {
+			if date, err := headerTime(r, tkhd); err == nil {
+				return date, nil
+			}
+		}
+	}
+	return time.Time{}, ErrNoDate
+}
+
+// isKnownTopLevel checks if the first box of a file is one an iso base media file starts with
+func isKnownTopLevel(boxType string) bool {
+	switch boxType {
+	case "ftyp", "moov", "mdat", "wide", "free", "skip", "pnot":
+		return true
+	}
+	return false
+}
+
+// readBoxes reads the headers of all boxes within the given range
+func readBoxes(r io.ReaderAt, offset int64, size int64) ([]box, error) {
+	var boxes []box
+	end := offset + size
+	header := make([]byte, 16)
+	for offset+8 <= end {
+		if _, err := r.ReadAt(header[:8], offset); err != nil {
+			return boxes, err
+		}
+		boxSize := int64(binary.BigEndian.Uint32(header))
+		headerSize := int64(8)
+		switch boxSize {
+		case 0:
+			// the box extends to the end of the enclosing range
+			boxSize = end - offset
+		case 1:
+			if _, err := r.ReadAt(header[8:16], offset+8); err != nil {
+				return boxes, err
+			}
+			boxSize = int64(binary.BigEndian.Uint64(header[8:16]))
+			headerSize = 16
+		}
+		if boxSize < headerSize || offset+boxSize > end {
+			return boxes, fmt.Errorf("mp4: invalid box size %d at offset %d", boxSize, offset)
+		}
+		boxes = append(boxes, box{boxType: string(header[4:8]), offset: offset + headerSize, size: boxSize - headerSize})
+		if len(boxes) > 1<<16 {
+			return boxes, fmt.Errorf("mp4: too many boxes")
+		}
+		offset += boxSize
+	}
+	return boxes, nil
+}
+
+// find returns the first box of the given type
+func find(boxes []box, boxType string) (box, bool) {
+	for _, b := range boxes {
+		if b.boxType == boxType {
+			return b, true
+		}
+	}
+	return box{}, false
+}
+
+// headerTime reads the creation_time of a mvhd or tkhd full box
+func headerTime(r io.ReaderAt, header box) (time.Time, error) {
+	payload := make([]byte, 12)
+	if header.size < int64(len(payload)) {
+		return time.Time{}, ErrNoDate
+	}
+	if _, err := r.ReadAt(payload, header.offset); err != nil {
+		return time.Time{}, err
+	}
+	var seconds uint64
+	if payload[0] == 1 {
+		seconds = binary.BigEndian.Uint64(payload[4:12])
+	} else {
+		seconds = uint64(binary.BigEndian.Uint32(payload[4:8]))
+	}
+	// zero means unknown, anything beyond the year 2176 is garbage
+	if seconds == 0 || seconds > 1<<33 {
+		return time.Time{}, ErrNoDate
+	}
+	return time.Unix(epoch.Unix()+int64(seconds), 0).Local(), nil
+}
@@ -0,0 +1,82 @@
+package mp4_test
+
+import (
+	"bytes"
+	"copy-images/mp4"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestThatMovieHeaderCreationTimeIsRead(t *testing.T) {
+
+	//GIVEN
+	var testFile string = "testdata/creation_time.mp4"
+
+	//WHEN
+	date, err := mp4.CreationTime(testFile)
+
+	//THEN
+	assert.Nil(t, err, "No error must be thrown")
+	assert.Equal(t, time.Date(2021, time.March, 3, 13, 15, 16, 0, time.UTC), date.UTC())
+	assert.Equal(t, time.Local, date.Location(), "The date must be returned in the local time zone")
+
+}
+
+func TestThatTrackHeaderIsUsedIfMovieHeaderHasNoTime(t *testing.T) {
+
+	//GIVEN
+	var testFile string = "testdata/track_header.mov"
+
+	//WHEN
+	date, err := mp4.CreationTime(testFile)
+
+	//THEN
+	assert.Nil(t, err, "No error must be thrown")
+	assert.Equal(t, time.Date(2019, time.July, 14, 16, 30, 5, 0, time.UTC), date.UTC())
+
+}
+
+func TestThatVersion1HeadersAndLargeBoxesAreRead(t *testing.T) {
+
+	//GIVEN
+	var testFile string = "testdata/version1.3gp"
+
+	//WHEN
+	date, err := mp4.CreationTime(testFile)
+
+	//THEN
+	assert.Nil(t, err, "No error must be thrown")
+	assert.Equal(t, time.Date(2020, time.February, 29, 12, 0, 0, 0, time.UTC), date.UTC())
+
+}
+
+func TestThatMissingCreationTimeIsReported(t *testing.T) {
+
+	//GIVEN
+	var testFile string = "testdata/no_date.m4v"
+
+	//WHEN
+	_, err := mp4.CreationTime(testFile)
+
+	//THEN
+	assert.Equal(t, mp4.ErrNoDate, err)
+
+}
+
+func TestThatOtherFilesAreNoMediaFiles(t *testing.T) {
+
+	//GIVEN
+	var emptyFile = []byte{}
+	var jpegFile = []byte{0xFF, 0xD8, 0xFF, 0xE0, 0x00, 0x10, 'J', 'F', 'I', 'F', 0, 0}
+
+	//WHEN
+	_, emptyErr := mp4.Decode(bytes.NewReader(emptyFile), int64(len(emptyFile)))
+	_, jpegErr := mp4.Decode(bytes.NewReader(jpegFile), int64(len(jpegFile)))
+
+	//THEN
+	assert.Equal(t, mp4.ErrNoBoxes, emptyErr)
+	assert.Equal(t, mp4.ErrNoBoxes, jpegErr)
+
+}
@@ -0,0 +1,462 @@
+package main
+
+import (
+	"copy-images/config"
+	"copy-images/dates"
+	"copy-images/file"
+	"copy-images/ignore"
+	"copy-images/index"
+	"copy-images/layout"
+	"copy-images/model"
+	"copy-images/retention"
+	"copy-images/runs"
+	"copy-images/trash"
+	"copy-images/utils"
+	"flag"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// defaultExtensions are the file extensions collected if no --extensions flag is given
+var defaultExtensions = append(append([]string{".png", ".jpeg", ".jpg", ".gif"}, file.VideoExtensions...), file.RawExtensions...)
+
+// defaultExcludedDirs are the exclusion rules used if no --exclude flag is given
+var defaultExcludedDirs = []string{"Android/Data", ".thumbnails", "WhatsApp/.Shared", "WhatsApp/Media/.Statuses", "WhatsApp/.Thumbs"}
+
+// defaultWorkers is the number of files copied concurrently if no --workers flag is given
+const defaultWorkers = 4
+
+// defaultCutoffMonths is the number of months kept on the source if no --cutoff-months flag is given
+const defaultCutoffMonths = 2
+
+// defaultReserveGB is the space in GB kept free on the target if no --reserve-gb flag is given
+const defaultReserveGB = float64(file.DefaultSpaceReserve) / 1000 / 1000 / 1000
+
+// defaultTrashRetentionDays is the number of days quarantined files are kept if no --trash-retention-days flag is given
+const defaultTrashRetentionDays = 30
+
+// options holds all flag values of a command invocation
+type options struct {
+	source         string
+	target         string
+	cutoffMonths   int
+	keepFavorites  bool
+	keepAlbums     listFlag
+	minFreeGB      float64
+	extensions     listFlag
+	excludedDirs   listFlag
+	dateSources    listFlag
+	videosDir      string
+	layout         string
+	planFile       string
+	configFile     string
+	profile        string
+	allowDelete    bool
+	skipDuplicates bool
+	useIndex       bool
+	copyMethod     string
+	bufferKiB      int
+	verifyCopies   bool
+	workers        int
+	scanWorkers    int
+	strict         bool
+	sniff          bool
+	fixExtensions  bool
+	takeout        bool
+	setModTime     bool
+	trashMode      string
+	quarantineDir  string
+	retentionDays  int
+	runID          string
+	reserveGB      float64
+	trimToSpace    bool
+}
+
+// addProfileFlags registers the flags selecting a profile of the config file
+func (o *options) addProfileFlags(fs *flag.FlagSet) {
+	fs.StringVar(&o.configFile, "config", config.DefaultPath(), "config `file` holding the profiles")
+	fs.StringVar(&o.profile, "profile", "", "`name` of the config profile to use, flags override its values")
+}
+
+// applyProfile loads the selected profile and fills in all values whose flags were not set explicitly
+func (o *options) applyProfile(fs *flag.FlagSet) error {
+	o.allowDelete = true
+	if o.profile == "" {
+		return nil
+	}
+	cfg, err := config.Load(o.configFile)
+	if err != nil {
+		return err
+	}
+	profile, err := cfg.Profile(o.profile)
+	if err != nil {
+		return err
+	}
+
+	setFlags := make(map[string]bool)
+	fs.Visit(func(f *flag.Flag) { setFlags[f.Name] = true })
+	fromProfile := func(name string) bool {
+		return fs.Lookup(name) != nil && !setFlags[name]
+	}
+
+	if fromProfile("source") && profile.Source != "" {
+		o.source = profile.Source
+	}
+	if fromProfile("target") && profile.Target != "" {
+		o.target = profile.Target
+	}
+	if fromProfile("layout") && profile.Layout != "" {
+		o.layout = profile.Layout
+	}
+	if fromProfile("videos-dir") && profile.VideosDir != "" {
+		o.videosDir = profile.VideosDir
+	}
+	if fromProfile("extensions") && profile.SupportedExtensions != nil {
+		o.extensions.values = profile.SupportedExtensions
+	}
+	if fromProfile("exclude") && profile.ExcludedDirs != nil {
+		o.excludedDirs.values = profile.ExcludedDirs
+	}
+	if fromProfile("date-sources") && profile.DateSources != nil {
+		// marked as set so that the order of the profile is not replaced by the one of --takeout
+		o.dateSources.values, o.dateSources.set = profile.DateSources, true
+	}
+	if fromProfile("cutoff-months") && profile.CutoffMonths != nil {
+		o.cutoffMonths = *profile.CutoffMonths
+	}
+	if fromProfile("keep-favorites") && profile.KeepFavorites != nil {
+		o.keepFavorites = *profile.KeepFavorites
+	}
+	if fromProfile("keep-albums") && profile.KeepAlbums != nil {
+		o.keepAlbums.values = profile.KeepAlbums
+	}
+	if fromProfile("min-free-gb") && profile.MinFreeGB != nil {
+		o.minFreeGB = *profile.MinFreeGB
+	}
+	if fromProfile("skip-duplicates") && profile.SkipDuplicates != nil {
+		o.skipDuplicates = *profile.SkipDuplicates
+	}
+	if fromProfile("sniff") && profile.Sniff != nil {
+		o.sniff = *profile.Sniff
+	}
+	if fromProfile("fix-extensions") && profile.FixExtensions != nil {
+		o.fixExtensions = *profile.FixExtensions
+	}
+	if fromProfile("takeout") && profile.Takeout != nil {
+		o.takeout = *profile.Takeout
+	}
+	if fromProfile("set-mtime") && profile.SetModTime != nil {
+		o.setModTime = *profile.SetModTime
+	}
+	if fromProfile("reserve-gb") && profile.ReserveGB != nil {
+		o.reserveGB = *profile.ReserveGB
+	}
+	if fromProfile("trim-to-space") && profile.TrimToSpace != nil {
+		o.trimToSpace = *profile.TrimToSpace
+	}
+	if fromProfile("trash") && profile.Trash != "" {
+		o.trashMode = profile.Trash
+	}
+	if fromProfile("quarantine-dir") && profile.QuarantineDir != "" {
+		o.quarantineDir = profile.QuarantineDir
+	}
+	if fromProfile("trash-retention-days") && profile.TrashRetentionDays != nil {
+		o.retentionDays = *profile.TrashRetentionDays
+	}
+	if profile.AllowDelete != nil {
+		o.allowDelete = *profile.AllowDelete
+	}
+	return nil
+}
+
+// addSourceFlags registers the flags describing which files are collected from the source
+func (o *options) addSourceFlags(fs *flag.FlagSet) {
+	fs.StringVar(&o.source, "source", "", "source `dir` to collect the files from (required)")
+	o.addCollectFlags(fs)
+}
+
+// addCollectFlags registers the flags describing which files are collected and how their dates are resolved
+func (o *options) addCollectFlags(fs *flag.FlagSet) {
+	o.extensions = listFlag{values: append([]string(nil), defaultExtensions...)}
+	fs.Var(&o.extensions, "extensions", "comma separated list of file `extensions` to collect")
+	o.excludedDirs = listFlag{values: append([]string(nil), defaultExcludedDirs...)}
+	fs.Var(&o.excludedDirs, "exclude", "comma separated list of gitignore-style `patterns` to skip")
+	o.dateSources = listFlag{values: dateSourceNames(dates.DefaultOrder)}
+	fs.Var(&o.dateSources, "date-sources", "comma separated list of date `sources` consulted in order ("+strings.Join(dates.SourceNames(), ", ")+")")
+	fs.IntVar(&o.scanWorkers, "scan-workers", file.DefaultWalkWorkers, "`number` of dirs read concurrently")
+	fs.BoolVar(&o.strict, "strict", false, "fail if any path of the source cannot be scanned instead of skipping it")
+	fs.BoolVar(&o.sniff, "sniff", false, "detect the type of the files from their content to collect misnamed and extensionless files")
+	fs.BoolVar(&o.takeout, "takeout", false, "the source is a Google Takeout export, match its json files despite their cut names and take the dates and locations from them")
+}
+
+// addTargetFlags registers the flag for the target directory
+func (o *options) addTargetFlags(fs *flag.FlagSet) {
+	fs.StringVar(&o.target, "target", "", "target `dir` the files are copied to (required)")
+	fs.StringVar(&o.layout, "layout", layout.Default, "`template` of the destination relative to the target, placeholders: "+strings.Join(layout.Names(), ", "))
+	fs.StringVar(&o.videosDir, "videos-dir", "", "`dir` relative to the target videos are sorted into, by default they are sorted like photos")
+	fs.BoolVar(&o.skipDuplicates, "skip-duplicates", true, "skip files whose content already exists in the target")
+	fs.BoolVar(&o.useIndex, "index", true, "skip files the index of the target knows as imported and record the copied ones")
+	fs.BoolVar(&o.fixExtensions, "fix-extensions", false, "name the copies with the extension of their detected type, implies --sniff")
+}
+
+// addExecutorFlags registers the flags tuning how the files are copied
+func (o *options) addExecutorFlags(fs *flag.FlagSet) {
+	fs.StringVar(&o.copyMethod, "copy-method", string(file.CopyAuto), "`method` used to copy the content: auto clones or lets the kernel copy where possible, stream always copies through a buffer")
+	fs.IntVar(&o.bufferKiB, "buffer-kib", file.DefaultBufferSize/1024, "size of the copy buffer in `KiB`")
+	fs.IntVar(&o.workers, "workers", defaultWorkers, "`number` of files copied concurrently")
+	fs.BoolVar(&o.verifyCopies, "verify", false, "read back and compare the hash of copies as well, moves are always verified before their source is deleted")
+	fs.BoolVar(&o.setModTime, "set-mtime", false, "set the modification time of the copies to their resolved date")
+}
+
+// addSpaceFlags registers the flags of the check whether the operations fit into the target
+func (o *options) addSpaceFlags(fs *flag.FlagSet) {
+	fs.Float64Var(&o.reserveGB, "reserve-gb", defaultReserveGB, "`GB` which have to stay free on every file system of the target")
+	fs.BoolVar(&o.trimToSpace, "trim-to-space", false, "copy the oldest files which fit into the target and skip the others instead of refusing to start")
+}
+
+// addTrashFlags registers the flags deciding where deleted sources go
+func (o *options) addTrashFlags(fs *flag.FlagSet) {
+	fs.StringVar(&o.trashMode, "trash", string(trash.ModeAuto), "`mode` deciding where deleted sources go: xdg puts them into the trash of the desktop, quarantine into the --quarantine-dir, none removes them for good, auto is xdg on Linux and quarantine elsewhere")
+	fs.StringVar(&o.quarantineDir, "quarantine-dir", "", "`dir` on the file system of the source deleted sources are quarantined in (default <source>/"+trash.QuarantineDirName+")")
+	fs.IntVar(&o.retentionDays, "trash-retention-days", defaultTrashRetentionDays, "quarantined runs older than this number of `days` are deleted for good")
+}
+
+// addRetentionFlags registers the flags of the retention policy deciding which files are deleted from the source
+func (o *options) addRetentionFlags(fs *flag.FlagSet) {
+	fs.IntVar(&o.cutoffMonths, "cutoff-months", defaultCutoffMonths, "files older than this number of `months` are moved instead of copied")
+	fs.BoolVar(&o.keepFavorites, "keep-favorites", false, "keep the photos rated with 5 stars in their XMP sidecar or marked as favorite in their Google Takeout json")
+	fs.Var(&o.keepAlbums, "keep-albums", "comma separated list of album `names`, files in a dir of one of these names are kept")
+	fs.Float64Var(&o.minFreeGB, "min-free-gb", 0, "only delete as many of the oldest files as are needed to have this many `GB` free on the source, 0 deletes all old files")
+}
+
+// requireSource returns a usage error if no source is given
+func (o *options) requireSource() error {
+	if o.source == "" {
+		return newUsageError("missing required flag --source")
+	}
+	return nil
+}
+
+// requireTarget returns a usage error if no target is given
+func (o *options) requireTarget() error {
+	if o.target == "" {
+		return newUsageError("missing required flag --target")
+	}
+	return nil
+}
+
+// spaceReserve returns the bytes which have to stay free on the target
+func (o *options) spaceReserve() (int64, error) {
+	if o.reserveGB < 0 {
+		return 0, newUsageError("--reserve-gb must not be negative")
+	}
+	return int64(o.reserveGB * 1000 * 1000 * 1000), nil
+}
+
+// retentionPolicy returns the retention.Policy described by the flags
+func (o *options) retentionPolicy() (*retention.Policy, error) {
+	if o.cutoffMonths < 0 {
+		return nil, newUsageError("--cutoff-months must not be negative")
+	}
+	if o.minFreeGB < 0 {
+		return nil, newUsageError("--min-free-gb must not be negative")
+	}
+	return &retention.Policy{
+		KeepMonths:    o.cutoffMonths,
+		KeepFavorites: o.keepFavorites,
+		KeepAlbums:    o.keepAlbums.values,
+		MinFreeBytes:  int64(o.minFreeGB * 1000 * 1000 * 1000),
+	}, nil
+}
+
+// retentionFacts returns what the retention policy knows about the source, its free space is unknown if it cannot
+// be read
+func (o *options) retentionFacts() retention.Facts {
+	facts := retention.Facts{Now: time.Now(), FreeBytes: -1}
+	if free, err := utils.FreeSpace(o.source); err == nil {
+		facts.FreeBytes = free
+	}
+	return facts
+}
+
+// collectFilesConfig creates the file.CollectFilesConfig described by the flags
+func (o *options) collectFilesConfig() (file.CollectFilesConfig, error) {
+	extensions := make([]string, 0, len(o.extensions.values))
+	for _, extension := range o.extensions.values {
+		extension = strings.ToLower(extension)
+		if !strings.HasPrefix(extension, ".") {
+			extension = "." + extension
+		}
+		extensions = append(extensions, extension)
+	}
+	sources := o.dateSources.values
+	if o.takeout && !o.dateSources.set {
+		sources = dateSourceNames(dates.TakeoutOrder)
+	}
+	chain, err := dates.NewChain(sources)
+	if err != nil {
+		return file.CollectFilesConfig{}, newUsageError(err.Error())
+	}
+	if o.scanWorkers <= 0 {
+		return file.CollectFilesConfig{}, newUsageError("--scan-workers must be positive")
+	}
+	if _, err := ignore.Compile(o.excludedDirs.values); err != nil {
+		return file.CollectFilesConfig{}, newUsageError("--exclude: " + err.Error())
+	}
+	excludedDirs := o.excludedDirs.values
+	// quarantined files must not be collected again, the default quarantine dir is always skipped
+	if rel, err := filepath.Rel(o.source, o.quarantineDir); o.quarantineDir != "" && err == nil && rel != "." && !strings.HasPrefix(rel, "..") {
+		excludedDirs = append(append([]string(nil), excludedDirs...), ignore.Literal(rel)+"/")
+	}
+	return file.CollectFilesConfig{ExcludedDirs: excludedDirs, SupportedExtensions: extensions, DateResolvers: chain, Workers: o.scanWorkers, Sniff: o.sniff || o.fixExtensions, Takeout: o.takeout}, nil
+}
+
+// copyConfig creates the file.CopyConfig described by the flags
+func (o *options) copyConfig() (file.CopyConfig, error) {
+	fileLayout, err := layout.Parse(o.layout)
+	if err != nil {
+		return file.CopyConfig{}, newUsageError(err.Error())
+	}
+	if !utils.IsBelow(o.videosDir) {
+		return file.CopyConfig{}, newUsageError(fmt.Sprintf("--videos-dir %q must be relative to the target", o.videosDir))
+	}
+	copyConfig := file.CopyConfig{VideosDir: o.videosDir, Layout: fileLayout, SkipDuplicates: o.skipDuplicates, FixExtensions: o.fixExtensions}
+	if o.useIndex {
+		if copyConfig.Index, err = o.loadIndex(); err != nil {
+			return file.CopyConfig{}, err
+		}
+	}
+	return copyConfig, nil
+}
+
+// executor creates the file.Executor described by the flags recording into the given index, the manifest and, with a
+// target, the journal of the run of the manifest. The trash is only created if the run deletes sources.
+func (o *options) executor(idx *index.Index, manifest *runs.Manifest, deletes bool) (file.Executor, error) {
+	method, err := file.ParseCopyMethod(o.copyMethod)
+	if err != nil {
+		return file.Executor{}, newUsageError(err.Error())
+	}
+	if o.bufferKiB <= 0 {
+		return file.Executor{}, newUsageError("--buffer-kib must be positive")
+	}
+	if o.workers <= 0 {
+		return file.Executor{}, newUsageError("--workers must be positive")
+	}
+	bin, err := o.trash(manifest.RunID, deletes)
+	if err != nil {
+		return file.Executor{}, err
+	}
+	var journal *runs.Journal
+	if o.target != "" {
+		journal = runs.NewJournal(o.target, manifest.RunID)
+	}
+	return file.Executor{Index: idx, Method: method, BufferSize: o.bufferKiB * 1024, VerifyCopies: o.verifyCopies, Manifest: manifest, Workers: o.workers, SetModTime: o.setModTime, Trash: bin, Journal: journal}, nil
+}
+
+// trash creates the trash.Trash described by the flags taking the sources the run deletes. It is nil if the sources
+// are removed for good or the run does not delete any, the flags are validated anyway.
+func (o *options) trash(runID string, deletes bool) (trash.Trash, error) {
+	if o.trashMode == "" {
+		return nil, nil
+	}
+	mode, err := trash.ParseMode(o.trashMode)
+	if err != nil {
+		return nil, newUsageError("--trash: " + err.Error())
+	}
+	if o.retentionDays < 0 {
+		return nil, newUsageError("--trash-retention-days must not be negative")
+	}
+	if !deletes {
+		return nil, nil
+	}
+	switch mode {
+	case trash.ModeXDG:
+		return trash.XDG{}, nil
+	case trash.ModeQuarantine:
+		dir := o.quarantineDir
+		if dir == "" && o.source == "" {
+			return nil, newUsageError("--quarantine-dir is required without --source")
+		}
+		if dir == "" {
+			dir = filepath.Join(o.source, trash.QuarantineDirName)
+		}
+		return trash.Quarantine{Dir: dir, Root: o.source, RunID: runID}, nil
+	}
+	return nil, nil
+}
+
+// purgeQuarantine deletes the quarantined runs older than the retention for good
+func (o *options) purgeQuarantine(bin trash.Trash, out io.Writer) error {
+	quarantine, ok := bin.(trash.Quarantine)
+	if !ok {
+		return nil
+	}
+	purged, err := quarantine.Purge(time.Duration(o.retentionDays)*24*time.Hour, time.Now())
+	for _, dir := range purged {
+		fmt.Fprintln(out, "Purged quarantined run", dir)
+	}
+	return err
+}
+
+// loadIndex loads the index of the target recording the profile in all new entries
+func (o *options) loadIndex() (*index.Index, error) {
+	idx, err := index.Load(o.target)
+	if err != nil {
+		return nil, err
+	}
+	idx.Profile = o.profile
+	return idx, nil
+}
+
+// dateSourceNames converts the date sources to their names
+func dateSourceNames(sources []model.DateSource) []string {
+	names := make([]string, 0, len(sources))
+	for _, source := range sources {
+		names = append(names, string(source))
+	}
+	return names
+}
+
+// listFlag is a flag.Value holding a comma separated list. Setting the flag the first time replaces
+// the default values, repeating the flag appends to the list.
+type listFlag struct {
+	values []string
+	set    bool
+}
+
+func (l *listFlag) String() string {
+	if l == nil {
+		return ""
+	}
+	return strings.Join(l.values, ",")
+}
+
+func (l *listFlag) Set(value string) error {
+	if !l.set {
+		l.values = nil
+		l.set = true
+	}
+	for _, item := range strings.Split(value, ",") {
+		item = strings.TrimSpace(item)
+		if item != "" {
+			l.values = append(l.values, item)
+		}
+	}
+	return nil
+}
+
+// usageError is returned by commands if they were invoked with wrong arguments
+type usageError struct {
+	msg string
+}
+
+func newUsageError(msg string) *usageError {
+	return &usageError{msg: msg}
+}
+
+func (e *usageError) Error() string {
+	return e.msg
+}
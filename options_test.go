@@ -0,0 +1,44 @@
+package main
+
+import (
+	"copy-images/model"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProfileIsMappedOntoTheCollectFilesConfig(t *testing.T) {
+
+	//GIVEN
+	source := t.TempDir()
+	configFile := writeFile(t, filepath.Join(t.TempDir(), "config.yaml"), `
+profiles:
+  pixel6:
+    source: `+source+`
+    excluded_dirs: [".thumbnails"]
+    supported_extensions: [".jpg", ".png"]
+    date_sources: [filename, mtime]
+    fix_extensions: true
+    quarantine_dir: `+filepath.Join(source, "Trash")+`
+`)
+	opts := &options{}
+	fs := findCommand(commands, "move").flagSet(opts, ioutil.Discard)
+	assert.Nil(t, fs.Parse([]string{"--config", configFile, "--profile", "pixel6", "--scan-workers", "2"}))
+
+	//WHEN
+	err := opts.applyProfile(fs)
+	collectFilesConfig, configErr := opts.collectFilesConfig()
+
+	//THEN
+	assert.Nil(t, err, "No error must be thrown")
+	assert.Nil(t, configErr, "No error must be thrown")
+	assert.Equal(t, []string{".thumbnails", "/Trash/"}, collectFilesConfig.ExcludedDirs, "The quarantine must never be collected")
+	assert.Equal(t, []string{".jpg", ".png"}, collectFilesConfig.SupportedExtensions)
+	assert.Equal(t, 2, len(collectFilesConfig.DateResolvers))
+	assert.Equal(t, model.FilenameSource, collectFilesConfig.DateResolvers[0].Source())
+	assert.True(t, collectFilesConfig.Sniff, "Fixing the extensions needs the detected types")
+	assert.Equal(t, 2, collectFilesConfig.Workers, "A flag must override the profile")
+
+}
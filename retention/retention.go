@@ -0,0 +1,194 @@
+// Package retention decides which files are deleted from a source once they have been copied to the target. A Policy
+// combines rules like keeping the newest months, the favorites or enough free space, and explains for every file which
+// rule decided its fate.
+package retention
+
+import (
+	"copy-images/model"
+	"copy-images/takeout"
+	"copy-images/utils"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Rule names the rule of a Policy which decided the fate of a file
+type Rule string
+
+const (
+	// Unverified keeps the files which have no verified copy in the target
+	Unverified Rule = "unverified"
+	// Favorite keeps the files marked as favorite, see Policy.KeepFavorites
+	Favorite Rule = "favorite"
+	// Album keeps the files in one of the Policy.KeepAlbums
+	Album Rule = "album"
+	// Age keeps the files of the newest Policy.KeepMonths and deletes the older ones
+	Age Rule = "age"
+	// FreeSpace deletes the oldest files until the source has Policy.MinFreeBytes free and keeps the others
+	FreeSpace Rule = "free-space"
+)
+
+// favoriteRating is the XMP rating from which on a photo is a favorite
+const favoriteRating = 5
+
+// ratingPattern matches the rating of an XMP file written as attribute or as element
+var ratingPattern = regexp.MustCompile(`xmp:Rating(?:\s*=\s*["']|>)\s*(-?\d+)`)
+
+// Policy decides which files are deleted from the source. The rules are tried in the order of the Rule constants, the
+// first one which keeps a file decides. Files without a verified copy are always kept.
+type Policy struct {
+	// KeepMonths keeps the files taken within this number of months, older ones are deleted
+	KeepMonths int
+	// KeepFavorites keeps the photos rated with 5 stars in their XMP sidecar or marked as favorite in the json
+	// sidecar of a Google Takeout export
+	KeepFavorites bool
+	// KeepAlbums keeps the files whose dir has one of these names, compared ignoring case
+	KeepAlbums []string
+	// MinFreeBytes only deletes as many of the files older than KeepMonths as are needed to have this many bytes
+	// free on the source, the oldest first. All of them are deleted if it is 0.
+	MinFreeBytes int64
+}
+
+// Group are files which are deleted or kept together, like a RAW+JPEG pair
+type Group struct {
+	// Files are the files of the group, the date of the first one counts
+	Files []model.FileInfo
+	// Verified is set if the files have copies in the target which have been verified or will be before the files
+	// are deleted
+	Verified bool
+}
+
+// Facts are what a Policy knows beyond the files
+type Facts struct {
+	// Now is the time the months are counted back from, the current time if it is zero
+	Now time.Time
+	// FreeBytes is the free space of the source, it is negative if it is not known
+	FreeBytes int64
+}
+
+// Decision is the fate of a group with the rule which decided it
+type Decision struct {
+	Delete bool
+	Rule   Rule
+	Reason string
+}
+
+// String returns the decision as "keep" or "delete" followed by the rule and the reason
+func (d Decision) String() string {
+	verb := "keep"
+	if d.Delete {
+		verb = "delete"
+	}
+	return fmt.Sprintf("%s (%s: %s)", verb, d.Rule, d.Reason)
+}
+
+// Decide returns the decision of every group in the order of the groups. The groups are decided oldest first, so that
+// MinFreeBytes is reached by deleting the oldest files.
+func (p Policy) Decide(groups []Group, facts Facts) []Decision {
+	if facts.Now.IsZero() {
+		facts.Now = time.Now()
+	}
+	cutoff := utils.RemoveMonths(facts.Now, p.KeepMonths)
+	order := make([]int, len(groups))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool {
+		return groups[order[a]].Files[0].CreationDate.Before(groups[order[b]].Files[0].CreationDate)
+	})
+	decisions := make([]Decision, len(groups))
+	freed := int64(0)
+	for _, i := range order {
+		decision, kept := p.keep(groups[i], cutoff)
+		if !kept {
+			decision = p.delete(groups[i], facts, freed)
+		}
+		if decision.Delete {
+			freed += size(groups[i])
+		}
+		decisions[i] = decision
+	}
+	return decisions
+}
+
+// keep returns the decision of the first rule keeping the group
+func (p Policy) keep(group Group, cutoff time.Time) (Decision, bool) {
+	if !group.Verified {
+		return Decision{Rule: Unverified, Reason: "no verified copy in the target"}, true
+	}
+	for i := 0; p.KeepFavorites && i < len(group.Files); i++ {
+		if reason, ok := favorite(group.Files[i]); ok {
+			return Decision{Rule: Favorite, Reason: reason}, true
+		}
+	}
+	for _, f := range group.Files {
+		album := filepath.Base(filepath.Dir(f.Path))
+		for _, name := range p.KeepAlbums {
+			if strings.EqualFold(album, name) {
+				return Decision{Rule: Album, Reason: "in album " + album}, true
+			}
+		}
+	}
+	taken := group.Files[0].CreationDate
+	if !taken.Before(cutoff) {
+		return Decision{Rule: Age, Reason: fmt.Sprintf("taken %s, within the newest %d months", taken.Format("2006-01-02"), p.KeepMonths)}, true
+	}
+	return Decision{}, false
+}
+
+// delete returns the decision for a group no rule keeps, freed is the size of the groups deleted before
+func (p Policy) delete(group Group, facts Facts, freed int64) Decision {
+	age := fmt.Sprintf("taken %s, older than %d months", group.Files[0].CreationDate.Format("2006-01-02"), p.KeepMonths)
+	if p.MinFreeBytes <= 0 {
+		return Decision{Delete: true, Rule: Age, Reason: age}
+	}
+	if facts.FreeBytes < 0 {
+		return Decision{Rule: FreeSpace, Reason: age + ", but the free space of the source is not known"}
+	}
+	free := facts.FreeBytes + freed
+	if free >= p.MinFreeBytes {
+		return Decision{Rule: FreeSpace, Reason: fmt.Sprintf("%s, but %s are free of the %s needed", age, utils.FormatSize(free), utils.FormatSize(p.MinFreeBytes))}
+	}
+	return Decision{Delete: true, Rule: FreeSpace, Reason: fmt.Sprintf("%s and only %s are free of the %s needed", age, utils.FormatSize(free), utils.FormatSize(p.MinFreeBytes))}
+}
+
+// favorite checks the sidecars of the file for a favorite mark and returns where it has been found. A sidecar which
+// cannot be read keeps the file as well, it may hold a mark.
+func favorite(f model.FileInfo) (string, bool) {
+	for _, sidecar := range f.Sidecars {
+		extension := strings.ToLower(filepath.Ext(sidecar))
+		if extension != ".xmp" && extension != ".json" {
+			continue
+		}
+		input, err := ioutil.ReadFile(sidecar)
+		if err != nil {
+			return "cannot read the sidecar " + err.Error(), true
+		}
+		if extension == ".json" {
+			if metadata, err := takeout.Decode(input); err == nil && metadata.Favorited {
+				return "marked as favorite in " + filepath.Base(sidecar), true
+			}
+			continue
+		}
+		if match := ratingPattern.FindSubmatch(input); match != nil {
+			if rating, err := strconv.Atoi(string(match[1])); err == nil && rating >= favoriteRating {
+				return fmt.Sprintf("rated %d stars in %s", rating, filepath.Base(sidecar)), true
+			}
+		}
+	}
+	return "", false
+}
+
+// size returns the size of the files of the group
+func size(group Group) int64 {
+	var total int64
+	for _, f := range group.Files {
+		total += f.Size
+	}
+	return total
+}
@@ -0,0 +1,73 @@
+package retention_test
+
+import (
+	"copy-images/model"
+	"copy-images/retention"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTheFirstRuleKeepingAFileDecides(t *testing.T) {
+
+	//GIVEN
+	dir := t.TempDir()
+	now, _ := time.Parse("2006-01-02", "2021-06-06")
+	old, _ := time.Parse("2006-01-02", "2021-01-01")
+	rated := filepath.Join(dir, "IMG_2.xmp")
+	ioutil.WriteFile(rated, []byte(`<rdf:Description xmp:Rating="5"/>`), 0644)
+	liked := filepath.Join(dir, "IMG_3.jpg.json")
+	ioutil.WriteFile(liked, []byte(`{"title": "IMG_3.jpg", "favorited": true}`), 0644)
+	groups := []retention.Group{
+		{Files: []model.FileInfo{{Path: filepath.Join(dir, "IMG_1.jpg"), CreationDate: old}}},
+		{Files: []model.FileInfo{{Path: filepath.Join(dir, "IMG_2.jpg"), CreationDate: old, Sidecars: []string{rated}}}, Verified: true},
+		{Files: []model.FileInfo{{Path: filepath.Join(dir, "IMG_3.jpg"), CreationDate: old, Sidecars: []string{liked}}}, Verified: true},
+		{Files: []model.FileInfo{{Path: filepath.Join(dir, "family", "IMG_4.jpg"), CreationDate: old}}, Verified: true},
+		{Files: []model.FileInfo{{Path: filepath.Join(dir, "IMG_5.jpg"), CreationDate: now.AddDate(0, -1, 0)}}, Verified: true},
+		{Files: []model.FileInfo{{Path: filepath.Join(dir, "IMG_6.jpg"), CreationDate: old}}, Verified: true},
+	}
+	policy := retention.Policy{KeepMonths: 2, KeepFavorites: true, KeepAlbums: []string{"Family"}}
+
+	//WHEN
+	decisions := policy.Decide(groups, retention.Facts{Now: now, FreeBytes: -1})
+
+	//THEN
+	assert.Equal(t, []retention.Rule{retention.Unverified, retention.Favorite, retention.Favorite, retention.Album, retention.Age, retention.Age},
+		[]retention.Rule{decisions[0].Rule, decisions[1].Rule, decisions[2].Rule, decisions[3].Rule, decisions[4].Rule, decisions[5].Rule})
+	for _, decision := range decisions[:5] {
+		assert.False(t, decision.Delete, decision.String())
+	}
+	assert.True(t, decisions[5].Delete)
+	assert.Equal(t, "keep (favorite: rated 5 stars in IMG_2.xmp)", decisions[1].String())
+	assert.Equal(t, "delete (age: taken 2021-01-01, older than 2 months)", decisions[5].String())
+
+}
+
+func TestOnlyTheOldestFilesNeededToFreeTheSpaceAreDeleted(t *testing.T) {
+
+	//GIVEN
+	now, _ := time.Parse("2006-01-02", "2021-06-06")
+	group := func(date string) retention.Group {
+		taken, _ := time.Parse("2006-01-02", date)
+		return retention.Group{Files: []model.FileInfo{{Path: "/phone/DCIM/IMG_" + date + ".jpg", CreationDate: taken, Size: 4000}}, Verified: true}
+	}
+	groups := []retention.Group{group("2021-03-03"), group("2021-01-01"), group("2021-02-02"), group("2021-05-05")}
+	policy := retention.Policy{KeepMonths: 2, MinFreeBytes: 7000}
+
+	//WHEN
+	decisions := policy.Decide(groups, retention.Facts{Now: now, FreeBytes: 2000})
+	unknown := policy.Decide(groups, retention.Facts{Now: now, FreeBytes: -1})
+
+	//THEN
+	assert.Equal(t, []bool{false, true, true, false}, []bool{decisions[0].Delete, decisions[1].Delete, decisions[2].Delete, decisions[3].Delete})
+	assert.Equal(t, retention.FreeSpace, decisions[0].Rule)
+	assert.Equal(t, "keep (free-space: taken 2021-03-03, older than 2 months, but 10.0 kB are free of the 7.0 kB needed)", decisions[0].String())
+	assert.Equal(t, retention.Age, decisions[3].Rule)
+	for _, decision := range unknown {
+		assert.False(t, decision.Delete, "Nothing must be deleted if the free space is not known")
+	}
+
+}
@@ -0,0 +1,50 @@
+package runs
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// appender appends json lines to a file and syncs every line to disk. The file is created with the first line. It is
+// safe for concurrent use.
+type appender struct {
+	path string
+	mu   sync.Mutex
+	file *os.File
+}
+
+// append writes the value as a line and syncs it to disk
+func (a *appender) append(value interface{}) error {
+	line, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.file == nil {
+		if err := os.MkdirAll(filepath.Dir(a.path), os.ModePerm); err != nil {
+			return err
+		}
+		if a.file, err = os.OpenFile(a.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644); err != nil {
+			return err
+		}
+	}
+	if _, err := a.file.Write(append(line, '\n')); err != nil {
+		return err
+	}
+	return a.file.Sync()
+}
+
+// close closes the file if it has been created
+func (a *appender) close() error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.file == nil {
+		return nil
+	}
+	err := a.file.Close()
+	a.file = nil
+	return err
+}
@@ -0,0 +1,140 @@
+package runs
+
+import (
+	"copy-images/utils"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// PlanFileName is the name of the plan copy and move write to the dir of their run, so that they can be resumed
+const PlanFileName = "plan.json"
+
+// stateSuffix replaces the extension of a plan in the name of its state file
+const stateSuffix = ".state.jsonl"
+
+// Completed is a line of a state file, it records an operation of the plan which has been completed. A line holding
+// only Run records the run which started to apply the plan.
+type Completed struct {
+	Time time.Time `json:"time"`
+	From string    `json:"from,omitempty"`
+	To   string    `json:"to,omitempty"`
+	Run  string    `json:"run,omitempty"`
+}
+
+// Checkpoint records the completed operations of a plan in a state file next to the plan, so that a run which has
+// been interrupted can be resumed without repeating them. Operations are identified by their source and destination.
+// It is safe for concurrent use.
+type Checkpoint struct {
+	appender
+	planFile string
+	lastRun  string
+	mu       sync.Mutex
+	done     map[string]bool
+}
+
+// PlanPath returns the path of the plan a copy or move writes to the dir of its run
+func PlanPath(targetDir string, runID string) string {
+	return filepath.Join(Dir(targetDir, runID), PlanFileName)
+}
+
+// RunOf returns the id of the run in the target whose dir holds the plan, it is empty for any other plan
+func RunOf(targetDir string, planFile string) string {
+	planFile, err := filepath.Abs(planFile)
+	if err != nil || filepath.Base(planFile) != PlanFileName {
+		return ""
+	}
+	runID := filepath.Base(filepath.Dir(planFile))
+	if dir, err := filepath.Abs(Dir(targetDir, runID)); err != nil || dir != filepath.Dir(planFile) {
+		return ""
+	}
+	return runID
+}
+
+// StatePath returns the path of the state file of the plan, plan.json keeps its state in plan.state.jsonl
+func StatePath(planFile string) string {
+	return strings.TrimSuffix(planFile, filepath.Ext(planFile)) + stateSuffix
+}
+
+// NewCheckpoint starts a new state file for the plan, the state of an earlier run of the plan is removed
+func NewCheckpoint(planFile string) (*Checkpoint, error) {
+	if err := os.Remove(StatePath(planFile)); err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	return &Checkpoint{appender: appender{path: StatePath(planFile)}, planFile: planFile, done: make(map[string]bool)}, nil
+}
+
+// OpenCheckpoint reads the state file of the plan to resume it, a plan without state file starts from the beginning
+func OpenCheckpoint(planFile string) (*Checkpoint, error) {
+	checkpoint := &Checkpoint{appender: appender{path: StatePath(planFile)}, planFile: planFile, done: make(map[string]bool)}
+	err := utils.ReadLines(checkpoint.path, func(line []byte) error {
+		var completed Completed
+		if err := json.Unmarshal(line, &completed); err != nil {
+			return err
+		}
+		if completed.From == "" {
+			checkpoint.lastRun = completed.Run
+			return nil
+		}
+		checkpoint.done[operationKey(completed.From, completed.To)] = true
+		return nil
+	})
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	return checkpoint, nil
+}
+
+// PlanFile returns the plan whose state is recorded
+func (c *Checkpoint) PlanFile() string {
+	return c.planFile
+}
+
+// LastRun returns the last run which started to apply the plan before the checkpoint was opened, it is empty for a new
+// checkpoint
+func (c *Checkpoint) LastRun() string {
+	return c.lastRun
+}
+
+// Start records the run which starts to apply the plan, so that a run resuming it knows its parent
+func (c *Checkpoint) Start(runID string) error {
+	return c.append(Completed{Time: time.Now(), Run: runID})
+}
+
+// Done checks if the operation has been completed
+func (c *Checkpoint) Done(from string, to string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.done[operationKey(from, to)]
+}
+
+// Count returns the number of completed operations
+func (c *Checkpoint) Count() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.done)
+}
+
+// Complete records the operation as completed and syncs the state file to disk
+func (c *Checkpoint) Complete(from string, to string) error {
+	if err := c.append(Completed{Time: time.Now(), From: from, To: to}); err != nil {
+		return err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.done[operationKey(from, to)] = true
+	return nil
+}
+
+// Close closes the state file
+func (c *Checkpoint) Close() error {
+	return c.close()
+}
+
+// operationKey identifies an operation by its source and destination
+func operationKey(from string, to string) string {
+	return from + "\x00" + to
+}
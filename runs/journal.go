@@ -0,0 +1,76 @@
+package runs
+
+import (
+	"copy-images/utils"
+	"encoding/json"
+	"path/filepath"
+	"time"
+)
+
+// JournalFileName is the name of the journal within the dir of a run
+const JournalFileName = "journal.jsonl"
+
+// Action is a change of the file system recorded in a journal
+type Action string
+
+const (
+	// Create writes a copy of From to Path
+	Create Action = "create"
+	// Trash renames the source at Path into the trash at To
+	Trash Action = "trash"
+	// Remove removes the source at Path for good
+	Remove Action = "remove"
+)
+
+// Record is a line of a journal. Every action is recorded before it is carried out and recorded again as done once it
+// is complete, so after a crash the records without done tell which paths may have been changed.
+type Record struct {
+	Time   time.Time `json:"time"`
+	Action Action    `json:"action"`
+	Done   bool      `json:"done,omitempty"`
+	Path   string    `json:"path"`
+	// From is the source of a created copy or the existing copy of a removed source
+	From string `json:"from,omitempty"`
+	// To is where a trashed source has been put
+	To string `json:"to,omitempty"`
+	// Hash is the hex sha-256 of the content, it is empty if it was not known before the action
+	Hash string `json:"hash,omitempty"`
+}
+
+// Journal appends the records of a run to the journal file in the dir of the run. Every record is synced to disk
+// before the action it announces is carried out. The file is created with the first record. It is safe for
+// concurrent use.
+type Journal struct {
+	appender
+}
+
+// NewJournal creates the journal of the run in the target
+func NewJournal(targetDir string, runID string) *Journal {
+	return &Journal{appender{path: filepath.Join(Dir(targetDir, runID), JournalFileName)}}
+}
+
+// Append writes the record to the journal and syncs it to disk
+func (j *Journal) Append(record Record) error {
+	record.Time = time.Now()
+	return j.append(record)
+}
+
+// Close closes the journal file
+func (j *Journal) Close() error {
+	return j.close()
+}
+
+// ReadJournal reads the journal of a run from the target. A last line cut by a crash while it was written is ignored,
+// the action it announced has not been started.
+func ReadJournal(targetDir string, runID string) ([]Record, error) {
+	var records []Record
+	err := utils.ReadLines(filepath.Join(Dir(targetDir, runID), JournalFileName), func(line []byte) error {
+		var record Record
+		if err := json.Unmarshal(line, &record); err != nil {
+			return err
+		}
+		records = append(records, record)
+		return nil
+	})
+	return records, err
+}
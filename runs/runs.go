@@ -0,0 +1,160 @@
+// Package runs identifies the runs applying file operations to a target and keeps their manifests.
+// Everything a run writes lives in <target>/.copy-images/runs/<run id>.
+package runs
+
+import (
+	"copy-images/index"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// ManifestFileName is the name of the manifest within the dir of a run
+const ManifestFileName = "manifest.json"
+
+// Status is the outcome of a single operation of a run
+type Status string
+
+const (
+	// Verified copies have been read back and match the hash of their source
+	Verified Status = "verified"
+	// Copied files have been copied without reading them back
+	Copied Status = "copied"
+	// Mismatch copies differ from their source, the copy has been removed and the source kept
+	Mismatch Status = "mismatch"
+	// Skipped operations have not been executed
+	Skipped Status = "skipped"
+	// Failed operations could not be executed
+	Failed Status = "failed"
+)
+
+// Entry is the result of a single operation
+type Entry struct {
+	From            string `json:"from"`
+	To              string `json:"to,omitempty"`
+	OpType          string `json:"type"`
+	Status          Status `json:"status"`
+	Size            int64  `json:"size,omitempty"`
+	SourceHash      string `json:"source_hash,omitempty"`
+	DestinationHash string `json:"destination_hash,omitempty"`
+	// SourceDeleted is set once the source of a verified move has been removed
+	SourceDeleted bool `json:"source_deleted,omitempty"`
+	// Trashed is where the deleted source has been put, it is empty if it has been removed for good
+	Trashed string `json:"trashed,omitempty"`
+	Error   string `json:"error,omitempty"`
+}
+
+// Manifest records the results of all operations of a run. Add and Count are safe for concurrent use.
+type Manifest struct {
+	RunID string `json:"run_id"`
+	// Parent is the run which applied the plan before this run resumed it, the runs resuming the plan of a copy or
+	// move continue the run which wrote it instead
+	Parent   string    `json:"parent,omitempty"`
+	Started  time.Time `json:"started"`
+	Finished time.Time `json:"finished"`
+	Entries  []Entry   `json:"entries"`
+	mu       sync.Mutex
+}
+
+// NewID returns a new run id made of the current time and a random suffix, run ids sort by their start
+func NewID() string {
+	suffix := make([]byte, 3)
+	rand.Read(suffix)
+	return time.Now().Format("20060102-150405") + "-" + hex.EncodeToString(suffix)
+}
+
+// Dir returns the dir holding everything the run wrote to the target
+func Dir(targetDir string, runID string) string {
+	return filepath.Join(targetDir, index.Dir, "runs", runID)
+}
+
+// NewManifest starts the manifest of a new run
+func NewManifest() *Manifest {
+	return &Manifest{RunID: NewID(), Started: time.Now()}
+}
+
+// OpenManifest reads the manifest of a run to continue it, a run which ended before writing its manifest starts a new
+// one with its id
+func OpenManifest(targetDir string, runID string) (*Manifest, error) {
+	m, err := ReadManifest(targetDir, runID)
+	if os.IsNotExist(err) {
+		return &Manifest{RunID: runID, Started: time.Now()}, nil
+	}
+	return m, err
+}
+
+// Lineage returns the run followed by the parents recorded in the manifests, the latest run first
+func Lineage(targetDir string, runID string) []string {
+	lineage := []string{runID}
+	seen := map[string]bool{runID: true}
+	for {
+		m, err := ReadManifest(targetDir, lineage[len(lineage)-1])
+		if err != nil || m.Parent == "" || seen[m.Parent] {
+			return lineage
+		}
+		seen[m.Parent] = true
+		lineage = append(lineage, m.Parent)
+	}
+}
+
+// Add records the result of an operation
+func (m *Manifest) Add(entry Entry) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.Entries = append(m.Entries, entry)
+}
+
+// Holds checks if the manifest has an entry for the operation from the source to the destination
+func (m *Manifest) Holds(from string, to string) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, entry := range m.Entries {
+		if entry.From == from && entry.To == to {
+			return true
+		}
+	}
+	return false
+}
+
+// Count returns the number of entries having the given status
+func (m *Manifest) Count(status Status) int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	count := 0
+	for _, entry := range m.Entries {
+		if entry.Status == status {
+			count++
+		}
+	}
+	return count
+}
+
+// Write finishes the manifest and writes it to the dir of the run in the target
+func (m *Manifest) Write(targetDir string) (string, error) {
+	m.Finished = time.Now()
+	dir := Dir(targetDir, m.RunID)
+	if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+		return "", err
+	}
+	content, err := json.MarshalIndent(m, "", "     ")
+	if err != nil {
+		return "", err
+	}
+	manifestFile := filepath.Join(dir, ManifestFileName)
+	return manifestFile, ioutil.WriteFile(manifestFile, content, 0644)
+}
+
+// ReadManifest reads the manifest of a run from the target
+func ReadManifest(targetDir string, runID string) (*Manifest, error) {
+	content, err := ioutil.ReadFile(filepath.Join(Dir(targetDir, runID), ManifestFileName))
+	if err != nil {
+		return nil, err
+	}
+	var m Manifest
+	return &m, json.Unmarshal(content, &m)
+}
@@ -0,0 +1,178 @@
+package runs_test
+
+import (
+	"copy-images/runs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewIDIsUniqueAndSortsByTime(t *testing.T) {
+
+	//GIVEN
+	pattern := regexp.MustCompile(`^\d{8}-\d{6}-[0-9a-f]{6}$`)
+
+	//WHEN
+	first := runs.NewID()
+	second := runs.NewID()
+
+	//THEN
+	assert.Regexp(t, pattern, first)
+	assert.NotEqual(t, first, second)
+
+}
+
+func TestManifestIsWrittenToTheRunDir(t *testing.T) {
+
+	//GIVEN
+	targetDir := t.TempDir()
+	manifest := runs.NewManifest()
+	manifest.Add(runs.Entry{From: "/phone/IMG_1.jpg", To: "/nas/IMG_1.jpg", OpType: "MOVE", Status: runs.Verified, SourceDeleted: true})
+	manifest.Add(runs.Entry{From: "/phone/IMG_2.jpg", To: "/nas/IMG_2.jpg", OpType: "MOVE", Status: runs.Mismatch})
+
+	//WHEN
+	manifestFile, err := manifest.Write(targetDir)
+	read, readErr := runs.ReadManifest(targetDir, manifest.RunID)
+
+	//THEN
+	assert.Nil(t, err, "No error must be thrown")
+	assert.Nil(t, readErr, "No error must be thrown")
+	assert.Equal(t, filepath.Join(targetDir, ".copy-images", "runs", manifest.RunID, "manifest.json"), manifestFile)
+	assert.Equal(t, manifest.Entries, read.Entries)
+	assert.False(t, read.Finished.Before(read.Started))
+	assert.Equal(t, 1, read.Count(runs.Verified))
+	assert.Equal(t, 1, read.Count(runs.Mismatch))
+
+}
+
+func TestJournalIsReadBackWithoutALineCutByACrash(t *testing.T) {
+
+	//GIVEN
+	targetDir := t.TempDir()
+	runID := runs.NewID()
+	journal := runs.NewJournal(targetDir, runID)
+	journal.Append(runs.Record{Action: runs.Create, Path: "/nas/IMG_1.jpg", From: "/phone/IMG_1.jpg"})
+	journal.Append(runs.Record{Action: runs.Create, Done: true, Path: "/nas/IMG_1.jpg", From: "/phone/IMG_1.jpg", Hash: "abc"})
+	journal.Close()
+	journalFile := filepath.Join(runs.Dir(targetDir, runID), runs.JournalFileName)
+	f, _ := os.OpenFile(journalFile, os.O_APPEND|os.O_WRONLY, 0644)
+	f.WriteString(`{"action":"trash","pa`)
+	f.Close()
+
+	//WHEN
+	records, err := runs.ReadJournal(targetDir, runID)
+
+	//THEN
+	assert.Nil(t, err, "No error must be thrown")
+	assert.Equal(t, 2, len(records))
+	assert.False(t, records[0].Done)
+	assert.True(t, records[1].Done)
+	assert.Equal(t, "abc", records[1].Hash)
+
+}
+
+func TestCheckpointIsResumedFromItsStateFile(t *testing.T) {
+
+	//GIVEN
+	planFile := filepath.Join(t.TempDir(), "plan.json")
+	checkpoint, _ := runs.NewCheckpoint(planFile)
+	checkpoint.Complete("/phone/IMG_1.jpg", "/nas/IMG_1.jpg")
+	checkpoint.Close()
+
+	//WHEN
+	resumed, err := runs.OpenCheckpoint(planFile)
+	restarted, restartErr := runs.NewCheckpoint(planFile)
+
+	//THEN
+	assert.Nil(t, err, "No error must be thrown")
+	assert.Nil(t, restartErr, "No error must be thrown")
+	assert.Equal(t, filepath.Join(filepath.Dir(planFile), "plan.state.jsonl"), runs.StatePath(planFile))
+	assert.True(t, resumed.Done("/phone/IMG_1.jpg", "/nas/IMG_1.jpg"))
+	assert.False(t, resumed.Done("/phone/IMG_2.jpg", "/nas/IMG_2.jpg"))
+	assert.Equal(t, 1, resumed.Count())
+	assert.Equal(t, 0, restarted.Count(), "A new checkpoint must start from the beginning")
+
+}
+
+func TestCheckpointKnowsTheLastRunWhichStartedThePlan(t *testing.T) {
+
+	//GIVEN
+	planFile := filepath.Join(t.TempDir(), "plan.json")
+	checkpoint, _ := runs.NewCheckpoint(planFile)
+	checkpoint.Start("20210101-120000-aaaaaa")
+	checkpoint.Complete("/phone/IMG_1.jpg", "/nas/IMG_1.jpg")
+	checkpoint.Start("20210102-120000-bbbbbb")
+	checkpoint.Close()
+
+	//WHEN
+	resumed, err := runs.OpenCheckpoint(planFile)
+
+	//THEN
+	assert.Nil(t, err, "No error must be thrown")
+	assert.Equal(t, "20210102-120000-bbbbbb", resumed.LastRun())
+	assert.Equal(t, 1, resumed.Count(), "A started run is not a completed operation")
+	assert.Equal(t, "", checkpoint.LastRun(), "A new checkpoint has no run before it")
+
+}
+
+func TestRunOfFindsTheRunWhoseDirHoldsThePlan(t *testing.T) {
+
+	//GIVEN
+	targetDir := t.TempDir()
+	runID := runs.NewID()
+
+	//WHEN
+	planRun := runs.RunOf(targetDir, runs.PlanPath(targetDir, runID))
+	otherRun := runs.RunOf(targetDir, filepath.Join(targetDir, "plan.json"))
+	otherTarget := runs.RunOf(t.TempDir(), runs.PlanPath(targetDir, runID))
+
+	//THEN
+	assert.Equal(t, runID, planRun)
+	assert.Equal(t, "", otherRun)
+	assert.Equal(t, "", otherTarget)
+
+}
+
+func TestOpenManifestContinuesTheManifestOfTheRun(t *testing.T) {
+
+	//GIVEN
+	targetDir := t.TempDir()
+	manifest := runs.NewManifest()
+	manifest.Add(runs.Entry{From: "/phone/IMG_1.jpg", To: "/nas/IMG_1.jpg", OpType: "COPY", Status: runs.Copied})
+	manifest.Write(targetDir)
+
+	//WHEN
+	continued, err := runs.OpenManifest(targetDir, manifest.RunID)
+	unwritten, unwrittenErr := runs.OpenManifest(targetDir, "20210101-120000-aaaaaa")
+
+	//THEN
+	assert.Nil(t, err, "No error must be thrown")
+	assert.Nil(t, unwrittenErr, "No error must be thrown")
+	assert.Equal(t, manifest.RunID, continued.RunID)
+	assert.True(t, continued.Holds("/phone/IMG_1.jpg", "/nas/IMG_1.jpg"))
+	assert.False(t, continued.Holds("/phone/IMG_2.jpg", "/nas/IMG_2.jpg"))
+	assert.Equal(t, "20210101-120000-aaaaaa", unwritten.RunID, "A run which did not write its manifest starts a new one")
+	assert.Empty(t, unwritten.Entries)
+
+}
+
+func TestLineageFollowsTheParentsOfARun(t *testing.T) {
+
+	//GIVEN
+	targetDir := t.TempDir()
+	first := &runs.Manifest{RunID: "20210101-120000-aaaaaa"}
+	second := &runs.Manifest{RunID: "20210102-120000-bbbbbb", Parent: first.RunID}
+	third := &runs.Manifest{RunID: "20210103-120000-cccccc", Parent: second.RunID}
+	first.Write(targetDir)
+	third.Write(targetDir)
+
+	//WHEN
+	lineage := runs.Lineage(targetDir, third.RunID)
+
+	//THEN
+	assert.Equal(t, []string{third.RunID, second.RunID}, lineage, "A parent without manifest ends the lineage")
+
+}
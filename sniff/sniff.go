@@ -0,0 +1,219 @@
+// Package sniff identifies the format of photos and videos from the magic bytes at the start of their content, so
+// that misnamed and extensionless files can be recognized.
+package sniff
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"os"
+	"strings"
+)
+
+// HeaderSize is the number of bytes read from the start of a file to detect its type
+const HeaderSize = 512
+
+// Type is a detected format
+type Type struct {
+	MimeType string
+	// Extensions are the lower case extensions files of the type are named with, the first one is the usual one
+	Extensions []string
+}
+
+// the types which can be detected
+var (
+	JPEG = Type{"image/jpeg", []string{".jpg", ".jpeg", ".jpe", ".jfif"}}
+	PNG  = Type{"image/png", []string{".png"}}
+	GIF  = Type{"image/gif", []string{".gif"}}
+	WebP = Type{"image/webp", []string{".webp"}}
+	HEIC = Type{"image/heic", []string{".heic", ".heif", ".hif"}}
+	HEIF = Type{"image/heif", []string{".heif", ".heic", ".hif"}}
+	AVIF = Type{"image/avif", []string{".avif"}}
+	// TIFF is a plain TIFF or one of the many RAW formats based on it which cannot be told apart by their header
+	TIFF = Type{"image/tiff", []string{".tif", ".tiff", ".dng", ".nef", ".nrw", ".arw", ".srf", ".sr2", ".pef", ".srw", ".erf", ".3fr", ".kdc", ".dcr", ".mos", ".iiq"}}
+	CR2  = Type{"image/x-canon-cr2", []string{".cr2"}}
+	CR3  = Type{"image/x-canon-cr3", []string{".cr3"}}
+	ORF  = Type{"image/x-olympus-orf", []string{".orf"}}
+	RW2  = Type{"image/x-panasonic-rw2", []string{".rw2", ".raw"}}
+	RAF  = Type{"image/x-fuji-raf", []string{".raf"}}
+	MP4  = Type{"video/mp4", []string{".mp4", ".m4v"}}
+	MOV  = Type{"video/quicktime", []string{".mov", ".qt"}}
+	M4V  = Type{"video/x-m4v", []string{".m4v", ".mp4"}}
+	GPP  = Type{"video/3gpp", []string{".3gp", ".3gpp"}}
+	GPP2 = Type{"video/3gpp2", []string{".3g2", ".3gpp2"}}
+	AVI  = Type{"video/x-msvideo", []string{".avi"}}
+	MKV  = Type{"video/x-matroska", []string{".mkv"}}
+	WebM = Type{"video/webm", []string{".webm"}}
+)
+
+// types are all types which can be detected
+var types = []Type{JPEG, PNG, GIF, WebP, HEIC, HEIF, AVIF, TIFF, CR2, CR3, ORF, RW2, RAF, MP4, MOV, M4V, GPP, GPP2, AVI, MKV, WebM}
+
+// Extension returns the usual extension of the type
+func (t Type) Extension() string {
+	return t.Extensions[0]
+}
+
+// HasExtension checks if files of the type may be named with the extension, ignoring case
+func (t Type) HasExtension(extension string) bool {
+	for _, e := range t.Extensions {
+		if strings.EqualFold(e, extension) {
+			return true
+		}
+	}
+	return false
+}
+
+// Video checks if the type is a video container
+func (t Type) Video() bool {
+	return strings.HasPrefix(t.MimeType, "video/")
+}
+
+// ByMimeType returns the type of a mime type returned by Detect
+func ByMimeType(mimeType string) (Type, bool) {
+	for _, t := range types {
+		if t.MimeType == mimeType {
+			return t, true
+		}
+	}
+	return Type{}, false
+}
+
+// DetectFile reads the header of the file and detects its type. It returns false if the format is not known.
+func DetectFile(path string) (Type, bool, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return Type{}, false, err
+	}
+	defer f.Close()
+	header := make([]byte, HeaderSize)
+	n, err := io.ReadFull(f, header)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return Type{}, false, err
+	}
+	t, ok := Detect(header[:n])
+	return t, ok, nil
+}
+
+// Detect detects the type from the first bytes of the content. It returns false if the format is not known.
+func Detect(header []byte) (Type, bool) {
+	switch {
+	case bytes.HasPrefix(header, []byte{0xFF, 0xD8, 0xFF}):
+		return JPEG, true
+	case bytes.HasPrefix(header, []byte("\x89PNG\r\n\x1a\n")):
+		return PNG, true
+	case bytes.HasPrefix(header, []byte("GIF87a")), bytes.HasPrefix(header, []byte("GIF89a")):
+		return GIF, true
+	case bytes.HasPrefix(header, []byte("RIFF")) && len(header) >= 12:
+		return riff(header[8:12])
+	case bytes.HasPrefix(header, []byte{0x1A, 0x45, 0xDF, 0xA3}):
+		// the doc type of the EBML header tells WebM from Matroska
+		if bytes.Contains(header, []byte("webm")) {
+			return WebM, true
+		}
+		return MKV, true
+	case bytes.HasPrefix(header, []byte("FUJIFILMCCD-RAW")):
+		return RAF, true
+	case bytes.HasPrefix(header, []byte("IIRO")), bytes.HasPrefix(header, []byte("IIRS")):
+		return ORF, true
+	case bytes.HasPrefix(header, []byte("IIU\x00")):
+		return RW2, true
+	case bytes.HasPrefix(header, []byte("II*\x00")), bytes.HasPrefix(header, []byte("MM\x00*")):
+		if len(header) >= 10 && string(header[8:10]) == "CR" {
+			return CR2, true
+		}
+		return TIFF, true
+	case len(header) >= 12 && string(header[4:8]) == "ftyp":
+		return isoMedia(header)
+	case len(header) >= 8 && isQuickTimeAtom(string(header[4:8])):
+		// old QuickTime movies start without a file type box
+		return MOV, true
+	}
+	return Type{}, false
+}
+
+// riff detects the formats stored in a RIFF container
+func riff(form []byte) (Type, bool) {
+	switch string(form) {
+	case "WEBP":
+		return WebP, true
+	case "AVI ":
+		return AVI, true
+	}
+	return Type{}, false
+}
+
+// isoMedia detects the formats of the ISO base media file format from the brands of the file type box
+func isoMedia(header []byte) (Type, bool) {
+	size := int(binary.BigEndian.Uint32(header[:4]))
+	if size > len(header) || size < 16 {
+		size = len(header)
+	}
+	// the major brand is followed by a version and the compatible brands
+	brands := []string{string(header[8:12])}
+	for offset := 16; offset+4 <= size; offset += 4 {
+		brands = append(brands, string(header[offset:offset+4]))
+	}
+	if isAudioBrand(brands[0]) {
+		// audio files list the generic video brands as compatible as well
+		return Type{}, false
+	}
+	heif := false
+	for _, brand := range brands {
+		if brand == "mif1" || brand == "msf1" {
+			// the generic HEIF brands are refined by the codec specific ones
+			heif = true
+			continue
+		}
+		if t, ok := brandType(brand); ok {
+			return t, true
+		}
+	}
+	if heif {
+		return HEIF, true
+	}
+	return Type{}, false
+}
+
+// brandType returns the type of a brand of the file type box
+func brandType(brand string) (Type, bool) {
+	switch brand {
+	case "heic", "heix", "heim", "heis", "hevc", "hevx":
+		return HEIC, true
+	case "avif", "avis":
+		return AVIF, true
+	case "crx ":
+		return CR3, true
+	case "qt  ":
+		return MOV, true
+	case "M4V ", "M4VH", "M4VP":
+		return M4V, true
+	case "isom", "iso2", "iso3", "iso4", "iso5", "iso6", "mp41", "mp42", "avc1", "dash", "mmp4", "MSNV", "XAVC", "f4v ":
+		return MP4, true
+	}
+	switch {
+	case strings.HasPrefix(brand, "3g2"):
+		return GPP2, true
+	case strings.HasPrefix(brand, "3gp"), strings.HasPrefix(brand, "3ge"), strings.HasPrefix(brand, "3gg"), strings.HasPrefix(brand, "3gs"):
+		return GPP, true
+	}
+	return Type{}, false
+}
+
+// isAudioBrand checks if the major brand is the one of an audio only file
+func isAudioBrand(brand string) bool {
+	switch brand {
+	case "M4A ", "M4B ", "M4P ", "F4A ", "F4B ":
+		return true
+	}
+	return false
+}
+
+// isQuickTimeAtom checks if the name is one of the atoms QuickTime movies without a file type box start with
+func isQuickTimeAtom(name string) bool {
+	switch name {
+	case "moov", "mdat", "wide", "free", "skip", "pnot":
+		return true
+	}
+	return false
+}
@@ -0,0 +1,112 @@
+package sniff_test
+
+import (
+	"copy-images/sniff"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// ftyp returns the start of an ISO base media file with the given major and compatible brands
+func ftyp(major string, compatible ...string) []byte {
+	box := append([]byte{0, 0, 0, byte(16 + 4*len(compatible))}, "ftyp"+major+"\x00\x00\x00\x00"...)
+	for _, brand := range compatible {
+		box = append(box, brand...)
+	}
+	return append(box, "\x00\x00\x00\x08mdat"...)
+}
+
+func TestDetect(t *testing.T) {
+	tests := []struct {
+		name     string
+		header   []byte
+		expected sniff.Type
+	}{
+		{"jpeg", []byte{0xFF, 0xD8, 0xFF, 0xE1, 0, 0, 'E', 'x', 'i', 'f'}, sniff.JPEG},
+		{"png", []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\x0dIHDR"), sniff.PNG},
+		{"gif", []byte("GIF89a\x01\x00"), sniff.GIF},
+		{"webp", []byte("RIFF\x24\x00\x00\x00WEBPVP8 "), sniff.WebP},
+		{"avi", []byte("RIFF\x24\x00\x00\x00AVI LIST"), sniff.AVI},
+		{"heic with a heic major brand", ftyp("heic", "mif1", "heic"), sniff.HEIC},
+		{"heic with a generic major brand", ftyp("mif1", "mif1", "miaf", "MiHB", "heic"), sniff.HEIC},
+		{"heif of another codec", ftyp("mif1", "mif1", "miaf"), sniff.HEIF},
+		{"avif", ftyp("avif", "avif", "mif1", "miaf"), sniff.AVIF},
+		{"tiff based raw", []byte("II*\x00\x08\x00\x00\x00\x10\x00"), sniff.TIFF},
+		{"big endian tiff", []byte("MM\x00*\x00\x00\x00\x08"), sniff.TIFF},
+		{"cr2", []byte("II*\x00\x10\x00\x00\x00CR\x02\x00"), sniff.CR2},
+		{"cr3", ftyp("crx ", "crx ", "isom"), sniff.CR3},
+		{"orf", []byte("IIRO\x08\x00\x00\x00"), sniff.ORF},
+		{"rw2", []byte("IIU\x00\x08\x00\x00\x00"), sniff.RW2},
+		{"raf", []byte("FUJIFILMCCD-RAW 0201FF383501"), sniff.RAF},
+		{"mp4", ftyp("isom", "isom", "iso2", "avc1", "mp41"), sniff.MP4},
+		{"mp4 of a phone", ftyp("mp42", "isom", "mp42"), sniff.MP4},
+		{"quicktime", ftyp("qt  ", "qt  "), sniff.MOV},
+		{"quicktime without file type box", []byte("\x00\x00\x00\x08wide\x00\x00\x00\x00mdat"), sniff.MOV},
+		{"m4v", ftyp("M4V ", "M4V ", "M4A ", "mp42", "isom"), sniff.M4V},
+		{"3gp", ftyp("3gp4", "isom", "3gp4"), sniff.GPP},
+		{"3g2", ftyp("3g2a", "3g2a"), sniff.GPP2},
+		{"matroska", []byte("\x1A\x45\xDF\xA3\x9f\x42\x86\x81\x01\x42\x82\x88matroska"), sniff.MKV},
+		{"webm", []byte("\x1A\x45\xDF\xA3\x9f\x42\x86\x81\x01\x42\x82\x84webm"), sniff.WebM},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			//WHEN
+			detected, ok := sniff.Detect(tt.header)
+
+			//THEN
+			assert.True(t, ok, "The type must be detected")
+			assert.Equal(t, tt.expected, detected)
+
+		})
+	}
+}
+
+func TestUnknownContentIsNotDetected(t *testing.T) {
+	for name, header := range map[string][]byte{
+		"empty":          {},
+		"text":           []byte("just some notes"),
+		"short jpeg":     {0xFF, 0xD8},
+		"audio":          ftyp("M4A ", "M4A ", "mp42", "isom"),
+		"riff wave":      []byte("RIFF\x24\x00\x00\x00WAVEfmt "),
+		"truncated ftyp": []byte("\x00\x00\x00\x18ftyp"),
+	} {
+		t.Run(name, func(t *testing.T) {
+
+			//WHEN
+			_, ok := sniff.Detect(header)
+
+			//THEN
+			assert.False(t, ok, "The content must not be detected")
+
+		})
+	}
+}
+
+func TestDetectFileReadsTheHeader(t *testing.T) {
+
+	//GIVEN
+	dir := t.TempDir()
+	heic := filepath.Join(dir, "IMG_1.jpg")
+	empty := filepath.Join(dir, "IMG_2.jpg")
+	ioutil.WriteFile(heic, ftyp("heic", "mif1", "heic"), 0644)
+	ioutil.WriteFile(empty, nil, 0644)
+
+	//WHEN
+	detected, ok, err := sniff.DetectFile(heic)
+	_, emptyOk, emptyErr := sniff.DetectFile(empty)
+	_, _, missingErr := sniff.DetectFile(filepath.Join(dir, "missing"))
+
+	//THEN
+	assert.Nil(t, err, "No error must be thrown")
+	assert.True(t, ok, "The type must be detected")
+	assert.Equal(t, "image/heic", detected.MimeType)
+	assert.False(t, detected.HasExtension(".JPG"))
+	assert.True(t, detected.HasExtension(".HEIC"))
+	assert.Nil(t, emptyErr, "An empty file must not fail")
+	assert.False(t, emptyOk, "An empty file must not be detected")
+	assert.NotNil(t, missingErr, "A missing file must fail")
+
+}
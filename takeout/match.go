@@ -0,0 +1,154 @@
+package takeout
+
+import (
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// truncatedLength is the number of characters Takeout cuts the names of its json files to, not counting the ".json"
+// and the "(1)" of duplicates. A json file with a shorter name has not been cut.
+const truncatedLength = 46
+
+// supplemental is put between the name of the file and ".json" by newer exports
+const supplemental = ".supplemental-metadata"
+
+// editedSuffixes mark the edited copies google photos exports next to the original, they are described by the json
+// file of the original
+var editedSuffixes = []string{"-edited", "-bearbeitet", "-modifié", "-editado", "-modificato"}
+
+// duplicatePattern matches the "(1)" Takeout appends to the names of files which would otherwise collide. It is
+// appended to the basename of the media file, IMG_1(1).jpg, but to the end of the name of its json file,
+// IMG_1.jpg(1).json.
+var duplicatePattern = regexp.MustCompile(`^(.+)(\(\d+\))$`)
+
+// candidate is the name of a json file which may describe a media file
+type candidate struct {
+	stem      string
+	duplicate string
+	// edited is set for the candidates of the original of an edited copy
+	edited bool
+	// truncatable is set for the longest stem, a cut json name is a prefix of it
+	truncatable bool
+}
+
+// name returns the file name of the candidate
+func (c candidate) name() string {
+	return c.stem + c.duplicate + ".json"
+}
+
+// candidates returns the json files which may describe the media file, the most specific first. The stems are tried
+// with and without the "(1)" of a duplicate moved to the end and, for edited copies, for the original.
+func candidates(name string) []candidate {
+	extension := filepath.Ext(name)
+	basename := strings.TrimSuffix(name, extension)
+	type variant struct {
+		basename, duplicate string
+		edited              bool
+	}
+	variants := []variant{{basename: basename}}
+	// the number of a duplicate may come before or after the suffix of an edited copy
+	for i := 0; i < len(variants); i++ {
+		v := variants[i]
+		if match := duplicatePattern.FindStringSubmatch(v.basename); match != nil && v.duplicate == "" {
+			variants = append(variants, variant{match[1], match[2], v.edited})
+		}
+		for _, suffix := range editedSuffixes {
+			if !v.edited && len(v.basename) > len(suffix) && strings.EqualFold(v.basename[len(v.basename)-len(suffix):], suffix) {
+				variants = append(variants, variant{v.basename[:len(v.basename)-len(suffix)], v.duplicate, true})
+			}
+		}
+	}
+	var result []candidate
+	for _, v := range variants {
+		result = append(result,
+			candidate{stem: v.basename + extension + supplemental, duplicate: v.duplicate, edited: v.edited, truncatable: true},
+			candidate{stem: v.basename + extension, duplicate: v.duplicate, edited: v.edited},
+			candidate{stem: v.basename, duplicate: v.duplicate, edited: v.edited},
+		)
+	}
+	return result
+}
+
+// Candidates returns the paths of the json files which may describe the media file, the most specific first. Names
+// cut by Takeout are not included since they cannot be told without reading the dir, see Match.
+func Candidates(path string) []string {
+	dir := filepath.Dir(path)
+	var paths []string
+	for _, edited := range []bool{false, true} {
+		for _, c := range candidates(filepath.Base(path)) {
+			if c.edited == edited {
+				paths = append(paths, filepath.Join(dir, c.name()))
+			}
+		}
+	}
+	return paths
+}
+
+// Match pairs the media files of a dir with the json files describing them, both given by name, and returns the json
+// file by media file. Names are compared ignoring case and every json file describes a single media file. The exact
+// names are matched first, then the originals of edited copies and last the json names cut by Takeout.
+func Match(media []string, jsons []string) map[string]string {
+	stems := make(map[string]string)
+	for _, name := range jsons {
+		stem := strings.TrimSuffix(name, filepath.Ext(name))
+		index(stems, stem, "", name)
+		if match := duplicatePattern.FindStringSubmatch(stem); match != nil {
+			index(stems, match[1], match[2], name)
+		}
+	}
+	matched := make(map[string]string)
+	used := make(map[string]bool)
+	pass := func(lookups func(c candidate) []string) {
+		for _, name := range media {
+			if _, ok := matched[name]; ok {
+				continue
+			}
+		search:
+			for _, c := range candidates(name) {
+				for _, k := range lookups(c) {
+					if json, ok := stems[k]; ok && !used[json] {
+						matched[name] = json
+						used[json] = true
+						break search
+					}
+				}
+			}
+		}
+	}
+	for _, edited := range []bool{false, true} {
+		pass(func(c candidate) []string {
+			if c.edited != edited {
+				return nil
+			}
+			return []string{key(c.stem, c.duplicate)}
+		})
+	}
+	// a cut name may have lost the "(1)" of the basename, the number at the end of the json name decides
+	for _, duplicate := range []bool{true, false} {
+		pass(func(c candidate) []string {
+			if !c.truncatable || (c.duplicate != "") != duplicate {
+				return nil
+			}
+			var keys []string
+			stem := []rune(c.stem)
+			for length := len(stem) - 1; length >= truncatedLength; length-- {
+				keys = append(keys, key(string(stem[:length]), c.duplicate))
+			}
+			return keys
+		})
+	}
+	return matched
+}
+
+// index adds the json file by its stem and duplicate suffix unless another one has been added before
+func index(stems map[string]string, stem string, duplicate string, name string) {
+	if _, ok := stems[key(stem, duplicate)]; !ok {
+		stems[key(stem, duplicate)] = name
+	}
+}
+
+// key identifies a stem and duplicate suffix ignoring case
+func key(stem string, duplicate string) string {
+	return strings.ToLower(stem) + "\x00" + duplicate
+}
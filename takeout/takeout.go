@@ -0,0 +1,73 @@
+// Package takeout reads the json files Google Takeout exports next to every photo and video. They hold the capture
+// time and location, while the modification time of the exported files is the time of the export.
+package takeout
+
+import (
+	"copy-images/model"
+	"encoding/json"
+	"io/ioutil"
+	"strconv"
+	"time"
+)
+
+// Metadata is the part of a Takeout json file describing its photo or video
+type Metadata struct {
+	// Title is the original name of the file, the exported file may have been renamed
+	Title string
+	// TakenTime is the capture time, it is zero if the file does not know it
+	TakenTime time.Time
+	// Location is where the photo has been taken, it is nil if the file does not know it
+	Location *model.Location
+	// Favorited is set for the files marked as favorite in google photos
+	Favorited bool
+}
+
+// document is the layout of a Takeout json file
+type document struct {
+	Title          string    `json:"title"`
+	PhotoTakenTime timestamp `json:"photoTakenTime"`
+	GeoData        geoData   `json:"geoData"`
+	GeoDataExif    geoData   `json:"geoDataExif"`
+	Favorited      bool      `json:"favorited"`
+}
+
+// timestamp holds the seconds since the epoch as string
+type timestamp struct {
+	Timestamp string `json:"timestamp"`
+}
+
+// geoData is a location, Takeout writes zeros if it does not know it
+type geoData struct {
+	Latitude  float64 `json:"latitude"`
+	Longitude float64 `json:"longitude"`
+	Altitude  float64 `json:"altitude"`
+}
+
+// Read decodes the Takeout json file
+func Read(path string) (Metadata, error) {
+	input, err := ioutil.ReadFile(path)
+	if err != nil {
+		return Metadata{}, err
+	}
+	return Decode(input)
+}
+
+// Decode decodes the content of a Takeout json file
+func Decode(input []byte) (Metadata, error) {
+	var doc document
+	if err := json.Unmarshal(input, &doc); err != nil {
+		return Metadata{}, err
+	}
+	metadata := Metadata{Title: doc.Title, Favorited: doc.Favorited}
+	if seconds, err := strconv.ParseInt(doc.PhotoTakenTime.Timestamp, 10, 64); err == nil && seconds > 0 {
+		metadata.TakenTime = time.Unix(seconds, 0)
+	}
+	// the location edited in google photos wins over the one of the camera
+	for _, geo := range []geoData{doc.GeoData, doc.GeoDataExif} {
+		if geo.Latitude != 0 || geo.Longitude != 0 {
+			metadata.Location = &model.Location{Latitude: geo.Latitude, Longitude: geo.Longitude, Altitude: geo.Altitude}
+			break
+		}
+	}
+	return metadata, nil
+}
@@ -0,0 +1,98 @@
+package takeout_test
+
+import (
+	"copy-images/model"
+	"copy-images/takeout"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMatch(t *testing.T) {
+	longName := "Screenshot_2021-03-03-14-15-16-123_com.example.gallery.jpg"
+	tests := []struct {
+		name  string
+		media []string
+		jsons []string
+		want  map[string]string
+	}{
+		{"the full name", []string{"IMG_1.jpg"}, []string{"IMG_1.jpg.json"}, map[string]string{"IMG_1.jpg": "IMG_1.jpg.json"}},
+		{"the basename", []string{"IMG_1.jpg"}, []string{"IMG_1.json"}, map[string]string{"IMG_1.jpg": "IMG_1.json"}},
+		{"the supplemental metadata of newer exports", []string{"IMG_1.jpg"}, []string{"IMG_1.jpg.supplemental-metadata.json"}, map[string]string{"IMG_1.jpg": "IMG_1.jpg.supplemental-metadata.json"}},
+		{"names are compared ignoring case", []string{"IMG_1.JPG"}, []string{"img_1.jpg.json"}, map[string]string{"IMG_1.JPG": "img_1.jpg.json"}},
+		{"a duplicate moves its number to the end", []string{"IMG_1.jpg", "IMG_1(1).jpg"}, []string{"IMG_1.jpg(1).json", "IMG_1.jpg.json"}, map[string]string{"IMG_1.jpg": "IMG_1.jpg.json", "IMG_1(1).jpg": "IMG_1.jpg(1).json"}},
+		{"a name with parentheses which is no duplicate", []string{"Screenshot (2).png"}, []string{"Screenshot (2).png.json"}, map[string]string{"Screenshot (2).png": "Screenshot (2).png.json"}},
+		{"an edited copy shares the json of its original", []string{"IMG_1.jpg", "IMG_1-edited.jpg"}, []string{"IMG_1.jpg.json"}, map[string]string{"IMG_1.jpg": "IMG_1.jpg.json"}},
+		{"an edited copy without original", []string{"IMG_1-bearbeitet.jpg"}, []string{"IMG_1.jpg.json"}, map[string]string{"IMG_1-bearbeitet.jpg": "IMG_1.jpg.json"}},
+		{"a cut name", []string{longName}, []string{longName[:46] + ".json"}, map[string]string{longName: longName[:46] + ".json"}},
+		{"a cut supplemental name", []string{"IMG_20210303_141516123.jpg"}, []string{"IMG_20210303_141516123.jpg.supplemental-metada.json"}, map[string]string{"IMG_20210303_141516123.jpg": "IMG_20210303_141516123.jpg.supplemental-metada.json"}},
+		{"a cut duplicate", []string{strings.Replace(longName, ".jpg", "(1).jpg", 1)}, []string{longName[:46] + "(1).json", longName[:46] + ".json"}, map[string]string{strings.Replace(longName, ".jpg", "(1).jpg", 1): longName[:46] + "(1).json"}},
+		{"a short name is never cut", []string{"IMG_12.jpg"}, []string{"IMG_1.json"}, map[string]string{}},
+		{"the album metadata describes no file", []string{"IMG_1.jpg"}, []string{"metadata.json"}, map[string]string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			//WHEN
+			matched := takeout.Match(tt.media, tt.jsons)
+
+			//THEN
+			assert.Equal(t, tt.want, matched)
+
+		})
+	}
+}
+
+func TestCandidatesTryTheExactNamesFirst(t *testing.T) {
+
+	//WHEN
+	candidates := takeout.Candidates(filepath.Join("Takeout", "IMG_1(1)-edited.jpg"))
+
+	//THEN
+	names := make([]string, 0, len(candidates))
+	for _, candidate := range candidates {
+		assert.Equal(t, "Takeout", filepath.Dir(candidate))
+		names = append(names, filepath.Base(candidate))
+	}
+	assert.Equal(t, []string{
+		"IMG_1(1)-edited.jpg.supplemental-metadata.json", "IMG_1(1)-edited.jpg.json", "IMG_1(1)-edited.json",
+		"IMG_1(1).jpg.supplemental-metadata.json", "IMG_1(1).jpg.json", "IMG_1(1).json",
+		"IMG_1.jpg.supplemental-metadata(1).json", "IMG_1.jpg(1).json", "IMG_1(1).json",
+	}, names, "The names of the original of the edited copy must come last")
+
+}
+
+func TestDecode(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		date     time.Time
+		location *model.Location
+		favorite bool
+	}{
+		{"date and location", `{"title": "IMG_1.jpg", "photoTakenTime": {"timestamp": "1614780916"}, "geoData": {"latitude": 48.1374, "longitude": 11.5755, "altitude": 519.5}}`,
+			time.Unix(1614780916, 0), &model.Location{Latitude: 48.1374, Longitude: 11.5755, Altitude: 519.5}, false},
+		{"the location of the camera", `{"photoTakenTime": {"timestamp": "1614780916"}, "geoData": {"latitude": 0.0, "longitude": 0.0}, "geoDataExif": {"latitude": -33.8568, "longitude": 151.2153}}`,
+			time.Unix(1614780916, 0), &model.Location{Latitude: -33.8568, Longitude: 151.2153}, false},
+		{"no location", `{"photoTakenTime": {"timestamp": "1614780916"}, "geoData": {"latitude": 0.0, "longitude": 0.0}}`, time.Unix(1614780916, 0), nil, false},
+		{"no date", `{"title": "IMG_1.jpg", "photoTakenTime": {"timestamp": "0"}}`, time.Time{}, nil, false},
+		{"favorite", `{"title": "IMG_1.jpg", "photoTakenTime": {"timestamp": "1614780916"}, "favorited": true}`, time.Unix(1614780916, 0), nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			//WHEN
+			metadata, err := takeout.Decode([]byte(tt.input))
+
+			//THEN
+			assert.Nil(t, err, "No error must be thrown")
+			assert.Equal(t, tt.date, metadata.TakenTime)
+			assert.Equal(t, tt.location, metadata.Location)
+			assert.Equal(t, tt.favorite, metadata.Favorited)
+
+		})
+	}
+}
@@ -0,0 +1,78 @@
+package trash
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// QuarantineDirName is the name of the quarantine dir in the root of a source if no other dir is configured
+const QuarantineDirName = ".copy-images-trash"
+
+// runTimeLayout is the layout of the time the ids of runs start with, see runs.NewID
+const runTimeLayout = "20060102-150405"
+
+// Quarantine keeps the trashed files of every run in a dir of its own below Dir, at their path relative to Root.
+// Files are renamed into the quarantine, so it has to be on the file system of the source.
+type Quarantine struct {
+	Dir   string
+	Root  string
+	RunID string
+}
+
+// Put implements Trash
+func (q Quarantine) Put(path string) (string, error) {
+	absolute, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+	// files outside the root keep their absolute path below the dir of the run
+	rel := strings.TrimPrefix(absolute, filepath.VolumeName(absolute))
+	if root, err := filepath.Abs(q.Root); q.Root != "" && err == nil {
+		if r, err := filepath.Rel(root, absolute); err == nil && r != ".." && !strings.HasPrefix(r, ".."+string(filepath.Separator)) {
+			rel = r
+		}
+	}
+	trashed := filepath.Join(q.Dir, q.RunID, rel)
+	if _, err := os.Lstat(trashed); err == nil {
+		return "", fmt.Errorf("%s has already been put into the quarantine as %s", path, trashed)
+	}
+	if err := os.MkdirAll(filepath.Dir(trashed), os.ModePerm); err != nil {
+		return "", err
+	}
+	if err := os.Rename(absolute, trashed); err != nil {
+		return "", err
+	}
+	return trashed, nil
+}
+
+// Purge deletes the quarantined files of all runs which started more than the retention before now for good and
+// returns the dirs of the purged runs. Dirs not named after a run are kept.
+func (q Quarantine) Purge(retention time.Duration, now time.Time) ([]string, error) {
+	entries, err := ioutil.ReadDir(q.Dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var purged []string
+	for _, entry := range entries {
+		if !entry.IsDir() || len(entry.Name()) < len(runTimeLayout) {
+			continue
+		}
+		started, err := time.ParseInLocation(runTimeLayout, entry.Name()[:len(runTimeLayout)], time.Local)
+		if err != nil || now.Sub(started) <= retention {
+			continue
+		}
+		dir := filepath.Join(q.Dir, entry.Name())
+		if err := os.RemoveAll(dir); err != nil {
+			return purged, err
+		}
+		purged = append(purged, dir)
+	}
+	return purged, nil
+}
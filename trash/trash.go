@@ -0,0 +1,89 @@
+// Package trash keeps the files copy-images deletes from a source, so that a wrong cutoff or a bug does not destroy
+// originals. Files are put into the trash of the desktop following the FreeDesktop trash spec or into a quarantine
+// dir on the source, and can be restored from there.
+package trash
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strings"
+)
+
+// Mode selects where deleted files go
+type Mode string
+
+const (
+	// ModeAuto is ModeXDG on Linux and ModeQuarantine elsewhere
+	ModeAuto Mode = "auto"
+	// ModeXDG puts the files into the trash of the desktop, see XDG
+	ModeXDG Mode = "xdg"
+	// ModeQuarantine puts the files into a quarantine dir on the source, see Quarantine
+	ModeQuarantine Mode = "quarantine"
+	// ModeNone removes the files for good
+	ModeNone Mode = "none"
+)
+
+// modes are all known modes
+var modes = []Mode{ModeAuto, ModeXDG, ModeQuarantine, ModeNone}
+
+// ParseMode returns the mode of the given name, ModeAuto is resolved to the mode of the platform
+func ParseMode(name string) (Mode, error) {
+	names := make([]string, 0, len(modes))
+	for _, mode := range modes {
+		names = append(names, string(mode))
+		if string(mode) != name {
+			continue
+		}
+		if mode == ModeAuto && runtime.GOOS == "linux" {
+			return ModeXDG, nil
+		}
+		if mode == ModeAuto {
+			return ModeQuarantine, nil
+		}
+		return mode, nil
+	}
+	return "", fmt.Errorf("unknown trash mode %q, known modes: %s", name, strings.Join(names, ", "))
+}
+
+// Trash takes the files which are deleted
+type Trash interface {
+	// Put moves the file into the trash and returns where it has been put
+	Put(path string) (string, error)
+}
+
+// trashDirPattern matches the names of the dirs holding trashed files, they are never collected from a source
+var trashDirPattern = regexp.MustCompile(`^(\.Trash|\.Trash-\d+|` + regexp.QuoteMeta(QuarantineDirName) + `)$`)
+
+// IsTrashDir checks if a dir of the given name holds trashed files, like the .Trash-1000 dir of a mount or the
+// default quarantine dir
+func IsTrashDir(name string) bool {
+	return trashDirPattern.MatchString(name)
+}
+
+// Restore moves a trashed file back to its original path. It refuses to overwrite a file which has been created at
+// the original path in the meantime. The info file of a file in a FreeDesktop trash is removed as well.
+func Restore(trashed string, original string) error {
+	if _, err := os.Lstat(original); err == nil {
+		return fmt.Errorf("%s exists, keeping %s in the trash", original, trashed)
+	} else if !os.IsNotExist(err) {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(original), os.ModePerm); err != nil {
+		return err
+	}
+	if err := os.Rename(trashed, original); err != nil {
+		return err
+	}
+	filesDir := filepath.Dir(trashed)
+	if filepath.Base(filesDir) != filesDirName {
+		return nil
+	}
+	info := filepath.Join(filepath.Dir(filesDir), infoDirName, filepath.Base(trashed)+infoExtension)
+	if err := os.Remove(info); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,120 @@
+package trash_test
+
+import (
+	"copy-images/trash"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// writeFile creates the file and its dirs
+func writeFile(t *testing.T, path string, content string) string {
+	assert.Nil(t, os.MkdirAll(filepath.Dir(path), os.ModePerm))
+	assert.Nil(t, ioutil.WriteFile(path, []byte(content), 0644))
+	return path
+}
+
+func TestQuarantineKeepsThePathBelowTheRun(t *testing.T) {
+
+	//GIVEN
+	source := t.TempDir()
+	original := writeFile(t, filepath.Join(source, "DCIM", "IMG_1.jpg"), "photo")
+	quarantine := trash.Quarantine{Dir: filepath.Join(source, trash.QuarantineDirName), Root: source, RunID: "20210303-141516-a1b2c3"}
+
+	//WHEN
+	trashed, err := quarantine.Put(original)
+	_, secondErr := quarantine.Put(writeFile(t, original, "same name"))
+	restoreErr := trash.Restore(trashed, original)
+	os.Remove(original)
+	restoredErr := trash.Restore(trashed, original)
+
+	//THEN
+	assert.Nil(t, err, "No error must be thrown")
+	assert.Equal(t, filepath.Join(source, trash.QuarantineDirName, "20210303-141516-a1b2c3", "DCIM", "IMG_1.jpg"), trashed)
+	assert.NotNil(t, secondErr, "A quarantined file must not be overwritten")
+	assert.NotNil(t, restoreErr, "A file created in the meantime must not be overwritten")
+	assert.Nil(t, restoredErr, "No error must be thrown")
+	content, _ := ioutil.ReadFile(original)
+	assert.Equal(t, "photo", string(content))
+
+}
+
+func TestQuarantinePurgesOldRuns(t *testing.T) {
+
+	//GIVEN
+	dir := t.TempDir()
+	now := time.Date(2021, time.March, 31, 12, 0, 0, 0, time.Local)
+	writeFile(t, filepath.Join(dir, "20210201-120000-aaaaaa", "IMG_1.jpg"), "old")
+	writeFile(t, filepath.Join(dir, "20210315-120000-bbbbbb", "IMG_2.jpg"), "recent")
+	writeFile(t, filepath.Join(dir, "keep", "IMG_3.jpg"), "no run")
+	quarantine := trash.Quarantine{Dir: dir}
+
+	//WHEN
+	purged, err := quarantine.Purge(30*24*time.Hour, now)
+	_, missingErr := trash.Quarantine{Dir: filepath.Join(dir, "missing")}.Purge(0, now)
+
+	//THEN
+	assert.Nil(t, err, "No error must be thrown")
+	assert.Equal(t, []string{filepath.Join(dir, "20210201-120000-aaaaaa")}, purged)
+	assert.DirExists(t, filepath.Join(dir, "20210315-120000-bbbbbb"))
+	assert.DirExists(t, filepath.Join(dir, "keep"))
+	assert.Nil(t, missingErr, "A missing quarantine has nothing to purge")
+
+}
+
+func TestXDGFollowsTheTrashSpec(t *testing.T) {
+
+	//GIVEN
+	dir := t.TempDir()
+	home := filepath.Join(dir, "Trash")
+	first := writeFile(t, filepath.Join(dir, "DCIM", "IMG 1.jpg"), "first")
+	second := writeFile(t, filepath.Join(dir, "Camera", "IMG 1.jpg"), "second")
+	xdg := trash.XDG{Home: home}
+
+	//WHEN
+	firstTrashed, err := xdg.Put(first)
+	secondTrashed, secondErr := xdg.Put(second)
+	restoreErr := trash.Restore(secondTrashed, second)
+
+	//THEN
+	if err != nil && strings.Contains(err.Error(), "only supported on Linux") {
+		t.Skip(err)
+	}
+	assert.Nil(t, err, "No error must be thrown")
+	assert.Nil(t, secondErr, "No error must be thrown")
+	assert.Equal(t, filepath.Join(home, "files", "IMG 1.jpg"), firstTrashed)
+	assert.Equal(t, filepath.Join(home, "files", "IMG 1_1.jpg"), secondTrashed, "A taken name must get a suffix")
+	info, _ := ioutil.ReadFile(filepath.Join(home, "info", "IMG 1.jpg.trashinfo"))
+	assert.Regexp(t, `^\[Trash Info\]\nPath=`+strings.ReplaceAll(filepath.ToSlash(dir), ".", `\.`)+`/DCIM/IMG%201\.jpg\nDeletionDate=\d{4}-\d{2}-\d{2}T\d{2}:\d{2}:\d{2}\n$`, string(info))
+	assert.Nil(t, restoreErr, "No error must be thrown")
+	assert.FileExists(t, second)
+	assert.NoFileExists(t, filepath.Join(home, "info", "IMG 1_1.jpg.trashinfo"), "The info of a restored file must be removed")
+
+}
+
+func TestParseMode(t *testing.T) {
+
+	//WHEN
+	none, err := trash.ParseMode("none")
+	auto, autoErr := trash.ParseMode("auto")
+	_, unknownErr := trash.ParseMode("recycle")
+
+	//THEN
+	assert.Nil(t, err, "No error must be thrown")
+	assert.Equal(t, trash.ModeNone, none)
+	assert.Nil(t, autoErr, "No error must be thrown")
+	assert.Contains(t, []trash.Mode{trash.ModeXDG, trash.ModeQuarantine}, auto, "auto must be resolved")
+	assert.NotNil(t, unknownErr, "An unknown mode must be rejected")
+
+}
+
+func TestIsTrashDir(t *testing.T) {
+	for name, isTrash := range map[string]bool{".Trash": true, ".Trash-1000": true, trash.QuarantineDirName: true, "Trash": false, ".Trash-abc": false, "DCIM": false} {
+		assert.Equal(t, isTrash, trash.IsTrashDir(name), name)
+	}
+}
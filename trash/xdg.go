@@ -0,0 +1,103 @@
+package trash
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// the layout of a trash dir defined by the FreeDesktop trash spec
+const (
+	filesDirName  = "files"
+	infoDirName   = "info"
+	infoExtension = ".trashinfo"
+)
+
+// XDG puts the files into the trash of the desktop following the FreeDesktop trash spec. Files on the file system of
+// the home dir go to the home trash, all others to the .Trash/<uid> or .Trash-<uid> dir in the top dir of their
+// mount. The trash is only supported on Linux.
+type XDG struct {
+	// Home is the home trash, $XDG_DATA_HOME/Trash or ~/.local/share/Trash if it is empty
+	Home string
+}
+
+// Put implements Trash
+func (x XDG) Put(path string) (string, error) {
+	absolute, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+	dir, topDir, err := x.trashDir(absolute)
+	if err != nil {
+		return "", err
+	}
+	for _, sub := range []string{filesDirName, infoDirName} {
+		if err := os.MkdirAll(filepath.Join(dir, sub), 0700); err != nil {
+			return "", err
+		}
+	}
+	// the trash of a mount records the path relative to its top dir, so that it still fits if it is mounted elsewhere
+	originalPath := absolute
+	if topDir != "" {
+		if originalPath, err = filepath.Rel(topDir, absolute); err != nil {
+			return "", err
+		}
+	}
+	name, info, err := reserve(dir, filepath.Base(absolute))
+	if err != nil {
+		return "", err
+	}
+	trashed := filepath.Join(dir, filesDirName, name)
+	_, err = fmt.Fprintf(info, "[Trash Info]\nPath=%s\nDeletionDate=%s\n",
+		(&url.URL{Path: filepath.ToSlash(originalPath)}).EscapedPath(), time.Now().Format("2006-01-02T15:04:05"))
+	if closeErr := info.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(absolute, trashed)
+	}
+	if err != nil {
+		os.Remove(info.Name())
+		return "", err
+	}
+	return trashed, nil
+}
+
+// home returns the dir of the home trash
+func (x XDG) home() (string, error) {
+	if x.Home != "" {
+		return x.Home, nil
+	}
+	if dataHome := os.Getenv("XDG_DATA_HOME"); dataHome != "" {
+		return filepath.Join(dataHome, "Trash"), nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".local", "share", "Trash"), nil
+}
+
+// reserve picks a name which is not taken in the trash dir yet by creating its info file exclusively, as the spec
+// asks for. The name is the one of the file, with a _1, _2, ... suffix if it is taken.
+func reserve(dir string, base string) (string, *os.File, error) {
+	extension := filepath.Ext(base)
+	for i := 0; ; i++ {
+		name := base
+		if i > 0 {
+			name = strings.TrimSuffix(base, extension) + "_" + strconv.Itoa(i) + extension
+		}
+		if _, err := os.Lstat(filepath.Join(dir, filesDirName, name)); err == nil {
+			continue
+		}
+		info, err := os.OpenFile(filepath.Join(dir, infoDirName, name+infoExtension), os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+		if os.IsExist(err) {
+			continue
+		}
+		return name, info, err
+	}
+}
@@ -0,0 +1,62 @@
+//go:build linux
+// +build linux
+
+package trash
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"syscall"
+)
+
+// trashDir returns the trash dir taking the file and the top dir of its mount, which is empty for the home trash
+func (x XDG) trashDir(path string) (string, string, error) {
+	home, err := x.home()
+	if err != nil {
+		return "", "", err
+	}
+	dev, err := device(path)
+	if err != nil {
+		return "", "", err
+	}
+	// the home trash may not exist yet, its nearest existing dir tells its file system
+	for dir := home; ; dir = filepath.Dir(dir) {
+		if homeDev, err := device(dir); err == nil {
+			if homeDev == dev {
+				return home, "", nil
+			}
+			break
+		}
+		if dir == filepath.Dir(dir) {
+			break
+		}
+	}
+	topDir := filepath.Dir(path)
+	for topDir != filepath.Dir(topDir) {
+		if parentDev, err := device(filepath.Dir(topDir)); err != nil || parentDev != dev {
+			break
+		}
+		topDir = filepath.Dir(topDir)
+	}
+	uid := strconv.Itoa(os.Getuid())
+	// a .Trash dir set up by an admin with the sticky bit holds a trash per user
+	if info, err := os.Lstat(filepath.Join(topDir, ".Trash")); err == nil && info.IsDir() && info.Mode()&os.ModeSticky != 0 {
+		return filepath.Join(topDir, ".Trash", uid), topDir, nil
+	}
+	return filepath.Join(topDir, ".Trash-"+uid), topDir, nil
+}
+
+// device returns the id of the device holding the file
+func device(path string) (uint64, error) {
+	info, err := os.Lstat(path)
+	if err != nil {
+		return 0, err
+	}
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return 0, fmt.Errorf("%s: no device", path)
+	}
+	return uint64(stat.Dev), nil
+}
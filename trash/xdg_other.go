@@ -0,0 +1,11 @@
+//go:build !linux
+// +build !linux
+
+package trash
+
+import "errors"
+
+// trashDir returns the trash dir taking the file and the top dir of its mount, which is empty for the home trash
+func (x XDG) trashDir(path string) (string, string, error) {
+	return "", "", errors.New("the FreeDesktop trash is only supported on Linux, use a quarantine dir")
+}
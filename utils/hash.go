@@ -0,0 +1,31 @@
+package utils
+
+import (
+	"copy-images/model"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"os"
+)
+
+//HashFile returns the hex encoded sha-256 of the file content
+func HashFile(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	hash := sha256.New()
+	if _, err := io.Copy(hash, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+//ContentHash returns the hex encoded sha-256 of the file content, it is only computed if the FileInfo does not carry it
+func ContentHash(f model.FileInfo) (string, error) {
+	if f.Hash != "" {
+		return f.Hash, nil
+	}
+	return HashFile(f.Path)
+}
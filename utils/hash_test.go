@@ -0,0 +1,53 @@
+package utils_test
+
+import (
+	"copy-images/model"
+	"copy-images/utils"
+	"io/ioutil"
+	"path"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHashFileReturnsSha256OfContent(t *testing.T) {
+
+	//GIVEN
+	var testFile string = path.Join(t.TempDir(), "test.txt")
+	ioutil.WriteFile(testFile, []byte("abc"), 0644)
+
+	//WHEN
+	var hash, err = utils.HashFile(testFile)
+
+	//THEN
+	assert.Nil(t, err)
+	assert.Equal(t, "ba7816bf8f01cfea414140de5dae2223b00361a396177a9cb410ff61f20015ad", hash)
+
+}
+
+func TestHashFileReportsMissingFile(t *testing.T) {
+
+	//WHEN
+	var _, err = utils.HashFile(path.Join(t.TempDir(), "missing.txt"))
+
+	//THEN
+	assert.NotNil(t, err)
+
+}
+
+func TestContentHashKeepsAKnownHash(t *testing.T) {
+
+	//GIVEN
+	var testFile string = path.Join(t.TempDir(), "test.txt")
+	ioutil.WriteFile(testFile, []byte("abc"), 0644)
+
+	//WHEN
+	var known, _ = utils.ContentHash(model.FileInfo{Path: testFile, Hash: "known"})
+	var computed, err = utils.ContentHash(model.FileInfo{Path: testFile})
+
+	//THEN
+	assert.Nil(t, err)
+	assert.Equal(t, "known", known)
+	assert.Equal(t, "ba7816bf8f01cfea414140de5dae2223b00361a396177a9cb410ff61f20015ad", computed)
+
+}
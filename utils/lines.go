@@ -0,0 +1,29 @@
+package utils
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+)
+
+//ReadLines decodes every json line of the file with the decode function. A last line cut by a crash while it was
+//written is ignored, every complete line ends with a newline.
+func ReadLines(path string, decode func(line []byte) error) error {
+	input, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	lines := bytes.Split(input, []byte("\n"))
+	for i, line := range lines {
+		if len(bytes.TrimSpace(line)) == 0 {
+			continue
+		}
+		if err := decode(line); err != nil {
+			if i == len(lines)-1 {
+				break
+			}
+			return fmt.Errorf("%s:%d: %w", path, i+1, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,43 @@
+package utils_test
+
+import (
+	"copy-images/utils"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReadLinesIgnoresOnlyACutLastLine(t *testing.T) {
+
+	//GIVEN
+	dir := t.TempDir()
+	cut := filepath.Join(dir, "cut.jsonl")
+	ioutil.WriteFile(cut, []byte("1\n\n2\n[3"), 0644)
+	corrupt := filepath.Join(dir, "corrupt.jsonl")
+	ioutil.WriteFile(corrupt, []byte("1\n[2\n3\n"), 0644)
+	decode := func(values *[]string) func(line []byte) error {
+		return func(line []byte) error {
+			var value int
+			if _, err := fmt.Sscan(string(line), &value); err != nil {
+				return fmt.Errorf("not a number: %s", line)
+			}
+			*values = append(*values, string(line))
+			return nil
+		}
+	}
+	var cutValues, corruptValues []string
+
+	//WHEN
+	err := utils.ReadLines(cut, decode(&cutValues))
+	corruptErr := utils.ReadLines(corrupt, decode(&corruptValues))
+
+	//THEN
+	assert.Nil(t, err, "No error must be thrown")
+	assert.Equal(t, []string{"1", "2"}, cutValues)
+	assert.NotNil(t, corruptErr, "A corrupt line which is not the last one must be reported")
+	assert.Contains(t, corruptErr.Error(), "corrupt.jsonl:2")
+
+}
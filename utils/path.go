@@ -0,0 +1,12 @@
+package utils
+
+import (
+	"path/filepath"
+	"strings"
+)
+
+//IsBelow checks that the path is relative and stays within the dir it is relative to once it has been cleaned
+func IsBelow(path string) bool {
+	cleaned := filepath.Clean(path)
+	return !filepath.IsAbs(path) && cleaned != ".." && !strings.HasPrefix(cleaned, ".."+string(filepath.Separator))
+}
@@ -0,0 +1,20 @@
+package utils_test
+
+import (
+	"copy-images/utils"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIsBelowRejectsPathsLeavingTheDir(t *testing.T) {
+
+	//WHEN
+	var below = []bool{utils.IsBelow("videos"), utils.IsBelow("media/../videos"), utils.IsBelow("..videos"), utils.IsBelow("")}
+	var outside = []bool{utils.IsBelow("/videos"), utils.IsBelow(".."), utils.IsBelow("../../x"), utils.IsBelow("media/../../x")}
+
+	//THEN
+	assert.Equal(t, []bool{true, true, true, true}, below)
+	assert.Equal(t, []bool{false, false, false, false}, outside)
+
+}
@@ -0,0 +1,17 @@
+package utils
+
+import "fmt"
+
+//FormatSize formats a number of bytes with the largest decimal unit below it, like 1.5 GB
+func FormatSize(bytes int64) string {
+	if bytes < 1000 {
+		return fmt.Sprintf("%d B", bytes)
+	}
+	value := float64(bytes) / 1000
+	units := []string{"kB", "MB", "GB", "TB"}
+	i := 0
+	for ; value >= 1000 && i < len(units)-1; i++ {
+		value /= 1000
+	}
+	return fmt.Sprintf("%.1f %s", value, units[i])
+}
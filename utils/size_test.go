@@ -0,0 +1,18 @@
+package utils_test
+
+import (
+	"copy-images/utils"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFormatSizeUsesTheLargestUnitBelowTheSize(t *testing.T) {
+
+	//WHEN
+	sizes := []string{utils.FormatSize(999), utils.FormatSize(1500), utils.FormatSize(10 * 1000 * 1000 * 1000), utils.FormatSize(2500 * 1000 * 1000 * 1000 * 1000)}
+
+	//THEN
+	assert.Equal(t, []string{"999 B", "1.5 kB", "10.0 GB", "2500.0 TB"}, sizes)
+
+}
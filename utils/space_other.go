@@ -0,0 +1,25 @@
+//go:build !linux && !darwin && !freebsd
+// +build !linux,!darwin,!freebsd
+
+package utils
+
+import (
+	"fmt"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+//FreeSpace returns the number of bytes available to unprivileged users on the file system holding the path
+func FreeSpace(path string) (int64, error) {
+	return 0, fmt.Errorf("free space of %s: not supported on %s", path, runtime.GOOS)
+}
+
+//FileSystemID returns an id of the file system holding the path, all paths of a volume have the same id
+func FileSystemID(path string) (uint64, error) {
+	var id uint64
+	for _, c := range strings.ToLower(filepath.VolumeName(path)) {
+		id = id*31 + uint64(c)
+	}
+	return id, nil
+}
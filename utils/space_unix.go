@@ -0,0 +1,33 @@
+//go:build linux || darwin || freebsd
+// +build linux darwin freebsd
+
+package utils
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+)
+
+//FreeSpace returns the number of bytes available to unprivileged users on the file system holding the path
+func FreeSpace(path string) (int64, error) {
+	var stat syscall.Statfs_t
+	if err := syscall.Statfs(path, &stat); err != nil {
+		return 0, err
+	}
+	return int64(uint64(stat.Bavail) * uint64(stat.Bsize)), nil
+}
+
+//FileSystemID returns an id of the file system holding the path, the ids of two paths are equal if they are on the
+//same file system
+func FileSystemID(path string) (uint64, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return 0, err
+	}
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return 0, fmt.Errorf("%s: no device", path)
+	}
+	return uint64(stat.Dev), nil
+}